@@ -1,4 +1,4 @@
-module apimocker
+module github.com/Hanashiko/apimocker
 
 go 1.24.3
 
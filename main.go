@@ -1,147 +1,39 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"crypto/tls"
+	"errors"
 	"fmt"
-	"io"
-	"io/ioutil"
 	"log"
 	"math/rand"
 	"net/http"
-	"net/url"
 	"os"
-	"path/filepath"
-	"sort"
-	"strconv"
 	"strings"
 	"time"
 
-	"gopkg.in/yaml.v3"
-
-	faker "github.com/bxcodec/faker/v3"
 	tea "github.com/charmbracelet/bubbletea"
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
-)
 
-type AuthConfig struct {
-	Type string `yaml:"type" json:"type"`
-	Token string `yaml:"token" json:"token"`
-	Username string `yaml:"username" json:"username"`
-	Password string `yaml:"password" json:"password"`
-}
-
-type Endpoint struct {
-	Path string `yaml:"path" json:"path"`
-	Method string `yaml:"method" json:"method"`
-	Data string `yaml:"data" json:"data"`
-	Count int `yaml:"count" json:"count"`
-	File string `yaml:"file" json:"file"`
-	Status int `yaml:"status" json:"status"`
-	Delay string `yaml:"delay" json:"delay"`
-	Headers map[string]string `yaml:"headers" json:"headers"`
-	Errors []ErrorConfig `yaml:"errors" json:"errors"`
-	Auth *AuthConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
-}
-
-type ErrorConfig struct {
-	Probability float64 `yaml:"probability" json:"probability"`
-	Status int `yaml:"status" json:"status"`
-	Message string `yaml:"message" json:"message"`
-}
-
-type LogConfig struct {
-	Enabled bool `yaml:"enabled" json:"enabled"`
-	Format string `yaml:"format" json:"format"`
-	Output string `yaml:"output" json:"output"`
-}
-
-type Config struct {
-	Port int `yaml:"port" json:"port"`
-	Endpoints []Endpoint `yaml:"endpoints" json:"endpoints"`
-	Logging LogConfig `yaml:"logging" json:"logging"`
-}
-
-type RequestLog struct {
-	Timestamp string `json:"timestamp"`
-	Method string `json:"method"`
-	Path string `json:"path"`
-	Query string `json:"query,omitempty"`
-	StatusCode int `json:"status_code"`
-	ResponseTime string `json:"response_time"`
-	UserAgent string `json:"user_agent,omitempty"`
-	RemoteAddr string `json:"remote_addr"`
-	ContentLength int64 `json:"content_length"`
-	AuthType string `json:"auth_type,omitempty"`
-	AuthResult string `json:"auth_result,omitempty"`
-}
-
-type Logger struct {
-	writer io.Writer
-	format string
-}
-
-func NewLogger(config LogConfig) (*Logger, error) {
-	if !config.Enabled {
-		return &Logger{writer: io.Discard, format: config.Format}, nil
-	}
-
-	var writer io.Writer
-	if config.Output == "stdout" || config.Output == "" {
-		writer = os.Stdout
-	} else {
-		file, err := os.OpenFile(config.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
-		if err != nil {
-			return nil, fmt.Errorf("failed to open log file: %v",err)
-		}
-		writer = file
-	}
+	"github.com/Hanashiko/apimocker/mocker"
+)
 
-	format := config.Format
-	if format != "json" && format != "plain" {
-		format = "plain"
-	}
-	
-	return &Logger{writer: writer, format: format}, nil
+type model struct {
+	messages []string
+	status string
 }
 
-func (l *Logger) LogRequest(reqLog RequestLog) {
-	if l.writer == io.Discard {
-		return
-	}
-
-	if l.format == "json" {
-		data, err := json.Marshal(reqLog)
-		if err != nil {
-			return
-		}
-		fmt.Fprintln(l.writer, string(data))
-	} else {
-		query := ""
-		if reqLog.Query != "" {
-			query = "?" + reqLog.Query
-		}
-		authInfo := ""
-		if reqLog.AuthType != "" {
-			authInfo = fmt.Sprintf(" - Auth: %s (%s)", reqLog.AuthType, reqLog.AuthResult)
-		}
-		fmt.Fprintf(l.writer, "[%s] %s %s%s - %d - %s - %s - %d bytes%s\r\n",
-			reqLog.Timestamp,
-			reqLog.Method,
-			reqLog.Path,
-			query,
-			reqLog.StatusCode,
-			reqLog.ResponseTime,
-			reqLog.RemoteAddr,
-			reqLog.ContentLength,
-			authInfo,
-			)
-	}
+// configReloadMsg reports the outcome of a config file reload to the TUI.
+type configReloadMsg struct {
+	path string
+	messages []string
+	err error
+	at time.Time
 }
 
-type model struct {
+// adminUpdateMsg reports endpoint changes made through the admin API.
+type adminUpdateMsg struct {
 	messages []string
+	at time.Time
 }
 
 func (m model) Init() tea.Cmd {
@@ -155,6 +47,16 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "q", "ctrl+c":
 			return m, tea.Quit
 		}
+	case configReloadMsg:
+		if msg.err != nil {
+			m.status = fmt.Sprintf("[%s] Reload of %s failed, keeping previous config: %v", msg.at.Format("15:04:05"), msg.path, msg.err)
+		} else {
+			m.messages = msg.messages
+			m.status = fmt.Sprintf("[%s] Reloaded %s", msg.at.Format("15:04:05"), msg.path)
+		}
+	case adminUpdateMsg:
+		m.messages = msg.messages
+		m.status = fmt.Sprintf("[%s] Endpoints changed through the admin API", msg.at.Format("15:04:05"))
 	}
 	return m, nil
 }
@@ -176,588 +78,77 @@ func (m model) View() string {
 	b.WriteString("\nAuthentication types supported:\n")
 	b.WriteString("- Basic Auth: Authorization: Basic <base64(username:password)>\n")
 	b.WriteString("- Bearer Token: Authorization: Bearer <token>\n")
+	b.WriteString("- Mutual TLS: client certificate signed by the configured CA\n")
+	b.WriteString("- JWT: Authorization: Bearer <signed token>, checked against keys and claims\n")
+	b.WriteString("- OAuth provider: JWTs issued by the built-in server at /token (oauth section)\n")
+	if m.status != "" {
+		b.WriteString("\n" + m.status + "\n")
+	}
 	b.WriteString("\nPress q to quit.\n")
 	return b.String()
 }
 
-func loadConfig(path string) (*Config, error) {
-	file, err := ioutil.ReadFile(path)
-	if err != nil {
-		return nil, err
-	}
-
-	config := &Config{}
-	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
-		err = yaml.Unmarshal(file, config)
-	} else {
-		err = json.Unmarshal(file, config)
-	}
-
-	for i := range config.Endpoints {
-		if config.Endpoints[i].Status == 0{
-			config.Endpoints[i].Status = 200
-		}
-		if config.Endpoints[i].Count == 0 {
-			config.Endpoints[i].Count = 1
-		}
-	}
-
-	if config.Logging.Format == "" {
-		config.Logging.Format = "plain"
-	}
-	if config.Logging.Output == "" {
-		config.Logging.Output = "stdout"
-	}
-
-	return config, err 
-}
-
-func parseDuration(delayStr string) time.Duration {
-	if delayStr == "" {
-		return 0
-	}
-
-	if strings.HasSuffix(delayStr, "ms") {
-		if ms, err := strconv.Atoi(strings.TrimSuffix(delayStr, "ms")); err == nil {
-			return time.Duration(ms) * time.Millisecond
-		}
-	}
-	if strings.HasSuffix(delayStr, "s") {
-		if s, err := strconv.Atoi(strings.TrimSuffix(delayStr, "s")); err == nil {
-			return time.Duration(s) * time.Second
-		}
-	}
-	if strings.HasSuffix(delayStr, "m") {
-		if m, err := strconv.Atoi(strings.TrimSuffix(delayStr, "m")); err == nil {
-			return time.Duration(m) * time.Minute
-		}
-	}
-
-	if duration, err := time.ParseDuration(delayStr); err == nil {
-		return duration
-	}
-
-	return 0
-}
-
-func shouldTriggerError(errors []ErrorConfig) (bool, ErrorConfig) {
-	if len(errors) == 0 {
-		return false, ErrorConfig{}
-	}
-
-	for _, errorConfig := range errors {
-		if rand.Float64() < errorConfig.Probability {
-			return true, errorConfig
-		}
-	}
-
-	return false, ErrorConfig{}
-}
-
-func authenticateRequest(r *http.Request, authConfig *AuthConfig) (bool, string, string){
-	if authConfig == nil {
-		return true, "", "no-auth"
-	}
-
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return false, authConfig.Type, "missing-auth"
-	}
-
-	switch strings.ToLower(authConfig.Type) {
-	case "basic":
-		return authenticateBasic(authHeader, authConfig)
-	case "bearer":
-		return authenticateBearer(authHeader, authConfig)
-	default:
-		return false, authConfig.Type, "invalid-auth-type"
-	}
-}
-
-func authenticateBasic(authHeader string, authConfig *AuthConfig) (bool, string, string) {
-	if !strings.HasPrefix(authHeader, "Basic "){
-		return false, "basic", "invalid-basic-format"
-	}
-
-	encoded := strings.TrimPrefix(authHeader, "Basic ")
-	decoded, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return false, "basic", "invalid-base64"
-	}
-
-	credentials := strings.SplitN(string(decoded), ":", 2)
-	if len(credentials) != 2 {
-		return false, "basic", "invalid-credentials-format"
-	}
-
-	username, password := credentials[0], credentials[1]
-	if username == authConfig.Username && password == authConfig.Password {
-		return true, "basic", "success"
-	}
-	return false, "basic", "invalid-credentials"
-}
-
-func authenticateBearer(authHeader string, authConfig *AuthConfig) (bool, string, string) {
-	if !strings.HasPrefix(authHeader, "Bearer ") {
-		return false, "bearer", "invalid-bearer-format"
-	}
-
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if token == authConfig.Token {
-		return true, "bearer", "success"
-	}
-
-	return false, "bearer", "invalid-token"
-}
-
-func generateFakeData(schema string, count int) ([]map[string]interface{}, error) {
-	supported := map[string]func() interface{}{
-		"uuid": func() interface{} { return uuid.New().String() },
-		"name": func() interface{} { return faker.Name() },
-		"email": func() interface{} { return faker.Email() },
-		"bool": func() interface{} { return rand.Intn(2) == 1 },
-		"int": func() interface{} { return rand.Intn(1000) },
-		"string": func() interface{} { return faker.Word() },
-		"lat": func() interface{} { return faker.Latitude() },
-		"lng": func() interface{} { return faker.Longitude() },
-		"ipv4": func() interface{} { return faker.IPv4() },
-		"url": func() interface{} { return faker.URL() },
-		"username": func() interface{} { return faker.Username() },
-		"password": func() interface{} { return faker.Password() },
-		"phone": func() interface{} { return faker.Phonenumber() },
-		"date": func() interface{} { return faker.Date() },
-		"timestamp": func() interface{} { return time.Now().Unix() },
-	}
-
-	var template map[string]string
-	if err := json.Unmarshal([]byte(schema), &template); err != nil {
-		fake := make([]map[string]interface{}, 0, count)
-		for i := 0; i < count; i++ {
-			item := map[string]interface{}{
-				"id": uuid.New().String(),
-				"name": faker.Name(),
-				"email": faker.Email(),
-			}
-			fake = append(fake, item)
-		}
-		return fake,nil
-	}
-
-	result := make([]map[string]interface{}, 0, count)
-	for i := 0; i < count; i++ {
-		row := make(map[string]interface{})
-		for key, typ := range template {
-			if fn, ok := supported[typ]; ok {
-				row[key] = fn()
-			} else {
-				row[key] = nil
-			}
-		}
-		result = append(result, row)
-	}
-
-	return result, nil
-}
-
-func applyQueryFilters(data []map[string]interface{}, params url.Values) []map[string]interface{} {
-	result := data
-
-	if filter := params.Get("filter"); filter != "" {
-		parts := strings.SplitN(filter, ":", 2)
-		if len(parts) == 2 {
-			field := parts[0]
-			value := parts[1]
-			var filtered []map[string]interface{}
-			for _, item := range result {
-				if itemValue, exists := item[field]; exists {
-					itemStr := fmt.Sprintf("%v", itemValue)
-					if strings.Contains(strings.ToLower(itemStr), strings.ToLower(value)) {
-						filtered = append(filtered, item)
-					}
-				}
-			}
-			result = filtered
-		}
-	}
-
-	if sortField := params.Get("sort"); sortField != "" {
-		order := params.Get("order")
-		if order == "" {
-			order = "asc"
-		}
-
-		sort.Slice(result, func(i, j int) bool {
-			val1, exists1 := result[i][sortField]
-			val2, exists2 := result[j][sortField]
-			
-			if !exists1 && !exists2 {
-				return false
-			}
-			if !exists1 {
-				return order == "desc"
-			}
-			if !exists2 {
-				return order == "asc"
-			}
-
-			str1 := fmt.Sprintf("%v", val1)
-			str2 := fmt.Sprintf("%v", val2)
-
-			if order == "desc" {
-				return str1 > str2
-			}
-			return str1 < str2
-		})
-	}
-
-	offset := 0
-	if offsetStr := params.Get("offset"); offsetStr != "" {
-		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
-			offset = parsedOffset
-		}
-	}
-
-	if offset >= len(result) {
-		return []map[string]interface{}{}
-	}
-	if offset > 0 {
-		result = result[offset:]
-	}
-
-	count := len(result)
-	if countStr := params.Get("count"); countStr != "" {
-		if parsedCount, err := strconv.Atoi(countStr); err == nil && parsedCount > 0 {
-			count = parsedCount
-		} 
-	} else if limitStr := params.Get("limit"); limitStr != "" {
-		if parsedCount, err := strconv.Atoi(limitStr); err == nil && parsedCount > 0 {
-			count = parsedCount
-		}
-	}
-
-	if count < len(result){
-		result = result[:count]
-	}
-
-	return result
-}
-
-func serveFileHandler(path string, endpoint Endpoint, logger *Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		statusCode := 200
-
-		authSuccess, authType, authResult := authenticateRequest(r, endpoint.Auth)
-		if !authSuccess {
-			statusCode = http.StatusUnauthorized
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(statusCode)
-			errorResponse := map[string]string{
-				"error": "Authentication required",
-			}
-			data, _ := json.Marshal(errorResponse)
-			w.Write(data)
-
-			duration := time.Since(start)
-			reqLog := RequestLog{
-				Timestamp: start.Format(time.RFC3339),
-				Method: r.Method,
-				Path: r.URL.Path,
-				Query: r.URL.RawQuery,
-				StatusCode: statusCode,
-				ResponseTime: duration.String(),
-				UserAgent: r.Header.Get("User-Agent"),
-				RemoteAddr: r.RemoteAddr,
-				ContentLength: int64(len(data)),
-				AuthType: authType,
-				AuthResult: authResult,
-			}
-			logger.LogRequest(reqLog)
-			return
-		}
-
-		ext := filepath.Ext(path)
-		switch ext := strings.ToLower(ext); ext {
-		case ".jpg", ".jpeg":
-			w.Header().Set("Content-Type", "image/jpeg")
-		case ".png":
-			w.Header().Set("Content-Type", "image/png")
-		case ".gif":
-			w.Header().Set("Content-Type", "image/gif")
-		case ".mp4":
-			w.Header().Set("Content-Type", "video/mp4")
-		default:
-			w.Header().Set("Content-Type", "application/octet-stream")
-		}
-		http.ServeFile(w, r, path)
-
-		duration := time.Since(start)
-		fileInfo, _ := os.Stat(path)
-		contentLength := int64(0)
-		if fileInfo != nil {
-			contentLength = fileInfo.Size()
-		}
-
-		reqLog := RequestLog{
-			Timestamp: start.Format(time.RFC3339),
-			Method: r.Method,
-			Path: r.URL.Path,
-			Query: r.URL.RawQuery,
-			StatusCode: 200,
-			ResponseTime: duration.String(),
-			UserAgent: r.Header.Get("User-Agent"),
-			RemoteAddr: r.RemoteAddr,
-			ContentLength: contentLength,
-			AuthType: authType,
-			AuthResult: authResult,
-		}
-		logger.LogRequest(reqLog)
+// watchConfig polls path and calls onChange whenever its modification time
+// or size changes, until stop is closed.
+func watchConfig(path string, interval time.Duration, stop <-chan struct{}, onChange func()) {
+	lastMod, lastSize := time.Time{}, int64(-1)
+	if info, err := os.Stat(path); err == nil {
+		lastMod, lastSize = info.ModTime(), info.Size()
 	}
-}
 
-func createLoggingHandler(endpoint Endpoint, logger *Logger) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		statusCode := endpoint.Status
-
-		if r.Method != endpoint.Method {
-			statusCode = http.StatusMethodNotAllowed
-			http.Error(w, "Method Not Allowed", statusCode)
-
-			duration := time.Since(start)
-			reqLog := RequestLog{
-				Timestamp: start.Format(time.RFC3339),
-				Method: r.Method,
-				Path: r.URL.Path,
-				Query: r.URL.RawQuery,
-				StatusCode: statusCode,
-				ResponseTime: duration.String(),
-				UserAgent: r.Header.Get("User-Agent"),
-				RemoteAddr: r.RemoteAddr,
-				ContentLength: 0,
-			}
-			logger.LogRequest(reqLog)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
 			return
-		}
-
-		authSuccess, authType, authResult := authenticateRequest(r, endpoint.Auth)
-		if !authSuccess {
-			statusCode = http.StatusUnauthorized
-			w.Header().Set("Content-Type", "application/json")
-			w.WriteHeader(statusCode)
-			errorResponse := map[string]string{
-				"error": "Authentication required",
-			}
-
-			data, _ := json.Marshal(errorResponse)
-			contentLength := int64(len(data))
-			w.Write(data)
-
-			duration := time.Since(start)
-			reqLog := RequestLog{
-				Timestamp: start.Format(time.RFC3339),
-				Method: r.Method,
-				Path: r.URL.Path,
-				Query: r.URL.RawQuery,
-				StatusCode: statusCode,
-				ResponseTime: duration.String(),
-				UserAgent: r.Header.Get("User-Agent"),
-				RemoteAddr: r.RemoteAddr,
-				ContentLength: contentLength,
-				AuthType: authType,
-				AuthResult: authResult,
-			}
-			logger.LogRequest(reqLog)
-			return
-		}
-		
-		if endpoint.Delay != "" {
-			delay := parseDuration(endpoint.Delay)
-			if delay > 0 {
-				time.Sleep(delay)
-			}
-		}
-
-	if shouldError, errorConfig := shouldTriggerError(endpoint.Errors); shouldError {
-			var contentLength int64
-			statusCode = errorConfig.Status
-			if errorConfig.Message != "" {
-				w.Header().Set("Content-Type", "application/json")
-				errorResponse := map[string]string{
-					"error": errorConfig.Message,
-				}
-				data, _ := json.Marshal(errorResponse)
-				contentLength = int64(len(data))
-				w.WriteHeader(statusCode)
-				w.Write(data)
-			} else {
-				contentLength = 0
-				w.WriteHeader(statusCode)
-			}
-
-			duration := time.Since(start)
-			reqLog := RequestLog{
-				Timestamp: start.Format(time.RFC3339),
-				Method: r.Method,
-				Path: r.URL.Path,
-				Query: r.URL.RawQuery,
-				StatusCode: statusCode,
-				ResponseTime: duration.String(),
-				UserAgent: r.Header.Get("User-Agent"),
-				RemoteAddr: r.RemoteAddr,
-				ContentLength: contentLength,
-				AuthType: authType,
-				AuthResult: authResult,
-			}
-			logger.LogRequest(reqLog)
-			return
-		}
-
-		for key, value := range endpoint.Headers {
-			w.Header().Add(key, value)
-		}
-
-		params := r.URL.Query()
-		
-		count := endpoint.Count
-		if countStr := params.Get("count"); countStr != "" {
-			if parsedCount, err := strconv.Atoi(countStr); err == nil && parsedCount > 0 {
-				count = parsedCount
-			}
-		} else if limitStr := params.Get("limit"); limitStr != "" {
-			if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-				count = parsedLimit
-			}
-		}
-
-		generateCount := count
-
-		data, err := generateFakeData(endpoint.Data, generateCount)
-		if err != nil {
-			w.Header().Set("Content-Type","application/json")
-			statusCode = http.StatusInternalServerError
-			w.WriteHeader(statusCode)
-			errorResponse := map[string]string{
-				"error": "Failed to generate data",
-			}
-			responseData, _ := json.Marshal(errorResponse)
-			w.Write(responseData)
-			
-			duration := time.Since(start)
-			reqLog := RequestLog{
-				Timestamp: start.Format(time.RFC3339),
-				Method: r.Method,
-				Path: r.URL.Path,
-				Query: r.URL.RawQuery,
-				StatusCode: statusCode,
-				ResponseTime: duration.String(),
-				UserAgent: r.Header.Get("User-Agent"),
-				RemoteAddr: r.RemoteAddr,
-				ContentLength: int64(len(responseData)),
-				AuthType: authType,
-				AuthResult: authResult,
+		case <-ticker.C:
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
 			}
-			logger.LogRequest(reqLog)
-			return
-		}
-
-		filteredData := applyQueryFilters(data, params)
-
-		var responseData []byte
-		if params.Get("meta") == "true" {
-			response := map[string]interface{}{
-				"data": filteredData,
-				"meta": map[string]interface{}{
-					"count": len(filteredData),
-					"total": len(data),
-					"offset": params.Get("offset"),
-					"limit": params.Get("count"),
-					"sort": params.Get("sort"),
-					"order": params.Get("order"),
-					"filter": params.Get("filter"),
-					"status": endpoint.Status,
-				},
+			if info.ModTime().Equal(lastMod) && info.Size() == lastSize {
+				continue
 			}
-			responseData, _ = json.Marshal(response)
-		} else {
-			responseData, _ = json.Marshal(filteredData)
+			lastMod, lastSize = info.ModTime(), info.Size()
+			onChange()
 		}
-		
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(statusCode)
-		w.Write(responseData)
-
-		duration := time.Since(start)
-		reqLog := RequestLog{
-			Timestamp: start.Format(time.RFC3339),
-			Method: r.Method,
-			Path: r.URL.Path,
-			Query: r.URL.RawQuery,
-			StatusCode: statusCode,
-			ResponseTime: duration.String(),
-			UserAgent: r.Header.Get("User-Agent"),
-			RemoteAddr: r.RemoteAddr,
-			ContentLength: int64(len(responseData)),
-			AuthType: authType,
-			AuthResult: authResult,
-		}
-		logger.LogRequest(reqLog)
 	}
 }
 
-func startServer(config *Config) ([]string, error) {
-	logger, err := NewLogger(config.Logging)
+func startServer(config *mocker.Config) (*mocker.Server, []string, error) {
+	server, messages, err := mocker.NewServer(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create logger: %v", err)
+		return nil, nil, err
 	}
 
-	var messages []string
-	for _, ep := range config.Endpoints {
-		path := ep.Path
-		method := ep.Method
-		msg := fmt.Sprintf("[%s] http://localhost:%d%s", method, config.Port, path)
-
-		if ep.Status != 200 {
-			msg += fmt.Sprintf(" (status: %d)", ep.Status)
+	serve := func(port int, tlsConfig *tls.Config) {
+		srv := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: server, TLSConfig: tlsConfig, Protocols: server.Protocols()}
+		if tlsConfig != nil {
+			log.Printf("Starting mock server on :%d (HTTPS)\n", port)
+			log.Fatal(srv.ListenAndServeTLS("", ""))
 		}
-		if ep.Delay != "" {
-			msg += fmt.Sprintf(" (delay: %s)", ep.Delay)
-		}
-		if len(ep.Errors) > 0 {
-			msg += " (with errors)"
-		}
-
-		messages = append(messages, msg)
-
-		if ep.File != "" {
-			http.HandleFunc(path, serveFileHandler(ep.File, ep, logger))
-			continue
-		}
-
-		http.HandleFunc(path, createLoggingHandler(ep, logger))
-
+		log.Printf("Starting mock server on :%d\n", port)
+		log.Fatal(srv.ListenAndServe())
 	}
-
-	if config.Logging.Enabled {
-		logMsg := fmt.Sprintf("Logging: %s format", config.Logging.Format)
-		if config.Logging.Output == "stdout" {
-			logMsg += " to stdout"
-		} else {
-			logMsg += fmt.Sprintf(" to %s", config.Logging.Output)
-		}
-		messages = append(messages, logMsg)
+	switch {
+	case config.TLS == nil:
+		go serve(config.Port, nil)
+	case config.TLS.Port != 0:
+		go serve(config.Port, nil)
+		go serve(config.TLS.Port, server.TLSConfig())
+	default:
+		go serve(config.Port, server.TLSConfig())
 	}
-
-	go func() {
-		log.Printf("Starting mock server on :%d\n", config.Port)
-		log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d",config.Port),nil))
-	}()
-		return messages, nil
+	return server, messages, nil
 }
 
 func main() {
 	rand.Seed(time.Now().UnixNano())
 	var configPath string
+	var seed int64
+	var watch bool
+	var admin bool
 
 	var rootCmd = &cobra.Command{
 		Use: "apimocker",
@@ -771,12 +162,22 @@ Supports dynamic query parameters:
  - filter: field:value to filter by
  - offset: number of items to skip
  - meta: include metadata in response (true/false)
+ - seed: seed for deterministic data
 
 Authentication types:
  - Basic Auth: username and password
- - Bearer Token: token-based authenticationn
+ - Bearer Token: token-based authentication
+ - Mutual TLS: client certificate signed by a configured CA
+ - JWT: signed bearer tokens checked against a secret, key or JWKS URL and their claims
+ - OAuth provider: built-in OAuth 2.0 / OpenID Connect server issuing JWTs (oauth)
 
 Additional features:
+ - Path parameters (/users/{id}, /users/{id:[0-9]+})
+ - Stateful in-memory CRUD resources (kind: resource)
+ - Hot reload of the config file while running
+ - Record a real backend and replay it offline (record/replay)
+ - HTTPS with your own or auto-generated certificates (tls)
+ - HTTP/2 over TLS and cleartext h2c
  - Custom status codes
  - Response delays (ms, s, m or Go duration format)
  - Custom headers
@@ -826,22 +227,240 @@ Examples:
  - GET /users?filter=name:john&count=5
  - GET /users?offset=10&limit=20&meta=true`,
 		Run: func(cmd *cobra.Command, args []string) {
-			config, err := loadConfig(configPath)
+			load := func() (*mocker.Config, error) {
+				config, err := mocker.LoadConfig(configPath)
+				if err != nil {
+					return nil, err
+				}
+				if cmd.Flags().Changed("seed") {
+					config.Seed = &seed
+				}
+				if admin {
+					if config.Admin == nil {
+						config.Admin = &mocker.AdminConfig{}
+					}
+					config.Admin.Enabled = true
+				}
+				return config, nil
+			}
+
+			config, err := load()
 			if err != nil {
 				log.Fatalf("Failed to load config: %v", err)
 			}
-			messages, err := startServer(config)
+			server, messages, err := startServer(config)
 			if err != nil {
 				log.Fatalf("Failed to start server: %v", err)
 			}
 			p := tea.NewProgram(model{messages: messages})
+			server.OnUpdate(func(messages []string) {
+				p.Send(adminUpdateMsg{messages: messages, at: time.Now()})
+			})
+
+			if watch {
+				stop := make(chan struct{})
+				defer close(stop)
+				go watchConfig(configPath, time.Second, stop, func() {
+					msg := configReloadMsg{path: configPath, at: time.Now()}
+					config, err := load()
+					if err == nil {
+						msg.messages, err = server.Reload(config)
+					}
+					msg.err = err
+					p.Send(msg)
+				})
+			}
+
 			if err := p.Start(); err != nil {
 				log.Fatalf("Error running TUI: %v", err)
 			}
 		},
 	}
 
-	rootCmd.Flags().StringVarP(&configPath, "config", "c", "mock.yaml", "Path to mock config file")
+	var recordTarget string
+	var recordOutput string
+	var recordPort int
+	var recordCmd = &cobra.Command{
+		Use: "record",
+		Short: "Proxy traffic to a real backend and record the responses as a mock config",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			recorder, err := mocker.NewRecorder(recordTarget, recordOutput, recordPort)
+			if err != nil {
+				log.Fatalf("Failed to start recorder: %v", err)
+			}
+			recorder.OnRecord = func(ep mocker.Endpoint) {
+				fmt.Printf("[REC] %s %s -> %d\n", ep.Method, ep.Path, ep.Status)
+			}
+
+			fmt.Printf("Recording %s on http://localhost:%d to %s\n", recordTarget, recordPort, recordOutput)
+			fmt.Printf("Replay it with: apimocker replay %s\n", recordOutput)
+			if err := http.ListenAndServe(fmt.Sprintf(":%d", recordPort), recorder); err != nil {
+				log.Fatalf("Recorder failed: %v", err)
+			}
+		},
+	}
+	recordCmd.Flags().StringVarP(&recordTarget, "target", "t", "", "Backend URL to proxy to, e.g. https://staging.example")
+	recordCmd.Flags().StringVarP(&recordOutput, "output", "o", "recorded.yaml", "Config file to write the recording to")
+	recordCmd.Flags().IntVarP(&recordPort, "port", "p", 5050, "Port to listen on while recording")
+	recordCmd.MarkFlagRequired("target")
+	rootCmd.AddCommand(recordCmd)
+
+	var replayCmd = &cobra.Command{
+		Use: "replay [recording]",
+		Short: "Serve a recorded config (default: recorded.yaml)",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if len(args) == 1 {
+				configPath = args[0]
+			} else if !cmd.Flags().Changed("config") {
+				configPath = "recorded.yaml"
+			}
+			rootCmd.Run(cmd, nil)
+		},
+	}
+	rootCmd.AddCommand(replayCmd)
+
+	var validateCmd = &cobra.Command{
+		Use: "validate [config]",
+		Short: "Check a mock config file and report every problem with its line and column",
+		Args: cobra.MaximumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			path := configPath
+			if len(args) == 1 {
+				path = args[0]
+			}
+
+			config, err := mocker.LoadConfig(path)
+			if err != nil {
+				var errs mocker.ConfigErrors
+				if errors.As(err, &errs) {
+					for _, configErr := range errs {
+						fmt.Fprintln(os.Stderr, configErr)
+					}
+				} else {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				}
+				os.Exit(1)
+			}
+			fmt.Printf("%s is valid (%d endpoints)\n", path, len(config.Endpoints))
+		},
+	}
+	rootCmd.AddCommand(validateCmd)
+
+	var importOutput string
+	var importPort int
+	var importCmd = &cobra.Command{
+		Use: "import",
+		Short: "Generate a mock config from an API description",
+	}
+	var importOpenAPICmd = &cobra.Command{
+		Use: "openapi <spec>",
+		Short: "Generate a mock config from an OpenAPI 3 document (YAML or JSON)",
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			endpoints, err := mocker.ImportOpenAPIFile(args[0])
+			if err != nil {
+				log.Fatalf("Failed to import spec: %v", err)
+			}
+
+			data, err := mocker.MarshalConfig(&mocker.Config{Port: importPort, Endpoints: endpoints})
+			if err != nil {
+				log.Fatalf("Failed to encode config: %v", err)
+			}
+			if importOutput == "" {
+				os.Stdout.Write(data)
+				return
+			}
+			if err := os.WriteFile(importOutput, data, 0644); err != nil {
+				log.Fatalf("Failed to write config: %v", err)
+			}
+			fmt.Printf("Wrote %d endpoints to %s\n", len(endpoints), importOutput)
+		},
+	}
+	importOpenAPICmd.Flags().StringVarP(&importOutput, "output", "o", "", "Write the config to a file instead of stdout")
+	importOpenAPICmd.Flags().IntVarP(&importPort, "port", "p", 5050, "Port for the generated config")
+	importCmd.AddCommand(importOpenAPICmd)
+	rootCmd.AddCommand(importCmd)
+
+	var exportOutput string
+	var exportFormat string
+	var exportCmd = &cobra.Command{
+		Use: "export",
+		Short: "Describe the mock config in another format",
+	}
+	var exportOpenAPICmd = &cobra.Command{
+		Use: "openapi",
+		Short: "Export the mock config as an OpenAPI 3 document",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			config, err := mocker.LoadConfig(configPath)
+			if err != nil {
+				log.Fatalf("Failed to load config: %v", err)
+			}
+
+			format := exportFormat
+			if format == "" && strings.HasSuffix(exportOutput, ".json") {
+				format = "json"
+			}
+			data, err := mocker.MarshalOpenAPI(mocker.ExportOpenAPI(config), format)
+			if err != nil {
+				log.Fatalf("Failed to encode OpenAPI document: %v", err)
+			}
+			if exportOutput == "" {
+				os.Stdout.Write(data)
+				return
+			}
+			if err := os.WriteFile(exportOutput, data, 0644); err != nil {
+				log.Fatalf("Failed to write OpenAPI document: %v", err)
+			}
+			fmt.Printf("Wrote OpenAPI document to %s\n", exportOutput)
+		},
+	}
+	exportOpenAPICmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write the document to a file instead of stdout")
+	exportOpenAPICmd.Flags().StringVarP(&exportFormat, "format", "f", "", "yaml or json (default: from the output extension, otherwise yaml)")
+	exportCmd.AddCommand(exportOpenAPICmd)
+
+	var caOutput string
+	var exportCACmd = &cobra.Command{
+		Use: "ca",
+		Short: "Export the local CA behind tls.auto so browsers and clients can trust it",
+		Args: cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			var tlsConfig mocker.TLSConfig
+			config, err := mocker.LoadConfig(configPath)
+			switch {
+			case err == nil && config.TLS != nil:
+				tlsConfig = *config.TLS
+			case err != nil && !errors.Is(err, os.ErrNotExist):
+				log.Fatalf("Failed to load config: %v", err)
+			}
+
+			ca, err := tlsConfig.CA()
+			if err != nil {
+				log.Fatalf("Failed to load CA: %v", err)
+			}
+			if caOutput == "" {
+				os.Stdout.Write(ca.PEM)
+				return
+			}
+			if err := os.WriteFile(caOutput, ca.PEM, 0644); err != nil {
+				log.Fatalf("Failed to write CA: %v", err)
+			}
+			fmt.Printf("Wrote CA certificate to %s\n", caOutput)
+		},
+	}
+	exportCACmd.Flags().StringVarP(&caOutput, "output", "o", "", "Write the certificate to a file instead of stdout")
+	exportCmd.AddCommand(exportCACmd)
+	rootCmd.AddCommand(exportCmd)
+
+	rootCmd.PersistentFlags().StringVarP(&configPath, "config", "c", "mock.yaml", "Path to mock config file")
+	// replay serves through rootCmd.Run, so it takes the same server flags.
+	for _, cmd := range []*cobra.Command{rootCmd, replayCmd} {
+		cmd.Flags().Int64Var(&seed, "seed", 0, "Seed for deterministic fake data (overrides the config seed)")
+		cmd.Flags().BoolVar(&watch, "watch", true, "Reload the config file when it changes")
+		cmd.Flags().BoolVar(&admin, "admin", false, "Enable the admin API under /__apimocker/ (see admin.token to protect it)")
+	}
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
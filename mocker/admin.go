@@ -0,0 +1,235 @@
+package mocker
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// adminPrefix is reserved for apimocker's own routes.
+const adminPrefix = "/__apimocker/"
+
+// publicAdminPaths are served under adminPrefix even when the admin API is
+// off: they only describe the mock server.
+var publicAdminPaths = map[string]bool{
+	adminPrefix + "ca.pem": true,
+	adminPrefix + "openapi.json": true,
+}
+
+// authorizeAdmin reports whether r may use the admin API and answers it
+// if not: 404 while the API is off, 401 without the configured token.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if publicAdminPaths[r.URL.Path] {
+		return true
+	}
+	config := s.adminConfig.Load()
+	if config == nil || !config.Enabled {
+		adminRespond(w, http.StatusNotFound, map[string]string{"error": "The admin API is disabled; enable it with admin.enabled or --admin"})
+		return false
+	}
+	if config.Token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(config.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="apimocker admin"`)
+			adminRespond(w, http.StatusUnauthorized, map[string]string{"error": "Unauthorized"})
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) resetIDs() {
+	s.ids = make([]int, len(s.config.Endpoints))
+	for i := range s.ids {
+		s.ids[i] = i + 1
+	}
+	s.nextID = len(s.ids) + 1
+}
+
+func (s *Server) indexOf(id int) int {
+	for i, candidate := range s.ids {
+		if candidate == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// adminEndpoint is an endpoint as listed by the admin API.
+type adminEndpoint struct {
+	ID int `json:"id"`
+	Endpoint
+}
+
+func adminRespond(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if body != nil {
+		data, _ := json.Marshal(body)
+		w.Write(data)
+	}
+}
+
+func adminError(w http.ResponseWriter, err error) {
+	var errs ConfigErrors
+	if errors.As(err, &errs) {
+		messages := make([]string, 0, len(errs))
+		for _, configErr := range errs {
+			messages = append(messages, configErr.Message)
+		}
+		adminRespond(w, http.StatusBadRequest, map[string]interface{}{"error": "Invalid endpoint", "errors": messages})
+		return
+	}
+	adminRespond(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+}
+
+// decodeAdminEndpoint decodes a JSON endpoint from r onto ep. An "id" in
+// the body is ignored.
+func decodeAdminEndpoint(r *http.Request, ep *Endpoint) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	body := adminEndpoint{Endpoint: *ep}
+	if err := decoder.Decode(&body); err != nil {
+		return fmt.Errorf("invalid endpoint JSON: %v", err)
+	}
+	*ep = body.Endpoint
+	return nil
+}
+
+// handleEndpoints serves GET and POST /__apimocker/endpoints.
+func (s *Server) handleEndpoints(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	if r.Method == http.MethodGet {
+		list := make([]adminEndpoint, 0, len(s.ids))
+		for i, id := range s.ids {
+			list = append(list, adminEndpoint{ID: id, Endpoint: s.config.Endpoints[i]})
+		}
+		adminRespond(w, http.StatusOK, list)
+		return
+	}
+
+	var ep Endpoint
+	if err := decodeAdminEndpoint(r, &ep); err != nil {
+		adminError(w, err)
+		return
+	}
+	config := cloneConfig(s.config)
+	config.Endpoints = append(config.Endpoints, ep)
+	if err := s.update(config, s.settings, false); err != nil {
+		adminError(w, err)
+		return
+	}
+
+	id := s.nextID
+	s.nextID++
+	s.ids = append(s.ids, id)
+	w.Header().Set("Location", fmt.Sprintf("%sendpoints/%d", adminPrefix, id))
+	adminRespond(w, http.StatusCreated, adminEndpoint{ID: id, Endpoint: config.Endpoints[len(config.Endpoints)-1]})
+}
+
+// handleEndpoint serves GET, PUT, PATCH and DELETE
+// /__apimocker/endpoints/{id}. PATCH merges the body into the endpoint.
+func (s *Server) handleEndpoint(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	id, _ := strconv.Atoi(pathParams(r)["id"])
+	index := s.indexOf(id)
+	if index == -1 {
+		adminRespond(w, http.StatusNotFound, map[string]string{"error": fmt.Sprintf("Endpoint %d not found", id)})
+		return
+	}
+
+	config := cloneConfig(s.config)
+	switch r.Method {
+	case http.MethodGet:
+		adminRespond(w, http.StatusOK, adminEndpoint{ID: id, Endpoint: config.Endpoints[index]})
+		return
+	case http.MethodDelete:
+		config.Endpoints = append(config.Endpoints[:index], config.Endpoints[index+1:]...)
+		if err := s.update(config, s.settings, false); err != nil {
+			adminError(w, err)
+			return
+		}
+		s.ids = append(s.ids[:index], s.ids[index+1:]...)
+		adminRespond(w, http.StatusNoContent, nil)
+		return
+	}
+
+	var ep Endpoint
+	if r.Method == http.MethodPatch {
+		// Start from a deep copy so merged maps do not leak into the live
+		// config.
+		data, _ := json.Marshal(config.Endpoints[index])
+		json.Unmarshal(data, &ep)
+	}
+	if err := decodeAdminEndpoint(r, &ep); err != nil {
+		adminError(w, err)
+		return
+	}
+	config.Endpoints[index] = ep
+	if err := s.update(config, s.settings, false); err != nil {
+		adminError(w, err)
+		return
+	}
+	adminRespond(w, http.StatusOK, adminEndpoint{ID: id, Endpoint: config.Endpoints[index]})
+}
+
+// handleSettings serves GET and PATCH /__apimocker/settings.
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	if r.Method == http.MethodGet {
+		adminRespond(w, http.StatusOK, s.settings)
+		return
+	}
+
+	settings := s.settings
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&settings); err != nil {
+		adminError(w, fmt.Errorf("invalid settings JSON: %v", err))
+		return
+	}
+	if settings.Delay != "" {
+		if _, err := parseDelay(settings.Delay); err != nil {
+			adminError(w, fmt.Errorf("delay: %v", err))
+			return
+		}
+	}
+	if err := s.update(cloneConfig(s.config), settings, false); err != nil {
+		adminError(w, err)
+		return
+	}
+	adminRespond(w, http.StatusOK, s.settings)
+}
+
+// handleReset serves POST /__apimocker/reset. It clears resources,
+// sequences, scenarios and the journal; with ?config=true it also drops every change
+// made through the admin API.
+func (s *Server) handleReset(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.unlock()
+
+	config, settings := cloneConfig(s.config), s.settings
+	restore := r.URL.Query().Get("config") == "true"
+	if restore {
+		config, settings = cloneConfig(s.base), AdminSettings{ErrorsEnabled: true}
+	}
+	if err := s.update(config, settings, true); err != nil {
+		adminError(w, err)
+		return
+	}
+	if restore {
+		s.resetIDs()
+	}
+	s.journal.Reset()
+	adminRespond(w, http.StatusOK, map[string]interface{}{"reset": true, "endpoints": len(s.config.Endpoints)})
+}
@@ -0,0 +1,186 @@
+package mocker
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminAPIKeepsState(t *testing.T) {
+	config := NewConfig(
+		Resource("/notes").Data(`{"id": "int", "text": "sentence"}`).Count(1),
+		GET("/jobs/1").Sequence(Response{Status: 202}, Response{Status: 200}),
+		GET("/step").Scenario("flow", "", "done").Status(200),
+	)
+	config.Admin = &AdminConfig{Enabled: true}
+	server, err := New(config)
+	require.NoError(t, err)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	require.Equal(t, 201, do("POST", "/notes", `{"text": "kept"}`).Code)
+	assert.Equal(t, 202, do("GET", "/jobs/1", "").Code)
+	assert.Equal(t, 200, do("GET", "/step", "").Code)
+
+	require.Equal(t, 200, do("PATCH", "/__apimocker/settings", `{"delay": "1ms"}`).Code)
+	require.Equal(t, 201, do("POST", "/__apimocker/endpoints", `{"path": "/other", "body": "x"}`).Code)
+
+	assert.Contains(t, do("GET", "/notes", "").Body.String(), "kept", "resources survive unrelated changes")
+	assert.Equal(t, 200, do("GET", "/jobs/1", "").Code, "sequences keep their position")
+	assert.Contains(t, do("GET", "/__apimocker/scenarios", "").Body.String(), `"flow":"done"`)
+
+	require.Equal(t, 200, do("PATCH", "/__apimocker/endpoints/1", `{"headers": {"X-Version": "2"}}`).Code)
+	require.Equal(t, 200, do("PATCH", "/__apimocker/endpoints/2", `{"headers": {"X-Version": "2"}}`).Code)
+	notes := do("GET", "/notes", "")
+	assert.Equal(t, "2", notes.Header().Get("X-Version"))
+	assert.Contains(t, notes.Body.String(), "kept", "new headers keep the records")
+	assert.Equal(t, 200, do("GET", "/jobs/1", "").Code, "new headers keep the position")
+
+	require.Equal(t, 200, do("PATCH", "/__apimocker/endpoints/2", `{"sequence": {"steps": [{"status": 203}, {"status": 200}]}}`).Code)
+	assert.Equal(t, 203, do("GET", "/jobs/1", "").Code, "new steps start over")
+
+	require.Equal(t, 200, do("POST", "/__apimocker/reset", "").Code)
+	assert.NotContains(t, do("GET", "/notes", "").Body.String(), "kept")
+	assert.Contains(t, do("GET", "/__apimocker/scenarios", "").Body.String(), `"flow":"started"`)
+}
+
+func TestAdminAPIUpdateCallback(t *testing.T) {
+	config := NewConfig(GET("/users").Body("[]"))
+	config.Admin = &AdminConfig{Enabled: true}
+	server, err := New(config)
+	require.NoError(t, err)
+	var listed string
+	server.OnUpdate(func([]string) {
+		// The callback runs after the admin lock is released, so it may
+		// call back into the server.
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/__apimocker/endpoints", nil))
+		listed = rr.Body.String()
+	})
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("POST", "/__apimocker/endpoints", strings.NewReader(`{"path": "/orders", "body": "[]"}`)))
+	require.Equal(t, 201, rr.Code)
+	assert.Contains(t, listed, "/orders")
+}
+
+func TestAdminAPI(t *testing.T) {
+	ok := "ok"
+	config := &Config{
+		Port: 8080,
+		Admin: &AdminConfig{Enabled: true},
+		Endpoints: []Endpoint{
+			{Path: "/users", Method: "GET", Status: 200, Count: 1, Data: `{"id": "uuid"}`},
+			{Path: "/flaky", Method: "GET", Status: 200, Count: 1, Body: &ok, Errors: []ErrorConfig{{Probability: 1, Status: 503, Message: "down"}}},
+		},
+	}
+	server, _, err := NewServer(config)
+	require.NoError(t, err)
+	var updates int
+	server.OnUpdate(func([]string) { updates++ })
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+
+	rr := do("PUT", "/__apimocker/settings", "{}")
+	assert.Equal(t, 405, rr.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS, PATCH", rr.Header().Get("Allow"))
+	assert.Equal(t, 405, do("PATCH", "/__apimocker/endpoints", "{}").Code)
+
+	rr = do("GET", "/__apimocker/endpoints", "")
+	assert.Equal(t, 200, rr.Code)
+	var list []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &list))
+	require.Len(t, list, 2)
+	assert.Equal(t, float64(1), list[0]["id"])
+	assert.Equal(t, "/users", list[0]["path"])
+
+	rr = do("POST", "/__apimocker/endpoints", `{"path": "/orders/{id}", "method": "post", "status": 201, "body": "created {{ .PathParams.id }}", "template": true}`)
+	require.Equal(t, 201, rr.Code, rr.Body.String())
+	assert.Equal(t, "/__apimocker/endpoints/3", rr.Header().Get("Location"))
+	assert.Contains(t, rr.Body.String(), `"method":"POST"`)
+	rr = do("POST", "/orders/7", "")
+	assert.Equal(t, 201, rr.Code)
+	assert.Equal(t, "created 7", rr.Body.String())
+
+	rr = do("POST", "/__apimocker/endpoints", `{"path": "/users", "method": "GET"}`)
+	assert.Equal(t, 400, rr.Code)
+	assert.Contains(t, rr.Body.String(), "duplicate")
+	rr = do("POST", "/__apimocker/endpoints", `{"path": "/x", "colour": "red"}`)
+	assert.Equal(t, 400, rr.Code)
+	assert.Contains(t, rr.Body.String(), "colour")
+
+	rr = do("PATCH", "/__apimocker/endpoints/3", `{"status": 202}`)
+	require.Equal(t, 200, rr.Code, rr.Body.String())
+	rr = do("POST", "/orders/8", "")
+	assert.Equal(t, 202, rr.Code)
+	assert.Equal(t, "created 8", rr.Body.String())
+
+	rr = do("PUT", "/__apimocker/endpoints/3", `{"path": "/orders", "body": "replaced"}`)
+	require.Equal(t, 200, rr.Code, rr.Body.String())
+	assert.Equal(t, 404, do("POST", "/orders/8", "").Code)
+	assert.Equal(t, "replaced", do("GET", "/orders", "").Body.String())
+
+	assert.Equal(t, 204, do("DELETE", "/__apimocker/endpoints/1", "").Code)
+	assert.Equal(t, 404, do("GET", "/users", "").Code)
+	assert.Equal(t, 404, do("GET", "/__apimocker/endpoints/1", "").Code)
+	assert.Equal(t, 200, do("GET", "/__apimocker/endpoints/3", "").Code)
+
+	assert.Equal(t, 503, do("GET", "/flaky", "").Code)
+	rr = do("PATCH", "/__apimocker/settings", `{"errors_enabled": false, "delay": "1ms"}`)
+	require.Equal(t, 200, rr.Code, rr.Body.String())
+	assert.JSONEq(t, `{"errors_enabled": false, "delay": "1ms"}`, rr.Body.String())
+	assert.Equal(t, 200, do("GET", "/flaky", "").Code)
+	assert.Equal(t, 400, do("PATCH", "/__apimocker/settings", `{"delay": "soon"}`).Code)
+
+	rr = do("POST", "/__apimocker/reset?config=true", "")
+	require.Equal(t, 200, rr.Code)
+	assert.Equal(t, 200, do("GET", "/users", "").Code)
+	assert.Equal(t, 404, do("GET", "/orders", "").Code)
+	assert.Equal(t, 503, do("GET", "/flaky", "").Code)
+	assert.Equal(t, 6, updates)
+}
+
+func TestAdminAPIAccess(t *testing.T) {
+	config := NewConfig(GET("/users").Body("[]"))
+	server, err := New(config)
+	require.NoError(t, err)
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, 404, do("GET", "/__apimocker/endpoints", "").Code, "off by default")
+	assert.Equal(t, 404, do("POST", "/__apimocker/reset", "").Code)
+	assert.Equal(t, 404, do("GET", "/__apimocker/scenarios", "").Code)
+	assert.Equal(t, 200, do("GET", "/__apimocker/openapi.json", "").Code, "the spec stays public")
+	assert.Equal(t, 200, do("GET", "/users", "").Code)
+
+	config.Admin = &AdminConfig{Enabled: true, Token: "s3cret"}
+	for i := range config.Endpoints {
+		applyEndpointDefaults(&config.Endpoints[i])
+	}
+	_, err = server.Reload(config)
+	require.NoError(t, err)
+	rr := do("GET", "/__apimocker/endpoints", "")
+	assert.Equal(t, 401, rr.Code)
+	assert.Equal(t, `Bearer realm="apimocker admin"`, rr.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, 401, do("GET", "/__apimocker/endpoints", "wrong").Code)
+	assert.Equal(t, 200, do("GET", "/__apimocker/endpoints", "s3cret").Code)
+	assert.Equal(t, 200, do("GET", "/__apimocker/scenarios", "s3cret").Code)
+}
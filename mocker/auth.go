@@ -0,0 +1,68 @@
+package mocker
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+func authenticateRequest(r *http.Request, authConfig *AuthConfig) (bool, string, string){
+	if authConfig == nil {
+		return true, "", "no-auth"
+	}
+	if strings.EqualFold(authConfig.Type, "mtls") {
+		return authenticateMTLS(r, authConfig)
+	}
+
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		return false, authConfig.Type, "missing-auth"
+	}
+
+	switch strings.ToLower(authConfig.Type) {
+	case "basic":
+		return authenticateBasic(authHeader, authConfig)
+	case "bearer":
+		return authenticateBearer(authHeader, authConfig)
+	case "jwt":
+		return authenticateJWT(authHeader, authConfig)
+	default:
+		return false, authConfig.Type, "invalid-auth-type"
+	}
+}
+
+func authenticateBasic(authHeader string, authConfig *AuthConfig) (bool, string, string) {
+	if !strings.HasPrefix(authHeader, "Basic "){
+		return false, "basic", "invalid-basic-format"
+	}
+
+	encoded := strings.TrimPrefix(authHeader, "Basic ")
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return false, "basic", "invalid-base64"
+	}
+
+	credentials := strings.SplitN(string(decoded), ":", 2)
+	if len(credentials) != 2 {
+		return false, "basic", "invalid-credentials-format"
+	}
+
+	username, password := credentials[0], credentials[1]
+	if username == authConfig.Username && password == authConfig.Password {
+		return true, "basic", "success"
+	}
+	return false, "basic", "invalid-credentials"
+}
+
+func authenticateBearer(authHeader string, authConfig *AuthConfig) (bool, string, string) {
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return false, "bearer", "invalid-bearer-format"
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == authConfig.Token {
+		return true, "bearer", "success"
+	}
+
+	return false, "bearer", "invalid-token"
+}
@@ -0,0 +1,173 @@
+package mocker
+
+import (
+	"encoding/base64"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticateBasic(t *testing.T) {
+	authConfig := &AuthConfig{
+		Type: "basic",
+		Username: "testuser",
+		Password: "testpass",
+	}
+
+	tests := []struct{
+		name string
+		authHeader string
+		wantAuth bool
+		wantResult string
+	}{
+		{
+			name: "valid credentials",
+			authHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("testuser:testpass")),
+			wantAuth: true,
+			wantResult: "success",
+		},
+		{
+			name: "invalid credentials",
+			authHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("wrong:wrong")),
+			wantAuth: false,
+			wantResult: "invalid-credentials",
+		},
+		{
+			name: "invalid format",
+			authHeader: "Bearer token123",
+			wantAuth: false,
+			wantResult: "invalid-basic-format",
+		},
+		{
+			name: "invalid base64",
+			authHeader: "Basic invalid-base64!",
+			wantAuth: false,
+			wantResult: "invalid-base64",
+		},
+		{
+			name: "missing colon",
+			authHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("userpass")),
+			wantAuth: false,
+			wantResult: "invalid-credentials-format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			success, authType, result := authenticateBasic(tt.authHeader, authConfig)
+			assert.Equal(t, tt.wantAuth, success)
+			assert.Equal(t, "basic", authType)
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
+
+func TestAuthenticateBearer(t *testing.T) {
+	authConfig := &AuthConfig{
+		Type: "bearer",
+		Token: "valid-token-123",
+	}
+
+	tests := []struct {
+		name string
+		authHeader string
+		wantAuth bool
+		wantResult string
+	}{
+		{
+			name: "valid token",
+			authHeader: "Bearer valid-token-123",
+			wantAuth: true,
+			wantResult: "success",
+		},
+		{
+			name: "invalid token",
+			authHeader: "Bearer wrong-token",
+			wantAuth: false,
+			wantResult: "invalid-token",
+		},
+		{
+			name: "invalid format",
+			authHeader: "Basic dGVzdA==",
+			wantAuth: false,
+			wantResult: "invalid-bearer-format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			success, authType, result := authenticateBearer(tt.authHeader, authConfig)
+			assert.Equal(t, tt.wantAuth, success)
+			assert.Equal(t, "bearer", authType)
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
+
+func TestAuthenticateRequest(t *testing.T) {
+	tests := []struct{
+		name string
+		authConfig *AuthConfig
+		authHeader string
+		wantAuth bool
+		wantType string
+		wantResult string
+	}{
+		{
+			name: "no auth required",
+			authConfig: nil,
+			authHeader: "",
+			wantAuth: true,
+			wantType: "",
+			wantResult: "no-auth",
+		},
+		{
+			name: "valid basic auth",
+			authConfig: &AuthConfig{
+				Type: "basic",
+				Username: "user",
+				Password: "pass",
+			},
+			authHeader: "Basic " + base64.StdEncoding.EncodeToString([]byte("user:pass")),
+			wantAuth: true,
+			wantType: "basic",
+			wantResult: "success",
+		},
+		{
+			name: "missing auth header",
+			authConfig: &AuthConfig{
+				Type: "bearer",
+				Token: "token123",
+			},
+			authHeader: "",
+			wantAuth: false,
+			wantType: "bearer",
+			wantResult: "missing-auth",
+		},
+		{
+			name: "invalid auth type",
+			authConfig: &AuthConfig{
+				Type: "invalid",
+			},
+			authHeader: "Bearer token",
+			wantAuth: false,
+			wantType: "invalid",
+			wantResult: "invalid-auth-type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			success, authType, result := authenticateRequest(req, tt.authConfig)
+			assert.Equal(t, tt.wantAuth, success)
+			assert.Equal(t, tt.wantType, authType)
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package mocker
+
+import (
+	"net/http"
+)
+
+// NewConfig returns a Config serving the built endpoints.
+func NewConfig(endpoints ...*EndpointBuilder) *Config {
+	config := &Config{}
+	for _, endpoint := range endpoints {
+		config.Endpoints = append(config.Endpoints, endpoint.Build())
+	}
+	return config
+}
+
+// EndpointBuilder assembles an Endpoint, e.g.
+//
+//	mocker.GET("/users/{id}").Data(`{"id": "uuid", "name": "name"}`).Single()
+type EndpointBuilder struct {
+	endpoint Endpoint
+}
+
+func On(method, path string) *EndpointBuilder {
+	return &EndpointBuilder{endpoint: Endpoint{Path: path, Method: method}}
+}
+
+func GET(path string) *EndpointBuilder { return On(http.MethodGet, path) }
+
+func POST(path string) *EndpointBuilder { return On(http.MethodPost, path) }
+
+func PUT(path string) *EndpointBuilder { return On(http.MethodPut, path) }
+
+func PATCH(path string) *EndpointBuilder { return On(http.MethodPatch, path) }
+
+func DELETE(path string) *EndpointBuilder { return On(http.MethodDelete, path) }
+
+// Resource turns the endpoint into a stateful CRUD resource seeded from
+// its data schema.
+func Resource(path string) *EndpointBuilder {
+	b := On("", path)
+	b.endpoint.Kind = "resource"
+	return b
+}
+
+func (b *EndpointBuilder) Status(status int) *EndpointBuilder {
+	b.endpoint.Status = status
+	return b
+}
+
+func (b *EndpointBuilder) Header(name, value string) *EndpointBuilder {
+	if b.endpoint.Headers == nil {
+		b.endpoint.Headers = map[string]string{}
+	}
+	b.endpoint.Headers[name] = value
+	return b
+}
+
+// Data sets the fake data schema, the same JSON template as data in a
+// config file.
+func (b *EndpointBuilder) Data(schema string) *EndpointBuilder {
+	b.endpoint.Data = schema
+	return b
+}
+
+func (b *EndpointBuilder) Count(count int) *EndpointBuilder {
+	b.endpoint.Count = count
+	return b
+}
+
+// Single returns one object instead of a list.
+func (b *EndpointBuilder) Single() *EndpointBuilder {
+	b.endpoint.Single = true
+	return b
+}
+
+func (b *EndpointBuilder) Seed(seed int64) *EndpointBuilder {
+	b.endpoint.Seed = &seed
+	return b
+}
+
+func (b *EndpointBuilder) Body(body string) *EndpointBuilder {
+	b.endpoint.Body = &body
+	return b
+}
+
+// Template renders the body or body file as a response template.
+func (b *EndpointBuilder) Template() *EndpointBuilder {
+	b.endpoint.Template = true
+	return b
+}
+
+func (b *EndpointBuilder) BodyFile(path string) *EndpointBuilder {
+	b.endpoint.BodyFile = path
+	return b
+}
+
+func (b *EndpointBuilder) File(path string) *EndpointBuilder {
+	b.endpoint.File = path
+	return b
+}
+
+// Delay sets the response delay, e.g. "300ms" or "2s".
+func (b *EndpointBuilder) Delay(delay string) *EndpointBuilder {
+	b.endpoint.Delay = delay
+	return b
+}
+
+func (b *EndpointBuilder) Error(probability float64, status int, message string) *EndpointBuilder {
+	b.endpoint.Errors = append(b.endpoint.Errors, ErrorConfig{Probability: probability, Status: status, Message: message})
+	return b
+}
+
+func (b *EndpointBuilder) BasicAuth(username, password string) *EndpointBuilder {
+	b.endpoint.Auth = &AuthConfig{Type: "basic", Username: username, Password: password}
+	return b
+}
+
+func (b *EndpointBuilder) BearerAuth(token string) *EndpointBuilder {
+	b.endpoint.Auth = &AuthConfig{Type: "bearer", Token: token}
+	return b
+}
+
+// JWTAuth requires an HS256 JWT signed with secret whose claims equal
+// those given.
+func (b *EndpointBuilder) JWTAuth(secret string, claims map[string]string) *EndpointBuilder {
+	auth := &AuthConfig{Type: "jwt", Secret: secret}
+	for name, value := range claims {
+		value := value
+		if auth.Claims == nil {
+			auth.Claims = map[string]Matcher{}
+		}
+		auth.Claims[name] = Matcher{Equals: &value}
+	}
+	b.endpoint.Auth = auth
+	return b
+}
+
+// When adds a conditional response, checked in the order added.
+func (b *EndpointBuilder) When(match MatchConfig, response Response) *EndpointBuilder {
+	b.endpoint.Responses = append(b.endpoint.Responses, ResponseRule{Match: match, Response: response})
+	return b
+}
+
+// Sequence answers successive calls with steps, then with the endpoint's
+// own response.
+func (b *EndpointBuilder) Sequence(steps ...Response) *EndpointBuilder {
+	b.endpoint.Sequence = &SequenceConfig{Steps: steps}
+	return b
+}
+
+// Scenario makes the endpoint answer only in state of the named scenario
+// (any state if empty) and then move it to nextState (unchanged if empty).
+func (b *EndpointBuilder) Scenario(name, state, nextState string) *EndpointBuilder {
+	b.endpoint.Scenario, b.endpoint.State, b.endpoint.NextState = name, state, nextState
+	return b
+}
+
+func (b *EndpointBuilder) Build() Endpoint {
+	return b.endpoint
+}
@@ -0,0 +1,57 @@
+package mocker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointBuilder(t *testing.T) {
+	endpoint := GET("/users/{id}").
+		Status(203).
+		Header("X-Mock", "yes").
+		Data(`{"id": "int"}`).
+		Count(3).
+		Single().
+		Seed(7).
+		Delay("10ms").
+		Error(0.5, 503, "busy").
+		Build()
+	assert.Equal(t, "GET", endpoint.Method)
+	assert.Equal(t, "/users/{id}", endpoint.Path)
+	assert.Equal(t, 203, endpoint.Status)
+	assert.Equal(t, map[string]string{"X-Mock": "yes"}, endpoint.Headers)
+	assert.Equal(t, `{"id": "int"}`, endpoint.Data)
+	assert.Equal(t, 3, endpoint.Count)
+	assert.True(t, endpoint.Single)
+	require.NotNil(t, endpoint.Seed)
+	assert.Equal(t, int64(7), *endpoint.Seed)
+	assert.Equal(t, "10ms", endpoint.Delay)
+	assert.Equal(t, []ErrorConfig{{Probability: 0.5, Status: 503, Message: "busy"}}, endpoint.Errors)
+
+	resource := Resource("/notes").Build()
+	assert.Equal(t, "resource", resource.Kind)
+	assert.Empty(t, resource.Method)
+
+	jwt := POST("/orders").JWTAuth("secret", map[string]string{"role": "admin"}).Build()
+	require.NotNil(t, jwt.Auth)
+	assert.Equal(t, "jwt", jwt.Auth.Type)
+	require.NotNil(t, jwt.Auth.Claims["role"].Equals)
+	assert.Equal(t, "admin", *jwt.Auth.Claims["role"].Equals)
+
+	steps := DELETE("/jobs/1").
+		Sequence(Response{Status: 202}, Response{Status: 204}).
+		Scenario("cleanup", "started", "done").
+		Build()
+	require.NotNil(t, steps.Sequence)
+	assert.Len(t, steps.Sequence.Steps, 2)
+	assert.Equal(t, "cleanup", steps.Scenario)
+	assert.Equal(t, "started", steps.State)
+	assert.Equal(t, "done", steps.NextState)
+
+	config := NewConfig(GET("/a"), PUT("/b").BearerAuth("token"))
+	require.Len(t, config.Endpoints, 2)
+	assert.Equal(t, "PUT", config.Endpoints[1].Method)
+	assert.Equal(t, "bearer", config.Endpoints[1].Auth.Type)
+}
@@ -0,0 +1,363 @@
+package mocker
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type AuthConfig struct {
+	Type string `yaml:"type" json:"type"`
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+	Username string `yaml:"username,omitempty" json:"username,omitempty"`
+	Password string `yaml:"password,omitempty" json:"password,omitempty"`
+	// CA, CommonName and SAN configure mtls: the client certificate must be
+	// signed by the CA in this PEM file and, if set, carry this subject
+	// common name and this subject alternative name.
+	CA string `yaml:"ca,omitempty" json:"ca,omitempty"`
+	CommonName string `yaml:"common_name,omitempty" json:"common_name,omitempty"`
+	SAN string `yaml:"san,omitempty" json:"san,omitempty"`
+	// Secret, PublicKey and JWKS give the jwt verification key: an HS256
+	// secret, a PEM public key or certificate, or a local JWKS file.
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+	PublicKey string `yaml:"public_key,omitempty" json:"public_key,omitempty"`
+	JWKS string `yaml:"jwks,omitempty" json:"jwks,omitempty"`
+	Issuer string `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+	Audience string `yaml:"audience,omitempty" json:"audience,omitempty"`
+	// Claims are required token claims, tested like request values in
+	// responses[].match.
+	Claims map[string]Matcher `yaml:"claims,omitempty" json:"claims,omitempty"`
+
+	// keys are the loaded jwt verification keys: those configured above or,
+	// without any, the built-in OAuth provider's.
+	keys []jwtKey
+	// clientCAs is the loaded mtls CA pool.
+	clientCAs *x509.CertPool
+}
+
+type Endpoint struct {
+	Path string `yaml:"path" json:"path"`
+	Method string `yaml:"method" json:"method"`
+	Data string `yaml:"data,omitempty" json:"data,omitempty"`
+	Body *string `yaml:"body,omitempty" json:"body,omitempty"`
+	BodyFile string `yaml:"body_file,omitempty" json:"body_file,omitempty"`
+	Template bool `yaml:"template,omitempty" json:"template,omitempty"`
+	Count int `yaml:"count,omitempty" json:"count,omitempty"`
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+	Status int `yaml:"status" json:"status"`
+	Delay string `yaml:"delay,omitempty" json:"delay,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Errors []ErrorConfig `yaml:"errors,omitempty" json:"errors,omitempty"`
+	Auth *AuthConfig `yaml:"auth,omitempty" json:"auth,omitempty"`
+	Kind string `yaml:"kind,omitempty" json:"kind,omitempty"`
+	IDField string `yaml:"id_field,omitempty" json:"id_field,omitempty"`
+	Single bool `yaml:"single,omitempty" json:"single,omitempty"`
+	Seed *int64 `yaml:"seed,omitempty" json:"seed,omitempty"`
+	Responses []ResponseRule `yaml:"responses,omitempty" json:"responses,omitempty"`
+	Sequence *SequenceConfig `yaml:"sequence,omitempty" json:"sequence,omitempty"`
+	Scenario string `yaml:"scenario,omitempty" json:"scenario,omitempty"`
+	State string `yaml:"state,omitempty" json:"state,omitempty"`
+	NextState string `yaml:"next_state,omitempty" json:"next_state,omitempty"`
+}
+
+// Response overrides an endpoint's status, headers and payload. Fields left
+// empty keep the endpoint's values; Template renders this response's body
+// as a template even if the endpoint's is not.
+type Response struct {
+	Status int `yaml:"status,omitempty" json:"status,omitempty"`
+	Headers map[string]string `yaml:"headers,omitempty" json:"headers,omitempty"`
+	Data string `yaml:"data,omitempty" json:"data,omitempty"`
+	Body *string `yaml:"body,omitempty" json:"body,omitempty"`
+	BodyFile string `yaml:"body_file,omitempty" json:"body_file,omitempty"`
+	Template bool `yaml:"template,omitempty" json:"template,omitempty"`
+	File string `yaml:"file,omitempty" json:"file,omitempty"`
+	Count int `yaml:"count,omitempty" json:"count,omitempty"`
+	Delay string `yaml:"delay,omitempty" json:"delay,omitempty"`
+	NextState string `yaml:"next_state,omitempty" json:"next_state,omitempty"`
+}
+
+// ResponseRule is a conditional response: the first rule whose match
+// conditions all hold, and whose State is the endpoint scenario's current
+// state if set, is used. A rule without conditions always matches.
+type ResponseRule struct {
+	Match MatchConfig `yaml:"match,omitempty" json:"match,omitempty"`
+	State string `yaml:"state,omitempty" json:"state,omitempty"`
+	Response `yaml:",inline"`
+}
+
+// SequenceConfig returns its steps on successive calls. After the last step
+// the sequence starts over (Loop), keeps answering with the last step
+// (StickLast) or hands back to the endpoint's own response. PerClient keeps
+// a separate position for each client, identified by "ip" or
+// "header:<Name>".
+type SequenceConfig struct {
+	Steps []Response `yaml:"steps" json:"steps"`
+	Loop bool `yaml:"loop,omitempty" json:"loop,omitempty"`
+	StickLast bool `yaml:"stick_last,omitempty" json:"stick_last,omitempty"`
+	PerClient string `yaml:"per_client,omitempty" json:"per_client,omitempty"`
+}
+
+// MatchConfig lists the request values a ResponseRule tests. Body keys are
+// JSONPath expressions ($.user.email, $.items[0].id, $.items[*].id); a key
+// without a leading $ is taken relative to the root.
+type MatchConfig struct {
+	Query map[string]Matcher `yaml:"query,omitempty" json:"query,omitempty"`
+	Headers map[string]Matcher `yaml:"headers,omitempty" json:"headers,omitempty"`
+	PathParams map[string]Matcher `yaml:"path_params,omitempty" json:"path_params,omitempty"`
+	Cookies map[string]Matcher `yaml:"cookies,omitempty" json:"cookies,omitempty"`
+	Body map[string]Matcher `yaml:"body,omitempty" json:"body,omitempty"`
+}
+
+// Matcher tests one request value. A plain scalar is shorthand for equals.
+type Matcher struct {
+	Equals *string `yaml:"equals,omitempty" json:"equals,omitempty"`
+	Regex string `yaml:"regex,omitempty" json:"regex,omitempty"`
+	Exists *bool `yaml:"exists,omitempty" json:"exists,omitempty"`
+	// Values requires exactly these occurrences, in any order, such as
+	// both values of ?tag=a&tag=b.
+	Values []string `yaml:"values,omitempty" json:"values,omitempty"`
+	Gt *float64 `yaml:"gt,omitempty" json:"gt,omitempty"`
+	Gte *float64 `yaml:"gte,omitempty" json:"gte,omitempty"`
+	Lt *float64 `yaml:"lt,omitempty" json:"lt,omitempty"`
+	Lte *float64 `yaml:"lte,omitempty" json:"lte,omitempty"`
+}
+
+type ErrorConfig struct {
+	Probability float64 `yaml:"probability" json:"probability"`
+	Status int `yaml:"status" json:"status"`
+	Message string `yaml:"message" json:"message"`
+}
+
+type LogConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	Format string `yaml:"format" json:"format"`
+	Output string `yaml:"output" json:"output"`
+}
+
+type Config struct {
+	Port int `yaml:"port" json:"port"`
+	Endpoints []Endpoint `yaml:"endpoints" json:"endpoints"`
+	Logging LogConfig `yaml:"logging,omitempty" json:"logging"`
+	Seed *int64 `yaml:"seed,omitempty" json:"seed,omitempty"`
+	Spec string `yaml:"spec,omitempty" json:"spec,omitempty"`
+	Proxy *ProxyConfig `yaml:"proxy,omitempty" json:"proxy,omitempty"`
+	Journal JournalConfig `yaml:"journal,omitempty" json:"journal,omitempty"`
+	TLS *TLSConfig `yaml:"tls,omitempty" json:"tls,omitempty"`
+	// HTTP2 enables HTTP/2 over TLS and cleartext h2c next to HTTP/1.1
+	// unless set to false.
+	HTTP2 *bool `yaml:"http2,omitempty" json:"http2,omitempty"`
+	OAuth *OAuthConfig `yaml:"oauth,omitempty" json:"oauth,omitempty"`
+	Admin *AdminConfig `yaml:"admin,omitempty" json:"admin,omitempty"`
+}
+
+// AdminConfig enables the admin API under /__apimocker/, which can change
+// endpoints, scenario states and settings and read the journal. It is off
+// unless Enabled is set; with a Token, requests must send it as a bearer
+// token.
+type AdminConfig struct {
+	Enabled bool `yaml:"enabled" json:"enabled"`
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+}
+
+// OAuthConfig enables the built-in OAuth 2.0 / OpenID Connect provider.
+// Without Clients it is open: every grant accepts any client ID and
+// redirect URI and ignores client secrets. Without Users a single user
+// "user" with password "password" can sign in.
+// Issuer defaults to the URL the provider is reached on and TokenTTL to 1h.
+type OAuthConfig struct {
+	Issuer string `yaml:"issuer,omitempty" json:"issuer,omitempty"`
+	TokenTTL string `yaml:"token_ttl,omitempty" json:"token_ttl,omitempty"`
+	Clients []OAuthClient `yaml:"clients,omitempty" json:"clients,omitempty"`
+	Users []OAuthUser `yaml:"users,omitempty" json:"users,omitempty"`
+}
+
+// OAuthClient is a client of the OAuth provider. A client without a Secret
+// is public and identifies itself by ID alone. With RedirectURIs, only
+// these may receive authorization codes.
+type OAuthClient struct {
+	ID string `yaml:"id" json:"id"`
+	Secret string `yaml:"secret,omitempty" json:"secret,omitempty"`
+	RedirectURIs []string `yaml:"redirect_uris,omitempty" json:"redirect_uris,omitempty"`
+}
+
+// OAuthUser signs in to the OAuth provider. Claims are added to its tokens
+// and returned by /userinfo.
+type OAuthUser struct {
+	Username string `yaml:"username" json:"username"`
+	Password string `yaml:"password" json:"password"`
+	Claims map[string]interface{} `yaml:"claims,omitempty" json:"claims,omitempty"`
+}
+
+// TLSConfig serves HTTPS with the Cert and Key files or, with Auto, with a
+// certificate for localhost and Hosts issued by a local CA kept at CACert
+// and CAKey. HTTPS replaces HTTP on the config port unless Port gives it
+// a port of its own.
+type TLSConfig struct {
+	Cert string `yaml:"cert,omitempty" json:"cert,omitempty"`
+	Key string `yaml:"key,omitempty" json:"key,omitempty"`
+	Auto bool `yaml:"auto,omitempty" json:"auto,omitempty"`
+	Hosts []string `yaml:"hosts,omitempty" json:"hosts,omitempty"`
+	CACert string `yaml:"ca_cert,omitempty" json:"ca_cert,omitempty"`
+	CAKey string `yaml:"ca_key,omitempty" json:"ca_key,omitempty"`
+	Port int `yaml:"port,omitempty" json:"port,omitempty"`
+}
+
+// JournalConfig sizes the in-memory journal of received requests.
+type JournalConfig struct {
+	Limit int `yaml:"limit,omitempty" json:"limit,omitempty"`
+}
+
+// ProxyConfig forwards requests that match no endpoint to a real backend.
+type ProxyConfig struct {
+	Target string `yaml:"target" json:"target"`
+	RewriteHost bool `yaml:"rewrite_host,omitempty" json:"rewrite_host,omitempty"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	file, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &Config{}
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		err = yaml.Unmarshal(file, config)
+	} else {
+		err = json.Unmarshal(file, config)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var imported []Endpoint
+	var specErr error
+	if config.Spec != "" {
+		imported, specErr = ImportOpenAPIFile(config.Spec)
+	}
+
+	// Errors point into the file when it also parses as a YAML node tree.
+	// JSON that YAML rejects, such as an escaped slash "\/", is still
+	// checked, without positions.
+	var root yaml.Node
+	var errs ConfigErrors
+	if err := yaml.Unmarshal(file, &root); err == nil {
+		errs = validateConfig(path, &root, config, specErr)
+	} else {
+		errs = validateConfigValue(path, config, specErr)
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if specErr != nil {
+		return nil, specErr
+	}
+	config.Endpoints = mergeEndpoints(config.Endpoints, imported)
+
+	for i := range config.Endpoints {
+		applyEndpointDefaults(&config.Endpoints[i])
+	}
+
+	if config.Logging.Format == "" {
+		config.Logging.Format = "plain"
+	}
+	if config.Logging.Output == "" {
+		config.Logging.Output = "stdout"
+	}
+
+	return config, nil
+}
+
+func applyEndpointDefaults(endpoint *Endpoint) {
+	endpoint.Method = strings.ToUpper(endpoint.Method)
+	if endpoint.Method == "" {
+		endpoint.Method = http.MethodGet
+	}
+	if endpoint.Status == 0 {
+		endpoint.Status = 200
+	}
+	if endpoint.Count == 0 {
+		endpoint.Count = 1
+	}
+}
+
+// routeKey identifies a method and path independently of parameter names
+// and constraints, so /users/{id} and /users/{userId:[0-9]+} collide.
+func routeKey(method, path string) string {
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	parts := splitPath(path)
+	for i, part := range parts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			parts[i] = "{}"
+		}
+	}
+	return method + " /" + strings.Join(parts, "/")
+}
+
+// MarshalConfig encodes config as YAML with two-space indentation.
+func MarshalConfig(config *Config) ([]byte, error) {
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(config); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+func parseDuration(delayStr string) time.Duration {
+	duration, err := parseDelay(delayStr)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// parseDelay accepts whole ms/s/m values ("300ms", "2s", "1m") as well as
+// any Go duration ("1h30m").
+func parseDelay(delayStr string) (time.Duration, error) {
+	if delayStr == "" {
+		return 0, nil
+	}
+
+	if strings.HasSuffix(delayStr, "ms") {
+		if ms, err := strconv.Atoi(strings.TrimSuffix(delayStr, "ms")); err == nil && ms >= 0 {
+			return time.Duration(ms) * time.Millisecond, nil
+		}
+	}
+	if strings.HasSuffix(delayStr, "s") {
+		if s, err := strconv.Atoi(strings.TrimSuffix(delayStr, "s")); err == nil && s >= 0 {
+			return time.Duration(s) * time.Second, nil
+		}
+	}
+	if strings.HasSuffix(delayStr, "m") {
+		if m, err := strconv.Atoi(strings.TrimSuffix(delayStr, "m")); err == nil && m >= 0 {
+			return time.Duration(m) * time.Minute, nil
+		}
+	}
+
+	if duration, err := time.ParseDuration(delayStr); err == nil && duration >= 0 {
+		return duration, nil
+	}
+
+	return 0, fmt.Errorf("invalid delay %q (expected e.g. 300ms, 2s, 1m or 1h30m)", delayStr)
+}
+
+func cloneConfig(config *Config) *Config {
+	clone := *config
+	clone.Endpoints = append([]Endpoint(nil), config.Endpoints...)
+	return &clone
+}
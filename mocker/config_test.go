@@ -0,0 +1,211 @@
+package mocker
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		configData  string
+		filename    string
+		wantErr     bool
+		expectedPort int
+	}{
+		{
+			name: "valid YAML config",
+			configData: `
+port: 8080
+logging:
+  enabled: true
+  format: json
+  output: stdout
+endpoints:
+  - path: /users
+    method: GET
+    count: 5
+    data: '{"id": "uuid", "name": "name"}'
+`,
+			filename:    "test.yaml",
+			wantErr:     false,
+			expectedPort: 8080,
+		},
+		{
+			name: "valid JSON config",
+			configData: `{
+  "port": 9090,
+  "logging": {
+    "enabled": false,
+    "format": "plain",
+    "output": "test.log"
+  },
+  "endpoints": [
+    {
+      "path": "/api/data",
+      "method": "POST",
+      "status": 201,
+      "count": 10
+    }
+  ]
+}`,
+			filename:    "test.json",
+			wantErr:     false,
+			expectedPort: 9090,
+		},
+		{
+			name:       "invalid config file",
+			configData: "invalid: yaml: content:",
+			filename:   "test.yaml",
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpFile, err := os.CreateTemp("", "config-*"+filepath.Ext(tt.filename))
+			require.NoError(t, err)
+			defer os.Remove(tmpFile.Name())
+
+			_, err = tmpFile.WriteString(tt.configData)
+			require.NoError(t, err)
+			tmpFile.Close()
+
+			config, err := LoadConfig(tmpFile.Name())
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.expectedPort, config.Port)
+
+			for _, endpoint := range config.Endpoints {
+				if endpoint.Status == 0 {
+					t.Errorf("Status should be set to default 200, got %d", endpoint.Status)
+				}
+				if endpoint.Count == 0 {
+					t.Errorf("Count should be set to default 1, got %d", endpoint.Count)
+				}
+			}
+		})
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		input string
+		expected time.Duration
+	}{
+		{"", 0},
+		{"100ms", 100 * time.Millisecond},
+		{"5s", 5 * time.Second},
+		{"2m", 2 * time.Minute},
+		{"1h30m", 90 * time.Minute},
+		{"invalid", 0},
+		{"100", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			result := parseDuration(tt.input)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestIntegration(t *testing.T) {
+	configContent := `
+port: 0
+logging:
+  enabled: false
+endpoints:
+  - path: /users
+    method: GET
+    status: 200
+    count: 3
+    data: '{"id": "uuid", "name": "name", "email": "email"}'
+  - path: /admin
+    method: GET
+    status: 200
+    data: '{"admin": true}'
+    auth:
+      type: bearer
+      token: admin-secret
+`
+
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString(configContent)
+	require.NoError(t, err)
+	tmpFile.Close()
+
+	config, err := LoadConfig(tmpFile.Name())
+	require.NoError(t, err)
+
+	assert.Equal(t, 0, config.Port)
+	assert.Len(t, config.Endpoints, 2)
+
+	endpoint1 := config.Endpoints[0]
+	assert.Equal(t, "/users", endpoint1.Path)
+	assert.Equal(t, "GET", endpoint1.Method)
+	assert.Equal(t, 200, endpoint1.Status)
+	assert.Equal(t, 3, endpoint1.Count)
+
+	endpoint2 := config.Endpoints[1]
+	assert.Equal(t, "/admin", endpoint2.Path)
+	assert.NotNil(t, endpoint2.Auth)
+	assert.Equal(t, "bearer", endpoint2.Auth.Type)
+	assert.Equal(t, "admin-secret", endpoint2.Auth.Token)
+}
+
+func TestLoadConfigSharedPath(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString(`
+port: 0
+endpoints:
+  - path: /users
+    method: get
+  - path: /users
+    method: POST
+    status: 201
+  - path: /health
+`)
+	require.NoError(t, err)
+	tmpFile.Close()
+
+	config, err := LoadConfig(tmpFile.Name())
+	require.NoError(t, err)
+	assert.Equal(t, "GET", config.Endpoints[0].Method)
+	assert.Equal(t, "POST", config.Endpoints[1].Method)
+	assert.Equal(t, "GET", config.Endpoints[2].Method)
+
+	router := NewRouter(nil)
+	for _, endpoint := range config.Endpoints {
+		require.NoError(t, router.Handle(endpoint.Path, endpoint.Method, http.NotFoundHandler()))
+	}
+	assert.Error(t, router.Handle("/users", "POST", http.NotFoundHandler()))
+}
+
+func TestParseDelay(t *testing.T) {
+	for _, valid := range []string{"", "0ms", "300ms", "2s", "1m", "1h30m", "1.5s"} {
+		_, err := parseDelay(valid)
+		assert.NoError(t, err, valid)
+	}
+	for _, invalid := range []string{"soon", "100", "-5s", "ms"} {
+		_, err := parseDelay(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
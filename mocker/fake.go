@@ -0,0 +1,501 @@
+package mocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"net/http"
+	"regexp/syntax"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	faker "github.com/bxcodec/faker/v3"
+	"github.com/google/uuid"
+)
+
+// fakeSource carries the randomness and clock for one generation pass, so a
+// seeded source always yields the same records.
+type fakeSource struct {
+	rng *rand.Rand
+	now time.Time
+	request *templateRequest
+}
+
+// seededNow is the clock seen by seeded generation, keeping timestamps and
+// dates stable between runs.
+var seededNow = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newFakeSource(seed *int64) *fakeSource {
+	if seed == nil {
+		return &fakeSource{rng: rand.New(rand.NewSource(time.Now().UnixNano())), now: time.Now()}
+	}
+	return &fakeSource{rng: rand.New(rand.NewSource(*seed)), now: seededNow}
+}
+
+func (src *fakeSource) uuid() string {
+	id, err := uuid.NewRandomFromReader(src.rng)
+	if err != nil {
+		return uuid.New().String()
+	}
+	return id.String()
+}
+
+// intBetween returns a uniform int in [low, high]. The span is computed
+// unsigned, so ranges covering most of int do not overflow.
+func (src *fakeSource) intBetween(low, high int) int {
+	span := uint64(high) - uint64(low)
+	switch {
+	case span < math.MaxInt32:
+		return low + src.rng.Intn(int(span)+1)
+	case span < math.MaxInt64:
+		return low + int(src.rng.Int63n(int64(span)+1))
+	}
+	for {
+		if v := src.rng.Uint64(); v <= span {
+			return low + int(v)
+		}
+	}
+}
+
+// fakerMu serializes calls into faker, which draws from a package-level
+// random source.
+var fakerMu sync.Mutex
+
+// withFaker adapts a faker function into a generator. faker's source is
+// pointed at src.rng for the call, so values come from the per-request
+// source and seeded output repeats exactly.
+func withFaker[T any](generate func() T) valueGenerator {
+	return func(src *fakeSource) interface{} {
+		fakerMu.Lock()
+		defer fakerMu.Unlock()
+		faker.SetRandomSource(src.rng)
+		return generate()
+	}
+}
+
+type valueGenerator func(src *fakeSource) interface{}
+
+var fakeWord = withFaker(faker.Word)
+
+var fakeTypes = map[string]valueGenerator{
+	"uuid": func(src *fakeSource) interface{} { return src.uuid() },
+	"name": withFaker(faker.Name),
+	"email": withFaker(faker.Email),
+	"bool": func(src *fakeSource) interface{} { return src.rng.Intn(2) == 1 },
+	"int": func(src *fakeSource) interface{} { return src.rng.Intn(1000) },
+	"float": func(src *fakeSource) interface{} { return roundFloat(src.rng.Float64()*1000, 2) },
+	"string": fakeWord,
+	"sentence": func(src *fakeSource) interface{} { return fakeSentence(src, 5, 10) },
+	"lat": withFaker(faker.Latitude),
+	"lng": withFaker(faker.Longitude),
+	"ipv4": withFaker(faker.IPv4),
+	"url": withFaker(faker.URL),
+	"username": withFaker(faker.Username),
+	"password": withFaker(faker.Password),
+	"phone": withFaker(faker.Phonenumber),
+	"date": func(src *fakeSource) interface{} { return time.Unix(src.rng.Int63n(src.now.Unix()), 0).UTC().Format(defaultDateLayout) },
+	"timestamp": func(src *fakeSource) interface{} { return src.now.Unix() },
+}
+
+const defaultDateLayout = "2006-01-02"
+
+// parseFieldType turns a field type such as "int:18..65", "enum:a|b" or
+// "nullable(email, 0.2)" into a generator.
+func parseFieldType(spec string) (valueGenerator, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.HasPrefix(spec, "nullable(") && strings.HasSuffix(spec, ")") {
+		return parseNullable(strings.TrimSuffix(strings.TrimPrefix(spec, "nullable("), ")"))
+	}
+
+	name, args, hasArgs := strings.Cut(spec, ":")
+	if !hasArgs {
+		if fn, ok := fakeTypes[spec]; ok {
+			return fn, nil
+		}
+		return nil, fmt.Errorf("unknown type %q", spec)
+	}
+
+	switch name {
+	case "int":
+		low, high, err := parseIntRange(args)
+		if err != nil {
+			return nil, fmt.Errorf("invalid int type %q: %v", spec, err)
+		}
+		return func(src *fakeSource) interface{} { return src.intBetween(low, high) }, nil
+	case "float":
+		return parseFloatType(spec, args)
+	case "enum":
+		values := strings.Split(args, "|")
+		for _, value := range values {
+			if value == "" {
+				return nil, fmt.Errorf("invalid enum type %q: empty value", spec)
+			}
+		}
+		return func(src *fakeSource) interface{} { return values[src.rng.Intn(len(values))] }, nil
+	case "regex":
+		return parseRegexType(spec, args)
+	case "date":
+		return parseDateType(spec, args)
+	case "sentence":
+		low, high, err := parseIntRange(args)
+		if err != nil || low < 1 {
+			return nil, fmt.Errorf("invalid sentence type %q: expected a positive word count or range", spec)
+		}
+		return func(src *fakeSource) interface{} { return fakeSentence(src, low, high) }, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q", spec)
+	}
+}
+
+func parseNullable(args string) (valueGenerator, error) {
+	inner, probability := args, 0.5
+	if comma := strings.LastIndex(args, ","); comma != -1 {
+		parsed, err := strconv.ParseFloat(strings.TrimSpace(args[comma+1:]), 64)
+		if err == nil {
+			inner, probability = args[:comma], parsed
+		}
+	}
+	if probability < 0 || probability > 1 {
+		return nil, fmt.Errorf("invalid nullable probability %v: must be between 0 and 1", probability)
+	}
+
+	fn, err := parseFieldType(inner)
+	if err != nil {
+		return nil, err
+	}
+	return func(src *fakeSource) interface{} {
+		if src.rng.Float64() < probability {
+			return nil
+		}
+		return fn(src)
+	}, nil
+}
+
+// parseIntRange accepts "n" or "low..high".
+func parseIntRange(args string) (int, int, error) {
+	lowStr, highStr, isRange := strings.Cut(args, "..")
+	low, err := strconv.Atoi(strings.TrimSpace(lowStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid number %q", lowStr)
+	}
+	if !isRange {
+		return low, low, nil
+	}
+	high, err := strconv.Atoi(strings.TrimSpace(highStr))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid number %q", highStr)
+	}
+	if high < low {
+		return 0, 0, fmt.Errorf("range %d..%d is empty", low, high)
+	}
+	return low, high, nil
+}
+
+func parseFloatType(spec, args string) (valueGenerator, error) {
+	rangeStr, decimalsStr, hasDecimals := strings.Cut(args, ":")
+	lowStr, highStr, isRange := strings.Cut(rangeStr, "..")
+	if !isRange {
+		return nil, fmt.Errorf("invalid float type %q: expected low..high", spec)
+	}
+	low, errLow := strconv.ParseFloat(strings.TrimSpace(lowStr), 64)
+	high, errHigh := strconv.ParseFloat(strings.TrimSpace(highStr), 64)
+	if errLow != nil || errHigh != nil || high < low {
+		return nil, fmt.Errorf("invalid float type %q: bad range", spec)
+	}
+
+	decimals := 2
+	if hasDecimals {
+		parsed, err := strconv.Atoi(decimalsStr)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid float type %q: bad precision", spec)
+		}
+		decimals = parsed
+	}
+	return func(src *fakeSource) interface{} { return roundFloat(low+src.rng.Float64()*(high-low), decimals) }, nil
+}
+
+func parseDateType(spec, args string) (valueGenerator, error) {
+	rangeStr, layout, hasLayout := strings.Cut(args, ":")
+	if !hasLayout || layout == "" {
+		layout = defaultDateLayout
+	}
+	fromStr, toStr, isRange := strings.Cut(rangeStr, "..")
+	if !isRange {
+		return nil, fmt.Errorf("invalid date type %q: expected from..to", spec)
+	}
+	from, errFrom := time.Parse(defaultDateLayout, fromStr)
+	to, errTo := time.Parse(defaultDateLayout, toStr)
+	if errFrom != nil || errTo != nil || to.Before(from) {
+		return nil, fmt.Errorf("invalid date type %q: dates must be YYYY-MM-DD and in order", spec)
+	}
+
+	span := int64(to.Sub(from)/time.Second) + 1
+	return func(src *fakeSource) interface{} {
+		return from.Add(time.Duration(src.rng.Int63n(span)) * time.Second).Format(layout)
+	}, nil
+}
+
+func parseRegexType(spec, expr string) (valueGenerator, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex type %q: %v", spec, err)
+	}
+	re = re.Simplify()
+	return func(src *fakeSource) interface{} {
+		var b strings.Builder
+		writeRegexMatch(src, &b, re)
+		return b.String()
+	}, nil
+}
+
+const maxRegexRepeat = 5
+
+// writeRegexMatch appends a random string matching re. Unbounded
+// repetitions are capped and character classes prefer printable ASCII.
+func writeRegexMatch(src *fakeSource, b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		if len(re.Rune) >= 2 {
+			b.WriteRune(randomClassRune(src, re.Rune))
+		}
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune(' ' + 1 + src.rng.Intn('~'-' ')))
+	case syntax.OpCapture:
+		writeRegexMatch(src, b, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writeRegexMatch(src, b, sub)
+		}
+	case syntax.OpAlternate:
+		writeRegexMatch(src, b, re.Sub[src.rng.Intn(len(re.Sub))])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		low, high := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			low, high = 0, maxRegexRepeat
+		case syntax.OpPlus:
+			low, high = 1, maxRegexRepeat
+		case syntax.OpQuest:
+			low, high = 0, 1
+		}
+		if high < 0 {
+			high = low + maxRegexRepeat
+		}
+		for i := low + src.rng.Intn(high-low+1); i > 0; i-- {
+			writeRegexMatch(src, b, re.Sub[0])
+		}
+	}
+}
+
+func randomClassRune(src *fakeSource, ranges []rune) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		low, high := ranges[i], ranges[i+1]
+		if low < ' ' {
+			low = ' '
+		}
+		if high > '~' {
+			high = '~'
+		}
+		if low <= high {
+			printable = append(printable, low, high)
+		}
+	}
+	if len(printable) == 0 {
+		printable = ranges[:2]
+	}
+
+	total := 0
+	for i := 0; i < len(printable); i += 2 {
+		total += int(printable[i+1]-printable[i]) + 1
+	}
+	pick := src.rng.Intn(total)
+	for i := 0; i < len(printable); i += 2 {
+		size := int(printable[i+1]-printable[i]) + 1
+		if pick < size {
+			return printable[i] + rune(pick)
+		}
+		pick -= size
+	}
+	return printable[0]
+}
+
+func fakeSentence(src *fakeSource, minWords, maxWords int) string {
+	count := minWords + src.rng.Intn(maxWords-minWords+1)
+	words := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		words = append(words, fakeWord(src).(string))
+	}
+	sentence := strings.Join(words, " ")
+	return strings.ToUpper(sentence[:1]) + sentence[1:] + "."
+}
+
+func roundFloat(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
+
+// GenerateFakeData generates count records from a JSON data template such
+// as {"id": "uuid", "name": "name"}. A schema that is not a JSON object
+// yields records with an id, name and email.
+func GenerateFakeData(schema string, count int) ([]map[string]interface{}, error) {
+	return generateFakeDataWith(newFakeSource(nil), schema, count)
+}
+
+// generateFakeDataWith generates count records drawing every random value
+// from src.
+func generateFakeDataWith(src *fakeSource, schema string, count int) ([]map[string]interface{}, error) {
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(schema), &template); err != nil {
+		fake := make([]map[string]interface{}, 0, count)
+		for i := 0; i < count; i++ {
+			item := map[string]interface{}{
+				"id": src.uuid(),
+				"name": fakeTypes["name"](src),
+				"email": fakeTypes["email"](src),
+			}
+			fake = append(fake, item)
+		}
+		return fake,nil
+	}
+
+	// Unknown or malformed field types generate null values.
+	generate, _ := compileSchema(template, "")
+
+	result := make([]map[string]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		result = append(result, generate(src).(map[string]interface{}))
+	}
+
+	return result, nil
+}
+
+// requestSeed resolves the seed for a request: the ?seed= query parameter
+// wins over the endpoint (or global) seed. The seed is mixed with the method
+// and path so that /users/1 and /users/2 differ but repeat exactly.
+func requestSeed(r *http.Request, endpoint Endpoint) *int64 {
+	seed := endpoint.Seed
+	if seedStr := r.URL.Query().Get("seed"); seedStr != "" {
+		if parsed, err := strconv.ParseInt(seedStr, 10, 64); err == nil {
+			seed = &parsed
+		}
+	}
+	if seed == nil {
+		return nil
+	}
+
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d %s %s", *seed, r.Method, r.URL.Path)
+	mixed := int64(hash.Sum64())
+	return &mixed
+}
+
+const (
+	defaultArrayMin = 1
+	defaultArrayMax = 3
+)
+
+// compileSchema builds a generator for a schema node. Strings are field
+// types, objects nest, arrays repeat their first element, and an object
+// with an "$items" key describes an array with explicit "$min"/"$max" (or
+// "$count") lengths. Numbers, booleans and null are returned as literals.
+// Invalid types compile to null and the first problem is returned.
+func compileSchema(node interface{}, path string) (valueGenerator, error) {
+	switch spec := node.(type) {
+	case string:
+		if isTemplate(spec) {
+			return compileTemplate(spec, path)
+		}
+		fn, err := parseFieldType(spec)
+		if err != nil {
+			if path != "" {
+				err = fmt.Errorf("%s: %v", path, err)
+			}
+			return func(src *fakeSource) interface{} { return nil }, err
+		}
+		return fn, nil
+	case map[string]interface{}:
+		if items, isArray := spec["$items"]; isArray {
+			minLen, maxLen := arrayBounds(spec)
+			return compileArray(items, minLen, maxLen, path+"[]")
+		}
+		return compileObject(spec, path)
+	case []interface{}:
+		if len(spec) == 0 {
+			return func(src *fakeSource) interface{} { return []interface{}{} }, nil
+		}
+		return compileArray(spec[0], defaultArrayMin, defaultArrayMax, path+"[]")
+	default:
+		return func(src *fakeSource) interface{} { return spec }, nil
+	}
+}
+
+func compileObject(template map[string]interface{}, path string) (valueGenerator, error) {
+	keys := make([]string, 0, len(template))
+	for key := range template {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var firstErr error
+	fields := make([]valueGenerator, len(keys))
+	for i, key := range keys {
+		fieldPath := key
+		if path != "" {
+			fieldPath = path + "." + key
+		}
+		fn, err := compileSchema(template[key], fieldPath)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+		fields[i] = fn
+	}
+
+	return func(src *fakeSource) interface{} {
+		row := make(map[string]interface{}, len(keys))
+		for i, key := range keys {
+			row[key] = fields[i](src)
+		}
+		return row
+	}, firstErr
+}
+
+func arrayBounds(spec map[string]interface{}) (int, int) {
+	bound := func(key string, fallback int) int {
+		if value, ok := spec[key].(float64); ok && value >= 0 {
+			return int(value)
+		}
+		return fallback
+	}
+
+	if _, fixed := spec["$count"]; fixed {
+		count := bound("$count", defaultArrayMin)
+		return count, count
+	}
+	minLen := bound("$min", defaultArrayMin)
+	maxLen := bound("$max", defaultArrayMax)
+	if maxLen < minLen {
+		maxLen = minLen
+	}
+	return minLen, maxLen
+}
+
+func compileArray(items interface{}, minLen, maxLen int, path string) (valueGenerator, error) {
+	item, err := compileSchema(items, path)
+	return func(src *fakeSource) interface{} {
+		length := minLen + src.rng.Intn(maxLen-minLen+1)
+		result := make([]interface{}, 0, length)
+		for i := 0; i < length; i++ {
+			result = append(result, item(src))
+		}
+		return result
+	}, err
+}
@@ -0,0 +1,298 @@
+package mocker
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateFakeData(t *testing.T) {
+	tests := []struct {
+		name string
+		schema string
+		count int
+	}{
+		{
+			name: "valid JSON schema",
+			schema: `{"id": "uuid", "name": "name", "email": "email"}`,
+			count: 3,
+		},
+		{
+			name: "invalid JSON schema - fallbakc to faker",
+			schema: "invalid json",
+			count: 2,
+		},
+		{
+			name: "supported field types",
+			schema: `{"id": "uuid", "flag": "bool", "number": "int", "location": "lat"}`,
+			count: 1,
+		},
+		{
+			name: "unsupported field type",
+			schema: `{"id": "uuid", "unknown": "unsupported"}`,
+			count: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := GenerateFakeData(tt.schema, tt.count)
+			require.NoError(t, err)
+			assert.Len(t, data, tt.count)
+
+			if tt.count > 0 {
+				assert.IsType(t, []map[string]interface{}{}, data)
+				firstItem := data[0]
+				assert.NotEmpty(t, firstItem)
+			}
+		})
+	}
+}
+
+func BenchmarkGenerateFakeData(b *testing.B) {
+	schema := `{"id": "uuid", "name": "name", "email": "email", "active": "bool"}`
+
+	for i := 0; i < b.N; i++ {
+		_, err := GenerateFakeData(schema, 100)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGenerateFakeDataNested(t *testing.T) {
+	schema := `{
+		"id": "uuid",
+		"address": {"street": "string", "geo": {"lat": "lat", "lng": "lng"}},
+		"tags": ["string"],
+		"friends": {"$items": {"name": "name", "email": "email"}, "$min": 2, "$max": 4},
+		"scores": {"$items": "int", "$count": 5},
+		"empty": [],
+		"version": 2,
+		"active": true
+	}`
+
+	data, err := GenerateFakeData(schema, 3)
+	require.NoError(t, err)
+	require.Len(t, data, 3)
+
+	for _, item := range data {
+		address, ok := item["address"].(map[string]interface{})
+		require.True(t, ok)
+		assert.IsType(t, "", address["street"])
+		geo, ok := address["geo"].(map[string]interface{})
+		require.True(t, ok)
+		assert.IsType(t, float64(0), geo["lat"])
+
+		tags, ok := item["tags"].([]interface{})
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, len(tags), defaultArrayMin)
+		assert.LessOrEqual(t, len(tags), defaultArrayMax)
+		assert.IsType(t, "", tags[0])
+
+		friends, ok := item["friends"].([]interface{})
+		require.True(t, ok)
+		assert.GreaterOrEqual(t, len(friends), 2)
+		assert.LessOrEqual(t, len(friends), 4)
+		assert.Contains(t, friends[0], "email")
+
+		assert.Len(t, item["scores"], 5)
+		assert.Equal(t, []interface{}{}, item["empty"])
+		assert.Equal(t, float64(2), item["version"])
+		assert.Equal(t, true, item["active"])
+	}
+}
+
+func TestParseFieldType(t *testing.T) {
+	tests := []struct {
+		spec string
+		check func(*testing.T, interface{})
+	}{
+		{
+			spec: "int:18..65",
+			check: func(t *testing.T, value interface{}) {
+				n := value.(int)
+				assert.True(t, n >= 18 && n <= 65, "got %d", n)
+			},
+		},
+		{
+			spec: "int:-5..-1",
+			check: func(t *testing.T, value interface{}) {
+				n := value.(int)
+				assert.True(t, n >= -5 && n <= -1, "got %d", n)
+			},
+		},
+		{
+			spec: "int:-9223372036854775808..9223372036854775807",
+			check: func(t *testing.T, value interface{}) {
+				assert.IsType(t, 0, value)
+			},
+		},
+		{
+			spec: "int:-4611686018427387904..4611686018427387904",
+			check: func(t *testing.T, value interface{}) {
+				n := value.(int)
+				assert.True(t, n >= -4611686018427387904 && n <= 4611686018427387904, "got %d", n)
+			},
+		},
+		{
+			spec: "int:9223372036854775806..9223372036854775807",
+			check: func(t *testing.T, value interface{}) {
+				n := value.(int)
+				assert.True(t, n >= 9223372036854775806, "got %d", n)
+			},
+		},
+		{
+			spec: "float:0..1:2",
+			check: func(t *testing.T, value interface{}) {
+				f := value.(float64)
+				assert.True(t, f >= 0 && f <= 1, "got %v", f)
+				assert.Equal(t, roundFloat(f, 2), f)
+			},
+		},
+		{
+			spec: "enum:active|pending|banned",
+			check: func(t *testing.T, value interface{}) {
+				assert.Contains(t, []string{"active", "pending", "banned"}, value)
+			},
+		},
+		{
+			spec: `regex:[A-Z]{3}-\d{4}`,
+			check: func(t *testing.T, value interface{}) {
+				assert.Regexp(t, `^[A-Z]{3}-\d{4}$`, value)
+			},
+		},
+		{
+			spec: `regex:(foo|bar)+[^a-z]?x*`,
+			check: func(t *testing.T, value interface{}) {
+				assert.Regexp(t, `^(foo|bar)+[^a-z]?x*$`, value)
+			},
+		},
+		{
+			spec: "date:2020-01-01..2024-12-31:2006-01-02",
+			check: func(t *testing.T, value interface{}) {
+				date, err := time.Parse("2006-01-02", value.(string))
+				require.NoError(t, err)
+				assert.False(t, date.Before(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)))
+				assert.False(t, date.After(time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)))
+			},
+		},
+		{
+			spec: "date:2021-06-01..2021-06-01:02/01/2006 15:04",
+			check: func(t *testing.T, value interface{}) {
+				assert.Regexp(t, `^01/06/2021 \d\d:\d\d$`, value)
+			},
+		},
+		{
+			spec: "sentence:5..12",
+			check: func(t *testing.T, value interface{}) {
+				words := len(strings.Fields(value.(string)))
+				assert.True(t, words >= 5 && words <= 12, "got %d words", words)
+				assert.True(t, strings.HasSuffix(value.(string), "."))
+			},
+		},
+		{
+			spec: "nullable(email, 1)",
+			check: func(t *testing.T, value interface{}) {
+				assert.Nil(t, value)
+			},
+		},
+		{
+			spec: "nullable(int:1..3, 0)",
+			check: func(t *testing.T, value interface{}) {
+				assert.Contains(t, []int{1, 2, 3}, value)
+			},
+		},
+		{
+			spec: "nullable(regex:a{1,2}, 0)",
+			check: func(t *testing.T, value interface{}) {
+				assert.Regexp(t, `^a{1,2}$`, value)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			fn, err := parseFieldType(tt.spec)
+			require.NoError(t, err)
+			src := newFakeSource(nil)
+			for i := 0; i < 20; i++ {
+				tt.check(t, fn(src))
+			}
+		})
+	}
+}
+
+func TestParseFieldTypeErrors(t *testing.T) {
+	specs := []string{
+		"unknown",
+		"int:abc",
+		"int:10..1",
+		"float:1",
+		"enum:a||b",
+		"regex:[a-",
+		"date:2024-01-01",
+		"date:2024-12-31..2024-01-01",
+		"sentence:0",
+		"nullable(email, 2)",
+		"nullable(bogus, 0.5)",
+		"color:red",
+	}
+
+	for _, spec := range specs {
+		t.Run(spec, func(t *testing.T) {
+			_, err := parseFieldType(spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestCompileSchemaReportsFieldPath(t *testing.T) {
+	var template map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"id": "uuid", "address": {"zip": "int:9..1"}}`), &template))
+
+	generate, err := compileSchema(template, "")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "address.zip")
+
+	row := generate(newFakeSource(nil)).(map[string]interface{})
+	assert.Nil(t, row["address"].(map[string]interface{})["zip"])
+	assert.NotNil(t, row["id"])
+}
+
+func TestSeededGeneration(t *testing.T) {
+	schema := `{"id": "uuid", "name": "name", "email": "email", "created": "timestamp", "born": "date", "bio": "sentence", "code": "regex:[A-Z]{3}", "tags": ["string"]}`
+	seed := int64(42)
+
+	first, err := generateFakeDataWith(newFakeSource(&seed), schema, 5)
+	require.NoError(t, err)
+	second, err := generateFakeDataWith(newFakeSource(&seed), schema, 5)
+	require.NoError(t, err)
+	assert.Equal(t, first, second)
+	assert.Equal(t, seededNow.Unix(), first[0]["created"])
+
+	other := int64(43)
+	third, err := generateFakeDataWith(newFakeSource(&other), schema, 5)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, third)
+
+	// Sources share no random state, so concurrent generation repeats too.
+	var wg sync.WaitGroup
+	results := make([][]map[string]interface{}, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = generateFakeDataWith(newFakeSource(&seed), schema, 5)
+		}(i)
+	}
+	wg.Wait()
+	for _, result := range results {
+		assert.Equal(t, first, result)
+	}
+}
@@ -0,0 +1,622 @@
+package mocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// shouldTriggerError rolls each configured error in order. The roll never
+// uses the endpoint's seed, so error_probability stays a probability for
+// seeded endpoints too.
+func shouldTriggerError(errors []ErrorConfig) (bool, ErrorConfig) {
+	if len(errors) == 0 {
+		return false, ErrorConfig{}
+	}
+
+	for _, errorConfig := range errors {
+		if rand.Float64() < errorConfig.Probability {
+			return true, errorConfig
+		}
+	}
+
+	return false, ErrorConfig{}
+}
+
+// ApplyQueryFilters applies the list query parameters to data: filter
+// (field:value, a case-insensitive substring match), sort and order (asc or
+// desc, comparing values as strings), offset, and count or its alias limit.
+func ApplyQueryFilters(data []map[string]interface{}, params url.Values) []map[string]interface{} {
+	result := data
+
+	if filter := params.Get("filter"); filter != "" {
+		parts := strings.SplitN(filter, ":", 2)
+		if len(parts) == 2 {
+			field := parts[0]
+			value := parts[1]
+			var filtered []map[string]interface{}
+			for _, item := range result {
+				if itemValue, exists := item[field]; exists {
+					itemStr := fmt.Sprintf("%v", itemValue)
+					if strings.Contains(strings.ToLower(itemStr), strings.ToLower(value)) {
+						filtered = append(filtered, item)
+					}
+				}
+			}
+			result = filtered
+		}
+	}
+
+	if sortField := params.Get("sort"); sortField != "" {
+		order := params.Get("order")
+		if order == "" {
+			order = "asc"
+		}
+
+		sort.Slice(result, func(i, j int) bool {
+			val1, exists1 := result[i][sortField]
+			val2, exists2 := result[j][sortField]
+			
+			if !exists1 && !exists2 {
+				return false
+			}
+			if !exists1 {
+				return order == "desc"
+			}
+			if !exists2 {
+				return order == "asc"
+			}
+
+			str1 := fmt.Sprintf("%v", val1)
+			str2 := fmt.Sprintf("%v", val2)
+
+			if order == "desc" {
+				return str1 > str2
+			}
+			return str1 < str2
+		})
+	}
+
+	offset := 0
+	if offsetStr := params.Get("offset"); offsetStr != "" {
+		if parsedOffset, err := strconv.Atoi(offsetStr); err == nil && parsedOffset >= 0 {
+			offset = parsedOffset
+		}
+	}
+
+	if offset >= len(result) {
+		return []map[string]interface{}{}
+	}
+	if offset > 0 {
+		result = result[offset:]
+	}
+
+	count := len(result)
+	if countStr := params.Get("count"); countStr != "" {
+		if parsedCount, err := strconv.Atoi(countStr); err == nil && parsedCount > 0 {
+			count = parsedCount
+		} 
+	} else if limitStr := params.Get("limit"); limitStr != "" {
+		if parsedCount, err := strconv.Atoi(limitStr); err == nil && parsedCount > 0 {
+			count = parsedCount
+		}
+	}
+
+	if count < len(result){
+		result = result[:count]
+	}
+
+	return result
+}
+
+// applyPathParams copies path parameters into the generated fields of the
+// same name, converted to the type the schema generated for them. Fields
+// whose value does not convert are left alone.
+func applyPathParams(data []map[string]interface{}, params map[string]string) {
+	if len(params) == 0 {
+		return
+	}
+
+	for _, item := range data {
+		for name, value := range params {
+			current, exists := item[name]
+			if !exists {
+				continue
+			}
+			if converted, ok := convertParam(value, current); ok {
+				item[name] = converted
+			}
+		}
+	}
+}
+
+// convertParam parses value as the type of like.
+func convertParam(value string, like interface{}) (interface{}, bool) {
+	switch like.(type) {
+	case nil, string:
+		return value, true
+	case int:
+		n, err := strconv.Atoi(value)
+		return n, err == nil
+	case int64:
+		n, err := strconv.ParseInt(value, 10, 64)
+		return n, err == nil
+	case float64:
+		f, err := strconv.ParseFloat(value, 64)
+		return f, err == nil
+	case bool:
+		b, err := strconv.ParseBool(value)
+		return b, err == nil
+	case json.Number:
+		var number json.Number
+		err := json.Unmarshal([]byte(value), &number)
+		return number, err == nil && value != "" && value[0] != '"'
+	}
+	return nil, false
+}
+
+func serveFileHandler(path string, endpoint Endpoint, logger *Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		statusCode := endpoint.Status
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+
+		authSuccess, authType, authResult := authenticateRequest(r, endpoint.Auth)
+		if !authSuccess {
+			statusCode = http.StatusUnauthorized
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statusCode)
+			errorResponse := map[string]string{
+				"error": "Authentication required",
+			}
+			data, _ := json.Marshal(errorResponse)
+			w.Write(data)
+
+			duration := time.Since(start)
+			reqLog := RequestLog{
+				Timestamp: start.Format(time.RFC3339),
+				Method: r.Method,
+				Protocol: r.Proto,
+				Path: r.URL.Path,
+				Query: r.URL.RawQuery,
+				StatusCode: statusCode,
+				ResponseTime: duration.String(),
+				UserAgent: r.Header.Get("User-Agent"),
+				RemoteAddr: r.RemoteAddr,
+				ContentLength: int64(len(data)),
+				AuthType: authType,
+				AuthResult: authResult,
+				PathParams: pathParams(r),
+			}
+			logger.LogRequest(reqLog)
+			return
+		}
+
+		writeFile(w, r, path, endpoint.Headers, statusCode, start, authType, authResult, logger)
+	}
+}
+
+// writeHeaders adds configured response headers to h. A value with several
+// lines is sent as one header per line, which is how repeated headers such
+// as Set-Cookie are configured.
+func writeHeaders(h http.Header, headers map[string]string) {
+	for key, value := range headers {
+		if !strings.Contains(value, "\n") {
+			h.Add(key, value)
+			continue
+		}
+		for _, line := range strings.Split(value, "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				h.Add(key, line)
+			}
+		}
+	}
+}
+
+// writeFile answers r with the file at path, headers and statusCode, once
+// the request has been authenticated. A 200 is served with
+// http.ServeFile, which also handles ranges and conditional requests.
+func writeFile(w http.ResponseWriter, r *http.Request, path string, headers map[string]string, statusCode int, start time.Time, authType, authResult string, logger *Logger) {
+	writeHeaders(w.Header(), headers)
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", fileContentType(path))
+	}
+
+	contentLength := int64(0)
+	if statusCode == http.StatusOK {
+		http.ServeFile(w, r, path)
+		if fileInfo, err := os.Stat(path); err == nil {
+			contentLength = fileInfo.Size()
+		} else {
+			statusCode = http.StatusNotFound
+		}
+	} else if file, err := os.Open(path); err != nil {
+		statusCode = http.StatusNotFound
+		http.Error(w, "404 page not found", statusCode)
+	} else {
+		defer file.Close()
+		w.WriteHeader(statusCode)
+		contentLength, _ = io.Copy(w, file)
+	}
+
+	duration := time.Since(start)
+	reqLog := RequestLog{
+		Timestamp: start.Format(time.RFC3339),
+		Method: r.Method,
+		Protocol: r.Proto,
+		Path: r.URL.Path,
+		Query: r.URL.RawQuery,
+		StatusCode: statusCode,
+		ResponseTime: duration.String(),
+		UserAgent: r.Header.Get("User-Agent"),
+		RemoteAddr: r.RemoteAddr,
+		ContentLength: contentLength,
+		AuthType: authType,
+		AuthResult: authResult,
+		PathParams: pathParams(r),
+	}
+	logger.LogRequest(reqLog)
+}
+
+// loadBody reads an endpoint's literal body or body file and, if the
+// endpoint is a template, renders it. Body files are read on every request,
+// so fixture edits show up without a reload.
+func loadBody(endpoint Endpoint, src *fakeSource) ([]byte, error) {
+	var content []byte
+	if endpoint.Body != nil {
+		content = []byte(*endpoint.Body)
+	} else {
+		var err error
+		if content, err = os.ReadFile(endpoint.BodyFile); err != nil {
+			return nil, err
+		}
+	}
+	if !endpoint.Template {
+		return content, nil
+	}
+	return renderBody(string(content), src)
+}
+
+// jsonRecords decodes body as a JSON array of objects. Numbers keep their
+// original text.
+func jsonRecords(body []byte) ([]map[string]interface{}, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var items []interface{}
+	if err := decoder.Decode(&items); err != nil || decoder.More() {
+		return nil, false
+	}
+
+	records := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		record, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		records = append(records, record)
+	}
+	return records, true
+}
+
+// bodyContentType picks a Content-Type for a literal body.
+func bodyContentType(body []byte) string {
+	if json.Valid(body) {
+		return "application/json"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+func createLoggingHandler(endpoint Endpoint, logger *Logger) http.HandlerFunc {
+	return createEndpointHandler(endpoint, newSequenceCounter(endpoint.Sequence), logger)
+}
+
+// createEndpointHandler serves endpoint, tracking its sequence in sequence.
+func createEndpointHandler(endpoint Endpoint, sequence *sequenceCounter, logger *Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		statusCode := endpoint.Status
+
+		if r.Method != endpoint.Method && !(r.Method == http.MethodHead && endpoint.Method == http.MethodGet) {
+			statusCode = http.StatusMethodNotAllowed
+			http.Error(w, "Method Not Allowed", statusCode)
+
+			duration := time.Since(start)
+			reqLog := RequestLog{
+				Timestamp: start.Format(time.RFC3339),
+				Method: r.Method,
+				Protocol: r.Proto,
+				Path: r.URL.Path,
+				Query: r.URL.RawQuery,
+				StatusCode: statusCode,
+				ResponseTime: duration.String(),
+				UserAgent: r.Header.Get("User-Agent"),
+				RemoteAddr: r.RemoteAddr,
+				ContentLength: 0,
+				PathParams: pathParams(r),
+			}
+			logger.LogRequest(reqLog)
+			return
+		}
+
+		authSuccess, authType, authResult := authenticateRequest(r, endpoint.Auth)
+		if !authSuccess {
+			statusCode = http.StatusUnauthorized
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statusCode)
+			errorResponse := map[string]string{
+				"error": "Authentication required",
+			}
+
+			data, _ := json.Marshal(errorResponse)
+			contentLength := int64(len(data))
+			w.Write(data)
+
+			duration := time.Since(start)
+			reqLog := RequestLog{
+				Timestamp: start.Format(time.RFC3339),
+				Method: r.Method,
+				Protocol: r.Proto,
+				Path: r.URL.Path,
+				Query: r.URL.RawQuery,
+				StatusCode: statusCode,
+				ResponseTime: duration.String(),
+				UserAgent: r.Header.Get("User-Agent"),
+				RemoteAddr: r.RemoteAddr,
+				ContentLength: contentLength,
+				AuthType: authType,
+				AuthResult: authResult,
+				PathParams: pathParams(r),
+			}
+			logger.LogRequest(reqLog)
+			return
+		}
+
+		// From here on the matching response rule, if any, stands in for
+		// the endpoint.
+		endpoint, ok := selectResponse(endpoint, r, sequence)
+		unavailable := func() {
+			statusCode = http.StatusNotFound
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statusCode)
+			data, _ := json.Marshal(map[string]string{
+				"error": fmt.Sprintf("Not available in state %q of scenario %q", scenarios(r).State(endpoint.Scenario), endpoint.Scenario),
+			})
+			w.Write(data)
+
+			duration := time.Since(start)
+			reqLog := RequestLog{
+				Timestamp: start.Format(time.RFC3339),
+				Method: r.Method,
+				Protocol: r.Proto,
+				Path: r.URL.Path,
+				Query: r.URL.RawQuery,
+				StatusCode: statusCode,
+				ResponseTime: duration.String(),
+				UserAgent: r.Header.Get("User-Agent"),
+				RemoteAddr: r.RemoteAddr,
+				ContentLength: int64(len(data)),
+				AuthType: authType,
+				AuthResult: authResult,
+				PathParams: pathParams(r),
+			}
+			logger.LogRequest(reqLog)
+		}
+		if !ok {
+			unavailable()
+			return
+		}
+		statusCode = endpoint.Status
+		
+		if endpoint.Delay != "" {
+			delay := parseDuration(endpoint.Delay)
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+
+		src := newFakeSource(requestSeed(r, endpoint))
+		src.request = newTemplateRequest(r)
+		if shouldError, errorConfig := shouldTriggerError(endpoint.Errors); shouldError {
+			var contentLength int64
+			statusCode = errorConfig.Status
+			if errorConfig.Message != "" {
+				w.Header().Set("Content-Type", "application/json")
+				errorResponse := map[string]string{
+					"error": errorConfig.Message,
+				}
+				data, _ := json.Marshal(errorResponse)
+				contentLength = int64(len(data))
+				w.WriteHeader(statusCode)
+				w.Write(data)
+			} else {
+				contentLength = 0
+				w.WriteHeader(statusCode)
+			}
+
+			duration := time.Since(start)
+			reqLog := RequestLog{
+				Timestamp: start.Format(time.RFC3339),
+				Method: r.Method,
+				Protocol: r.Proto,
+				Path: r.URL.Path,
+				Query: r.URL.RawQuery,
+				StatusCode: statusCode,
+				ResponseTime: duration.String(),
+				UserAgent: r.Header.Get("User-Agent"),
+				RemoteAddr: r.RemoteAddr,
+				ContentLength: contentLength,
+				AuthType: authType,
+				AuthResult: authResult,
+				PathParams: pathParams(r),
+			}
+			logger.LogRequest(reqLog)
+			return
+		}
+
+		if endpoint.Scenario != "" && endpoint.NextState != "" && !scenarios(r).Transition(endpoint.Scenario, endpoint.State, endpoint.NextState) {
+			// A concurrent request moved the scenario on first.
+			unavailable()
+			return
+		}
+
+		if endpoint.File != "" {
+			writeFile(w, r, endpoint.File, endpoint.Headers, statusCode, start, authType, authResult, logger)
+			return
+		}
+
+		writeHeaders(w.Header(), endpoint.Headers)
+
+		params := r.URL.Query()
+		var data []map[string]interface{}
+
+		if endpoint.Body != nil || endpoint.BodyFile != "" {
+			body, err := loadBody(endpoint, src)
+			if err != nil {
+				statusCode = http.StatusInternalServerError
+				body, _ = json.Marshal(map[string]string{
+					"error": fmt.Sprintf("Failed to load body: %v", err),
+				})
+				w.Header().Set("Content-Type", "application/json")
+			}
+
+			// JSON arrays of objects are filtered like generated data,
+			// anything else is sent as is.
+			records, isList := jsonRecords(body)
+			if err != nil || !isList {
+				if w.Header().Get("Content-Type") == "" {
+					w.Header().Set("Content-Type", bodyContentType(body))
+				}
+				w.WriteHeader(statusCode)
+				w.Write(body)
+
+				duration := time.Since(start)
+				reqLog := RequestLog{
+					Timestamp: start.Format(time.RFC3339),
+					Method: r.Method,
+					Protocol: r.Proto,
+					Path: r.URL.Path,
+					Query: r.URL.RawQuery,
+					StatusCode: statusCode,
+					ResponseTime: duration.String(),
+					UserAgent: r.Header.Get("User-Agent"),
+					RemoteAddr: r.RemoteAddr,
+					ContentLength: int64(len(body)),
+					AuthType: authType,
+					AuthResult: authResult,
+					PathParams: pathParams(r),
+				}
+				logger.LogRequest(reqLog)
+				return
+			}
+			data = records
+		} else {
+			count := endpoint.Count
+			if countStr := params.Get("count"); countStr != "" {
+				if parsedCount, err := strconv.Atoi(countStr); err == nil && parsedCount > 0 {
+					count = parsedCount
+				}
+			} else if limitStr := params.Get("limit"); limitStr != "" {
+				if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
+					count = parsedLimit
+				}
+			}
+
+			generateCount := count
+			if endpoint.Single {
+				generateCount = 1
+			}
+
+			generated, err := generateFakeDataWith(src, endpoint.Data, generateCount)
+			if err != nil {
+				w.Header().Set("Content-Type","application/json")
+				statusCode = http.StatusInternalServerError
+				w.WriteHeader(statusCode)
+				errorResponse := map[string]string{
+					"error": "Failed to generate data",
+				}
+				responseData, _ := json.Marshal(errorResponse)
+				w.Write(responseData)
+			
+				duration := time.Since(start)
+				reqLog := RequestLog{
+					Timestamp: start.Format(time.RFC3339),
+					Method: r.Method,
+					Protocol: r.Proto,
+					Path: r.URL.Path,
+					Query: r.URL.RawQuery,
+					StatusCode: statusCode,
+					ResponseTime: duration.String(),
+					UserAgent: r.Header.Get("User-Agent"),
+					RemoteAddr: r.RemoteAddr,
+					ContentLength: int64(len(responseData)),
+					AuthType: authType,
+					AuthResult: authResult,
+					PathParams: pathParams(r),
+				}
+				logger.LogRequest(reqLog)
+				return
+			}
+
+			data = generated
+			applyPathParams(data, pathParams(r))
+		}
+
+		var responseData []byte
+		filteredData := ApplyQueryFilters(data, params)
+		if endpoint.Single && len(data) > 0 {
+			responseData, _ = json.Marshal(data[0])
+		} else if params.Get("meta") == "true" {
+			response := map[string]interface{}{
+				"data": filteredData,
+				"meta": map[string]interface{}{
+					"count": len(filteredData),
+					"total": len(data),
+					"offset": params.Get("offset"),
+					"limit": params.Get("count"),
+					"sort": params.Get("sort"),
+					"order": params.Get("order"),
+					"filter": params.Get("filter"),
+					"status": endpoint.Status,
+				},
+			}
+			responseData, _ = json.Marshal(response)
+		} else {
+			responseData, _ = json.Marshal(filteredData)
+		}
+		
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", "application/json")
+		}
+		w.WriteHeader(statusCode)
+		w.Write(responseData)
+
+		duration := time.Since(start)
+		reqLog := RequestLog{
+			Timestamp: start.Format(time.RFC3339),
+			Method: r.Method,
+			Protocol: r.Proto,
+			Path: r.URL.Path,
+			Query: r.URL.RawQuery,
+			StatusCode: statusCode,
+			ResponseTime: duration.String(),
+			UserAgent: r.Header.Get("User-Agent"),
+			RemoteAddr: r.RemoteAddr,
+			ContentLength: int64(len(responseData)),
+			AuthType: authType,
+			AuthResult: authResult,
+			PathParams: pathParams(r),
+		}
+		logger.LogRequest(reqLog)
+	}
+}
@@ -0,0 +1,615 @@
+package mocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShouldTriggerError(t *testing.T) {
+	tests := []struct {
+		name string
+		errors []ErrorConfig
+		runs int
+	}{
+		{
+			name: "no errors configured",
+			errors: []ErrorConfig{},
+			runs: 10,
+		},
+		{
+			name: "100% probability error",
+			errors: []ErrorConfig{
+				{Probability: 1.0, Status: 500, Message: "Always fails"},
+			},
+			runs: 5,
+		},
+		{
+			name: "0% probability error",
+			errors: []ErrorConfig{
+				{Probability: 0.0, Status: 500, Message: "Never fails"},
+			},
+			runs: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errorCount := 0
+			for i := 0; i < tt.runs; i++ {
+				triggered, _ := shouldTriggerError(tt.errors)
+				if triggered {
+					errorCount++
+				}
+			}
+
+			if len(tt.errors) == 0 {
+				assert.Equal(t, 0, errorCount, "No error should be triggered")
+			} else if tt.errors[0].Probability == 1.0 {
+				assert.Equal(t, tt.runs, errorCount, "All requests should trigger error")
+			} else if tt.errors[0].Probability == 0.0 {
+				assert.Equal(t, 0, errorCount, "No requests should trigger error")
+			}
+		})
+	}
+}
+
+func TestApplyQueryFilters(t *testing.T) {
+	testData := []map[string]interface{}{
+		{"id": 1, "name": "Alice", "age": 30},
+		{"id": 2, "name": "Bob", "age": 25},
+		{"id": 3, "name": "Charlie", "age": 35},
+		{"id": 4, "name": "Alice", "age": 28},
+	}
+
+	tests := []struct{
+		name string
+		params map[string]string
+		expected int
+	}{
+		{
+			name: "no filters",
+			params: map[string]string{},
+			expected: 4,
+		},
+		{
+			name: "filter by name",
+			params: map[string]string{"filter": "name:Alice"},
+			expected: 2,
+		},
+		{
+			name: "limit count",
+			params: map[string]string{"count": "2"},
+			expected: 2,
+		},
+		{
+			name: "limit with alias",
+			params: map[string]string{"limit": "3"},
+			expected: 3,
+		},
+		{
+			name: "offset",
+			params: map[string]string{"offset": "1", "count": "2"},
+			expected: 2,
+		},
+		{
+			name: "sort ascending",
+			params: map[string]string{"sort": "age", "order": "asc"},
+			expected: 4,
+		},
+		{
+			name: "sort descending",
+			params: map[string]string{"sort": "age", "order": "desc"},
+			expected: 4,
+		},
+		{
+			name: "offset beyond data",
+			params: map[string]string{"offset": "10"},
+			expected: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values := url.Values{}
+			for k, v := range tt.params {
+				values.Set(k, v)
+			}
+
+			result := ApplyQueryFilters(testData, values)
+			assert.Len(t, result, tt.expected)
+
+			if tt.params["sort"] == "age" {
+				if len(result) >= 2 {
+					if tt.params["order"] == "desc" {
+						assert.True(t, result[0]["age"].(int) >= result[1]["age"].(int))
+					} else {
+						assert.True(t, result[0]["age"].(int) <= result[1]["age"].(int))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCreateLoggingHandler(t *testing.T) {
+	logger := &Logger{writer: io.Discard, format: "json"}
+
+	tests := []struct {
+		name string
+		endpoint Endpoint
+		method string
+		path string
+		authHeader string
+		expectedStatus int
+	}{
+		{
+			name: "successful request",
+			endpoint: Endpoint{
+				Path: "/test",
+				Method: "GET",
+				Status: 200,
+				Data: `{"id": "uuid"}`,
+				Count: 1,
+			},
+			method: "GET",
+			path: "/test",
+			expectedStatus: 200,
+		},
+		{
+			name: "method not allowed",
+			endpoint: Endpoint{
+				Path: "/test",
+				Method: "POST",
+				Status: 200,
+			},
+			method: "GET",
+			path: "/test",
+			expectedStatus: 405,
+		},
+		{
+			name: "unauthorized request",
+			endpoint: Endpoint{
+				Path: "/secure",
+				Method: "GET",
+				Status: 200,
+				Auth: &AuthConfig{
+					Type: "bearer",
+					Token: "secret",
+				},
+			},
+			method: "GET",
+			path: "/secure",
+			expectedStatus: 401,
+		},
+		{
+			name: "authorized request",
+			endpoint: Endpoint{
+				Path: "/secure",
+				Method: "GET",
+				Status: 200,
+				Data: `{"message": "success"}`,
+				Auth: &AuthConfig{
+					Type: "bearer",
+					Token: "secret",
+				},
+			},
+			method: "GET",
+			path: "/secure",
+			authHeader: "Bearer secret",
+			expectedStatus: 200,
+		},
+		{
+			name: "request with delay",
+			endpoint: Endpoint{
+				Path: "/slow",
+				Method: "GET",
+				Status: 200,
+				Data: `{"slow": true}`,
+				Delay: "10ms",
+			},
+			method: "GET",
+			path: "/slow",
+			expectedStatus: 200,
+		},
+		{
+			name: "request with custom headers",
+			endpoint: Endpoint{
+				Path: "/headers",
+				Method: "GET",
+				Status: 200,
+				Data: `{"test": true}`,
+				Headers: map[string]string{
+					"X-Custom-Header": "test-value",
+					"X-API-Version": "v1",
+				},
+			},
+			method: "GET",
+			path: "/headers",
+			expectedStatus: 200,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := createLoggingHandler(tt.endpoint, logger)
+
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.authHeader != "" {
+				req.Header.Set("Authorization", tt.authHeader)
+			}
+
+			rr := httptest.NewRecorder()
+			handler(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+
+			if tt.expectedStatus == 200 {
+				for key, expectedValue := range tt.endpoint.Headers {
+					assert.Equal(t, expectedValue, rr.Header().Get(key))
+				}
+
+				if tt.endpoint.Data != "" {
+					assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+				}
+			}
+		})
+	}
+}
+
+func TestCreateLoggingHandlerWithQueryParams(t *testing.T) {
+	logger := &Logger{writer: io.Discard, format: "json"}
+	endpoint := Endpoint{
+		Path: "/users",
+		Method: "GET",
+		Status: 200,
+		Data: `{"id": "uuid", "name": "name", "email": "email"}`,
+		Count: 5,
+	}
+
+	handler := createLoggingHandler(endpoint, logger)
+
+	tests := []struct{
+		name string
+		queryParams string
+		checkFunc func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			name: "count parameter",
+			queryParams: "?count=3",
+			checkFunc: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				var data []map[string]interface{}
+				err := json.Unmarshal(rr.Body.Bytes(), &data)
+				require.NoError(t, err)
+				assert.Len(t, data, 3)
+			},
+		},
+		{
+			name: "limit parameter",
+			queryParams: "?limit=2",
+			checkFunc: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				var data []map[string]interface{}
+				err := json.Unmarshal(rr.Body.Bytes(), &data)
+				require.NoError(t, err)
+				assert.Len(t, data, 2)
+			},
+		},
+		{
+			name: "meta parameter",
+			queryParams: "?meta=true&count=2",
+			checkFunc: func(t *testing.T, rr *httptest.ResponseRecorder) {
+				var response map[string]interface{}
+				err := json.Unmarshal(rr.Body.Bytes(), &response)
+				require.NoError(t, err)
+				
+				assert.Contains(t, response, "data")
+				assert.Contains(t, response, "meta")
+				
+				meta := response["meta"].(map[string]interface{})
+				assert.Contains(t, meta, "count")
+				assert.Contains(t, meta, "total")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/users"+tt.queryParams, nil)
+			rr := httptest.NewRecorder()
+
+			handler(rr, req)
+
+			assert.Equal(t, 200, rr.Code)
+			tt.checkFunc(t, rr)
+		})
+	}
+}
+
+func TestServeFileHandler(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test.txt")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	testContent := "Hello, World!"
+	_, err = tmpFile.WriteString(testContent)
+	require.NoError(t, err)
+	tmpFile.Close()
+
+	logger := &Logger{writer: io.Discard, format: "json"}
+	endpoint := Endpoint{
+		Path: "/file",
+		File: tmpFile.Name(),
+	}
+	
+	handler := serveFileHandler(tmpFile.Name(), endpoint, logger)
+
+	req := httptest.NewRequest("GET", "/file", nil)
+	rr := httptest.NewRecorder()
+
+	handler(rr, req)
+
+	assert.Equal(t, 200, rr.Code)
+	assert.Contains(t, rr.Body.String(), testContent)
+}
+
+func TestServeFileHandlerWithAuth(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test.txt")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+
+	_, err = tmpFile.WriteString("Secret content")
+	require.NoError(t, err)
+	tmpFile.Close()
+
+	logger := &Logger{writer: io.Discard, format: "json"}
+	endpoint := Endpoint{
+		Path: "/secure-file",
+		File: tmpFile.Name(),
+		Auth: &AuthConfig{
+			Type: "bearer",
+			Token: "file-token",
+		},
+	}
+
+	handler := serveFileHandler(tmpFile.Name(), endpoint, logger)
+
+	req := httptest.NewRequest("GET", "/secure-file", nil)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	assert.Equal(t, 401, rr.Code)
+
+	req = httptest.NewRequest("GET", "/secure-file", nil)
+	req.Header.Set("Authorization", "Bearer file-token")
+	rr = httptest.NewRecorder()
+	handler(rr, req)
+	assert.Equal(t, 200, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Secret content")
+}
+
+func BenchmarkApplyQueryFilters(b *testing.B) {
+	testData := make([]map[string]interface{}, 1000)
+	for i := 0; i < 1000; i++ {
+		testData[i] = map[string]interface{}{
+			"id": i,
+			"name": fmt.Sprintf("User%d", i),
+			"age": 20 + (i % 50),
+		}
+	}
+
+	params := url.Values{}
+	params.Set("filter", "name:User1")
+	params.Set("soft", "age")
+	params.Set("order", "desc")
+	params.Set("count", "50")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ApplyQueryFilters(testData, params)
+	}
+}
+
+func TestCreateLoggingHandlerWithPathParams(t *testing.T) {
+	var logs bytes.Buffer
+	logger := &Logger{writer: &logs, format: "json"}
+	endpoint := Endpoint{
+		Path: "/users/{id}",
+		Method: "GET",
+		Status: 200,
+		Data: `{"id": "uuid", "name": "name"}`,
+		Count: 1,
+	}
+
+	router := NewRouter(nil)
+	require.NoError(t, router.Handle(endpoint.Path, endpoint.Method, createLoggingHandler(endpoint, logger)))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/users/42", nil))
+	require.Equal(t, 200, rr.Code)
+
+	var data []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &data))
+	require.Len(t, data, 1)
+	assert.Equal(t, "42", data[0]["id"])
+
+	var reqLog RequestLog
+	require.NoError(t, json.Unmarshal(logs.Bytes(), &reqLog))
+	assert.Equal(t, map[string]string{"id": "42"}, reqLog.PathParams)
+}
+
+func TestCreateLoggingHandlerSingle(t *testing.T) {
+	logger := &Logger{writer: io.Discard, format: "json"}
+	endpoint := Endpoint{
+		Path: "/profile",
+		Method: "GET",
+		Status: 200,
+		Data: `{"id": "uuid", "address": {"city": "string"}}`,
+		Count: 5,
+		Single: true,
+	}
+
+	rr := httptest.NewRecorder()
+	createLoggingHandler(endpoint, logger)(rr, httptest.NewRequest("GET", "/profile?count=3", nil))
+	require.Equal(t, 200, rr.Code)
+
+	var item map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &item))
+	assert.Contains(t, item, "id")
+	assert.Contains(t, item["address"], "city")
+}
+
+func TestCreateLoggingHandlerSeeded(t *testing.T) {
+	logger := &Logger{writer: io.Discard, format: "json"}
+	seed := int64(7)
+	endpoint := Endpoint{
+		Path: "/users/{id}",
+		Method: "GET",
+		Status: 200,
+		Data: `{"id": "uuid", "name": "name", "email": "email", "age": "int:18..65"}`,
+		Count: 3,
+		Seed: &seed,
+	}
+
+	router := NewRouter(logger)
+	require.NoError(t, router.Handle(endpoint.Path, endpoint.Method, createLoggingHandler(endpoint, logger)))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	for _, target := range []string{"/users/1", "/users/2?sort=name", "/users/3?seed=99"} {
+		first, second := get(target), get(target)
+		assert.Equal(t, first.Code, second.Code, target)
+		assert.Equal(t, first.Body.String(), second.Body.String(), target)
+	}
+
+	assert.NotEqual(t, get("/users/1").Body.String(), get("/users/2").Body.String())
+	assert.NotEqual(t, get("/users/1?seed=1").Body.String(), get("/users/1?seed=2").Body.String())
+
+	unseeded := endpoint
+	unseeded.Seed = nil
+	handler := createLoggingHandler(unseeded, logger)
+	rr1, rr2 := httptest.NewRecorder(), httptest.NewRecorder()
+	handler(rr1, httptest.NewRequest("GET", "/users/1", nil))
+	handler(rr2, httptest.NewRequest("GET", "/users/1", nil))
+	assert.NotEqual(t, rr1.Body.String(), rr2.Body.String())
+
+	// Errors are rolled apart from the seed, so a seeded endpoint still
+	// fails only some of the time.
+	flaky := endpoint
+	flaky.Errors = []ErrorConfig{{Probability: 0.5, Status: 500}}
+	handler = createLoggingHandler(flaky, logger)
+	codes := map[int]int{}
+	for i := 0; i < 100; i++ {
+		rr := httptest.NewRecorder()
+		handler(rr, httptest.NewRequest("GET", "/users/1", nil))
+		codes[rr.Code]++
+	}
+	assert.Positive(t, codes[200])
+	assert.Positive(t, codes[500])
+}
+
+func TestCreateLoggingHandlerBody(t *testing.T) {
+	body := "pong"
+	endpoint := Endpoint{Path: "/ping", Method: "GET", Status: 202, Body: &body}
+	logger, _ := NewLogger(LogConfig{Enabled: false})
+
+	rr := httptest.NewRecorder()
+	createLoggingHandler(endpoint, logger).ServeHTTP(rr, httptest.NewRequest("GET", "/ping", nil))
+
+	assert.Equal(t, 202, rr.Code)
+	assert.Equal(t, "text/plain; charset=utf-8", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "pong", rr.Body.String())
+}
+
+func TestApplyPathParams(t *testing.T) {
+	data := []map[string]interface{}{{"id": 1, "ratio": 0.5, "active": false, "slug": "a", "ref": json.Number("1"), "name": "x"}}
+	applyPathParams(data, map[string]string{"id": "42", "ratio": "1.5", "active": "true", "slug": "b", "ref": "7"})
+	assert.Equal(t, map[string]interface{}{"id": 42, "ratio": 1.5, "active": true, "slug": "b", "ref": json.Number("7"), "name": "x"}, data[0])
+
+	applyPathParams(data, map[string]string{"id": "abc", "active": "maybe", "ref": "x"})
+	assert.Equal(t, 42, data[0]["id"], "values that do not convert are left alone")
+	assert.Equal(t, true, data[0]["active"])
+	assert.Equal(t, json.Number("7"), data[0]["ref"])
+}
+
+func TestBodyFileFixtures(t *testing.T) {
+	dir := t.TempDir()
+	fixture := filepath.Join(dir, "users.json")
+	require.NoError(t, os.WriteFile(fixture, []byte(`[
+  {"id": 1, "name": "Ada", "balance": 12345678901234567890},
+  {"id": 2, "name": "Grace"},
+  {"id": 3, "name": "Alan"}
+]`), 0644))
+
+	logger, _ := NewLogger(LogConfig{Enabled: false})
+	handler := createLoggingHandler(Endpoint{Path: "/users", Method: "GET", Status: 200, BodyFile: fixture}, logger)
+	serve := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+
+	rr := serve("/users")
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "12345678901234567890", "numbers keep their precision")
+
+	rr = serve("/users?sort=name&order=desc&count=2")
+	var users []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &users))
+	require.Len(t, users, 2)
+	assert.Equal(t, "Grace", users[0]["name"])
+	assert.Equal(t, "Alan", users[1]["name"])
+
+	rr = serve("/users?filter=name:a&meta=true")
+	var envelope struct {
+		Data []map[string]interface{} `json:"data"`
+		Meta map[string]interface{}   `json:"meta"`
+	}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &envelope))
+	assert.Len(t, envelope.Data, 3)
+	assert.Equal(t, float64(3), envelope.Meta["total"])
+
+	// Edits to the fixture are picked up on the next request.
+	require.NoError(t, os.WriteFile(fixture, []byte(`{"status": "ok"}`), 0644))
+	rr = serve("/users?count=1")
+	assert.JSONEq(t, `{"status": "ok"}`, rr.Body.String())
+
+	require.NoError(t, os.WriteFile(fixture, []byte(`[1, 2, 3]`), 0644))
+	rr = serve("/users?count=1")
+	assert.Equal(t, `[1, 2, 3]`, rr.Body.String())
+
+	single := `[{"id": 1}, {"id": 2}]`
+	handler = createLoggingHandler(Endpoint{Path: "/me", Method: "GET", Status: 200, Body: &single, Single: true}, logger)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/me", nil))
+	assert.JSONEq(t, `{"id": 1}`, rr.Body.String())
+
+	// A configured Content-Type survives filtered and generated responses.
+	headers := map[string]string{"Content-Type": "application/vnd.api+json"}
+	for _, endpoint := range []Endpoint{
+		{Path: "/me", Method: "GET", Status: 200, Body: &single, Headers: headers},
+		{Path: "/me", Method: "GET", Status: 200, Data: `{"id": "int"}`, Count: 1, Headers: headers},
+	} {
+		rr = httptest.NewRecorder()
+		createLoggingHandler(endpoint, logger).ServeHTTP(rr, httptest.NewRequest("GET", "/me", nil))
+		assert.Equal(t, []string{"application/vnd.api+json"}, rr.Header().Values("Content-Type"))
+	}
+
+	require.NoError(t, os.Remove(fixture))
+	handler = createLoggingHandler(Endpoint{Path: "/users", Method: "GET", Status: 200, BodyFile: fixture}, logger)
+	rr = serve("/users")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Failed to load body")
+}
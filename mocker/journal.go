@@ -0,0 +1,289 @@
+package mocker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultJournalLimit is the number of requests the journal keeps unless
+// journal.limit says otherwise.
+const defaultJournalLimit = 1000
+
+// maxJournalBody is how much of a request body a journal entry keeps.
+const maxJournalBody = 64 << 10
+
+// JournalEntry is a request received by the server, as kept in the
+// journal. Body holds at most maxJournalBody bytes, with Truncated set when
+// the request sent more. Endpoint is the method and path pattern that
+// handled it, empty when no endpoint matched.
+type JournalEntry struct {
+	ID int `json:"id"`
+	Timestamp string `json:"timestamp"`
+	Method string `json:"method"`
+	Protocol string `json:"protocol"`
+	Path string `json:"path"`
+	Query url.Values `json:"query,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body string `json:"body,omitempty"`
+	Truncated bool `json:"truncated,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	PathParams map[string]string `json:"path_params,omitempty"`
+	Status int `json:"status"`
+	Proxied bool `json:"proxied,omitempty"`
+}
+
+// request rebuilds the journaled request so match rules can run against it.
+func (e JournalEntry) request() *http.Request {
+	target := &url.URL{Path: e.Path, RawQuery: e.Query.Encode()}
+	r, _ := http.NewRequest(e.Method, target.RequestURI(), strings.NewReader(e.Body))
+	r.Header = e.Headers.Clone()
+	if len(e.PathParams) > 0 {
+		r = r.WithContext(context.WithValue(r.Context(), pathParamsKey{}, e.PathParams))
+	}
+	return r
+}
+
+type journalKey struct{}
+
+// peekRequestBody returns up to limit bytes of r's body and whether it had
+// more. r.Body still yields the whole body, and the rest is not buffered,
+// so large uploads stream through to proxies and handlers.
+func peekRequestBody(r *http.Request, limit int64) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, false
+	}
+	prefix, _ := io.ReadAll(io.LimitReader(r.Body, limit+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(prefix), r.Body), r.Body}
+	if int64(len(prefix)) > limit {
+		return prefix[:limit], true
+	}
+	return prefix, false
+}
+
+// journalEntry returns the entry being recorded for r, or nil when r is
+// not journaled.
+func journalEntry(r *http.Request) *JournalEntry {
+	entry, _ := r.Context().Value(journalKey{}).(*JournalEntry)
+	return entry
+}
+
+// Journal keeps the most recent requests in memory, oldest first.
+type Journal struct {
+	mu sync.Mutex
+	entries []JournalEntry
+	limit int
+	nextID int
+}
+
+func NewJournal(limit int) *Journal {
+	j := &Journal{nextID: 1}
+	j.SetLimit(limit)
+	return j
+}
+
+// SetLimit changes how many entries are kept; 0 means defaultJournalLimit.
+func (j *Journal) SetLimit(limit int) {
+	if limit <= 0 {
+		limit = defaultJournalLimit
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.limit = limit
+	j.trim()
+}
+
+func (j *Journal) Add(entry JournalEntry) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	entry.ID = j.nextID
+	j.nextID++
+	j.entries = append(j.entries, entry)
+	j.trim()
+}
+
+func (j *Journal) trim() {
+	if excess := len(j.entries) - j.limit; excess > 0 {
+		j.entries = append([]JournalEntry(nil), j.entries[excess:]...)
+	}
+}
+
+func (j *Journal) Entries() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+func (j *Journal) Reset() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.entries = nil
+}
+
+// journalFilter selects journal entries by method, path and the fields of
+// a MatchConfig. Path may be a pattern like /orders/{id}; its parameters
+// then replace the entry's for path_params conditions.
+type journalFilter struct {
+	Method string `json:"method,omitempty"`
+	Path string `json:"path,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+	Status int `json:"status,omitempty"`
+	Match MatchConfig `json:"match,omitempty"`
+}
+
+func (f journalFilter) compile() (func(JournalEntry) bool, error) {
+	var pattern *route
+	if f.Path != "" {
+		segments, err := compilePathPattern(f.Path)
+		if err != nil {
+			return nil, err
+		}
+		pattern = &route{pattern: f.Path, segments: segments}
+	}
+	for _, group := range []map[string]Matcher{f.Match.Query, f.Match.Headers, f.Match.PathParams, f.Match.Cookies, f.Match.Body} {
+		for name, matcher := range group {
+			if err := matcher.validate(); err != nil {
+				return nil, fmt.Errorf("match %s: %v", name, err)
+			}
+		}
+	}
+
+	return func(entry JournalEntry) bool {
+		if f.Method != "" && !strings.EqualFold(f.Method, entry.Method) {
+			return false
+		}
+		if f.Endpoint != "" && f.Endpoint != entry.Endpoint {
+			return false
+		}
+		if f.Status != 0 && f.Status != entry.Status {
+			return false
+		}
+		if pattern != nil {
+			params, _, ok := pattern.match(splitPath(entry.Path))
+			if !ok {
+				return false
+			}
+			if strings.Contains(f.Path, "{") {
+				entry.PathParams = params
+			}
+		}
+		return f.Match.matches(&matchRequest{r: entry.request()})
+	}, nil
+}
+
+// handleRequests serves GET and DELETE /__apimocker/requests. GET accepts
+// method, path, endpoint, status and limit (the newest n) query parameters.
+func (s *Server) handleRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		s.journal.Reset()
+		adminRespond(w, http.StatusNoContent, nil)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := journalFilter{Method: query.Get("method"), Path: query.Get("path"), Endpoint: query.Get("endpoint")}
+	if status := query.Get("status"); status != "" {
+		code, err := strconv.Atoi(status)
+		if err != nil {
+			adminError(w, fmt.Errorf("status must be a number"))
+			return
+		}
+		filter.Status = code
+	}
+	keep, err := filter.compile()
+	if err != nil {
+		adminError(w, err)
+		return
+	}
+
+	entries := []JournalEntry{}
+	for _, entry := range s.journal.Entries() {
+		if keep(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			adminError(w, fmt.Errorf("limit must be a non-negative number"))
+			return
+		}
+		if n < len(entries) {
+			entries = entries[len(entries)-n:]
+		}
+	}
+	adminRespond(w, http.StatusOK, entries)
+}
+
+// verifyRequest asserts how often requests selected by its filter were
+// received. Without a count bound it expects at least one.
+type verifyRequest struct {
+	journalFilter
+	Count *int `json:"count,omitempty"`
+	AtLeast *int `json:"at_least,omitempty"`
+	AtMost *int `json:"at_most,omitempty"`
+}
+
+func (v verifyRequest) expectation() (string, func(int) bool) {
+	switch {
+	case v.Count != nil:
+		return fmt.Sprintf("exactly %d", *v.Count), func(n int) bool { return n == *v.Count }
+	case v.AtLeast != nil && v.AtMost != nil:
+		return fmt.Sprintf("between %d and %d", *v.AtLeast, *v.AtMost), func(n int) bool { return n >= *v.AtLeast && n <= *v.AtMost }
+	case v.AtMost != nil:
+		return fmt.Sprintf("at most %d", *v.AtMost), func(n int) bool { return n <= *v.AtMost }
+	case v.AtLeast != nil:
+		return fmt.Sprintf("at least %d", *v.AtLeast), func(n int) bool { return n >= *v.AtLeast }
+	}
+	return "at least 1", func(n int) bool { return n >= 1 }
+}
+
+// handleVerify serves POST /__apimocker/verify. It answers 200 when the
+// journal meets the expectation and 417 when it does not, listing the
+// matching requests either way.
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	var verify verifyRequest
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&verify); err != nil {
+		adminError(w, fmt.Errorf("invalid verify JSON: %v", err))
+		return
+	}
+	if verify.Count != nil && (verify.AtLeast != nil || verify.AtMost != nil) {
+		adminError(w, fmt.Errorf("count cannot be combined with at_least or at_most"))
+		return
+	}
+	keep, err := verify.compile()
+	if err != nil {
+		adminError(w, err)
+		return
+	}
+
+	matched := []JournalEntry{}
+	for _, entry := range s.journal.Entries() {
+		if keep(entry) {
+			matched = append(matched, entry)
+		}
+	}
+	expected, ok := verify.expectation()
+	statusCode := http.StatusOK
+	if !ok(len(matched)) {
+		statusCode = http.StatusExpectationFailed
+	}
+	adminRespond(w, statusCode, map[string]interface{}{
+		"verified": statusCode == http.StatusOK,
+		"expected": expected,
+		"count": len(matched),
+		"requests": matched,
+	})
+}
@@ -0,0 +1,126 @@
+package mocker
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestJournal(t *testing.T) {
+	created := `{"created": true}`
+	server, _, err := NewServer(&Config{
+		Port: 8080,
+		Journal: JournalConfig{Limit: 5},
+		Admin: &AdminConfig{Enabled: true},
+		Endpoints: []Endpoint{
+			{Path: "/orders", Method: "POST", Status: 201, Count: 1, Body: &created},
+			{Path: "/orders/{id}", Method: "GET", Status: 200, Count: 1, Data: `{"id": "uuid"}`, Single: true},
+		},
+	})
+	require.NoError(t, err)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("X-Client", "web")
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+	do("POST", "/orders", `{"total": 150}`)
+	do("POST", "/orders?dry=1", `{"total": 50}`)
+	do("POST", "/orders", `{"total": 101.5}`)
+	do("GET", "/orders/7", "")
+	do("GET", "/missing", "")
+
+	rr := do("GET", "/__apimocker/requests", "")
+	require.Equal(t, 200, rr.Code)
+	var entries []JournalEntry
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &entries))
+	require.Len(t, entries, 5, "admin requests are not journaled")
+	assert.Equal(t, "POST /orders", entries[0].Endpoint)
+	assert.Equal(t, `{"total": 150}`, entries[0].Body)
+	assert.Equal(t, 201, entries[0].Status)
+	assert.Equal(t, "web", entries[0].Headers.Get("X-Client"))
+	assert.Equal(t, "1", entries[1].Query.Get("dry"))
+	assert.Equal(t, "GET /orders/{id}", entries[3].Endpoint)
+	assert.Equal(t, map[string]string{"id": "7"}, entries[3].PathParams)
+	assert.Equal(t, "", entries[4].Endpoint)
+	assert.Equal(t, 404, entries[4].Status)
+
+	filter := func(query string) []JournalEntry {
+		rr := do("GET", "/__apimocker/requests?"+query, "")
+		require.Equal(t, 200, rr.Code, rr.Body.String())
+		var entries []JournalEntry
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &entries))
+		return entries
+	}
+	assert.Len(t, filter("method=post"), 3)
+	assert.Len(t, filter("path=/orders/{id}"), 1)
+	assert.Len(t, filter("endpoint=GET+/orders/{id}"), 1)
+	assert.Len(t, filter("status=404"), 1)
+	last := filter("method=POST&limit=1")
+	require.Len(t, last, 1)
+	assert.Equal(t, entries[2].ID, last[0].ID)
+	assert.Equal(t, 400, do("GET", "/__apimocker/requests?path=/{", "").Code)
+
+	verify := func(body string) (int, map[string]interface{}) {
+		rr := do("POST", "/__apimocker/verify", body)
+		var result map[string]interface{}
+		json.Unmarshal(rr.Body.Bytes(), &result)
+		return rr.Code, result
+	}
+	code, result := verify(`{"method": "POST", "path": "/orders", "match": {"body": {"$.total": {"gt": 100}}}, "count": 2}`)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, true, result["verified"])
+	assert.Equal(t, float64(2), result["count"])
+	code, result = verify(`{"method": "POST", "path": "/orders", "count": 2}`)
+	assert.Equal(t, 417, code)
+	assert.Equal(t, "exactly 2", result["expected"])
+	assert.Equal(t, float64(3), result["count"])
+	code, _ = verify(`{"path": "/orders/{id}", "match": {"path_params": {"id": "7"}, "headers": {"X-Client": "web"}}}`)
+	assert.Equal(t, 200, code)
+	code, _ = verify(`{"path": "/orders/{id}", "at_most": 0}`)
+	assert.Equal(t, 417, code)
+	code, _ = verify(`{"path": "/orders", "count": 1, "at_least": 1}`)
+	assert.Equal(t, 400, code)
+	code, _ = verify(`{"match": {"query": {"dry": {}}}}`)
+	assert.Equal(t, 400, code)
+
+	do("GET", "/orders/8", "")
+	entries = filter("")
+	assert.Len(t, entries, 5, "journal keeps the newest journal.limit requests")
+	assert.Equal(t, "/orders/8", entries[4].Path)
+
+	assert.Equal(t, 204, do("DELETE", "/__apimocker/requests", "").Code)
+	assert.Empty(t, filter(""))
+}
+
+func TestJournalBodyLimit(t *testing.T) {
+	length := `{{ len .Body }}`
+	server, _, err := NewServer(&Config{
+		Port: 8080,
+		Endpoints: []Endpoint{
+			{Path: "/uploads", Method: "POST", Status: 200, Count: 1, Body: &length, Template: true},
+		},
+	})
+	require.NoError(t, err)
+
+	upload := strings.Repeat("a", maxJournalBody+10)
+	for _, body := range []string{"small", upload} {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("POST", "/uploads", strings.NewReader(body)))
+		assert.Equal(t, strconv.Itoa(len(body)), rr.Body.String(), "the handler reads the whole body")
+	}
+
+	entries := server.Requests()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "small", entries[0].Body)
+	assert.False(t, entries[0].Truncated)
+	assert.Equal(t, upload[:maxJournalBody], entries[1].Body)
+	assert.True(t, entries[1].Truncated)
+}
@@ -0,0 +1,290 @@
+package mocker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// jwtHeader is the part of a JWT header used to pick the verification key.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// jwk is a public key from a JWKS document. Only RSA and P-256 EC keys are
+// used.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	N string `json:"n"`
+	E string `json:"e"`
+	Crv string `json:"crv"`
+	X string `json:"x"`
+	Y string `json:"y"`
+}
+
+func (key jwk) publicKey() (crypto.PublicKey, error) {
+	decode := func(value string) (*big.Int, error) {
+		data, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+		return new(big.Int).SetBytes(data), nil
+	}
+
+	switch key.Kty {
+	case "RSA":
+		n, err := decode(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid n: %v", key.Kid, err)
+		}
+		e, err := decode(key.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("key %q: invalid e", key.Kid)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if key.Crv != "P-256" {
+			return nil, fmt.Errorf("key %q: unsupported curve %q", key.Kid, key.Crv)
+		}
+		x, err := decode(key.X)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid x: %v", key.Kid, err)
+		}
+		y, err := decode(key.Y)
+		if err != nil {
+			return nil, fmt.Errorf("key %q: invalid y: %v", key.Kid, err)
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	}
+	return nil, fmt.Errorf("key %q: unsupported key type %q", key.Kid, key.Kty)
+}
+
+// jwtKey is a verification key and the kid it is published under.
+type jwtKey struct {
+	kid string
+	key interface{}
+}
+
+// loadJWTKeys returns the keys of a jwt auth: the HS256 secret, the key in
+// a PEM file (a public key or certificate), the keys of a JWKS file or
+// those of the OAuth provider.
+func loadJWTKeys(auth *AuthConfig) ([]jwtKey, error) {
+	switch {
+	case auth.keys != nil:
+		return auth.keys, nil
+	case auth.Secret != "":
+		return []jwtKey{{key: []byte(auth.Secret)}}, nil
+	case auth.PublicKey != "":
+		data, err := os.ReadFile(auth.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("%q contains no PEM data", auth.PublicKey)
+		}
+		if block.Type == "CERTIFICATE" {
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			return []jwtKey{{key: cert.PublicKey}}, nil
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			if rsaKey, rsaErr := x509.ParsePKCS1PublicKey(block.Bytes); rsaErr == nil {
+				return []jwtKey{{key: rsaKey}}, nil
+			}
+			return nil, fmt.Errorf("%q: %v", auth.PublicKey, err)
+		}
+		return []jwtKey{{key: key}}, nil
+	case auth.JWKS != "":
+		data, err := os.ReadFile(auth.JWKS)
+		if err != nil {
+			return nil, err
+		}
+		var set struct {
+			Keys []jwk `json:"keys"`
+		}
+		if err := json.Unmarshal(data, &set); err != nil {
+			return nil, fmt.Errorf("%q is not a JWKS document: %v", auth.JWKS, err)
+		}
+		var keys []jwtKey
+		for _, key := range set.Keys {
+			if key.Use != "" && key.Use != "sig" {
+				continue
+			}
+			publicKey, err := key.publicKey()
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, jwtKey{kid: key.Kid, key: publicKey})
+		}
+		if len(keys) == 0 {
+			return nil, fmt.Errorf("%q contains no signing keys", auth.JWKS)
+		}
+		return keys, nil
+	}
+	return nil, fmt.Errorf("jwt auth requires secret, public_key, jwks or an oauth section")
+}
+
+// verifyJWTSignature checks sig over signingInput with key for alg, one of
+// HS256, RS256 and ES256.
+func verifyJWTSignature(alg string, key interface{}, signingInput string, sig []byte) bool {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch k := key.(type) {
+	case []byte:
+		if alg != "HS256" {
+			return false
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signingInput))
+		return hmac.Equal(sig, mac.Sum(nil))
+	case *rsa.PublicKey:
+		return alg == "RS256" && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	case *ecdsa.PublicKey:
+		if alg != "ES256" || len(sig) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(k, digest[:], r, s)
+	}
+	return false
+}
+
+// claimValues returns the values of the claim at expr, a claim name or a
+// JSONPath, with arrays expanded so a matcher can test membership.
+func claimValues(claims map[string]interface{}, expr string) []string {
+	steps, err := parseJSONPath(expr)
+	if err != nil {
+		return nil
+	}
+	var values []string
+	for _, value := range evalJSONPath(claims, steps) {
+		if list, ok := value.([]interface{}); ok {
+			for _, item := range list {
+				values = append(values, matchString(item))
+			}
+			continue
+		}
+		values = append(values, matchString(value))
+	}
+	return values
+}
+
+// authenticateJWT verifies a bearer JWT: its signature, exp and nbf, and
+// the issuer, audience and claims required by authConfig.
+func authenticateJWT(authHeader string, authConfig *AuthConfig) (bool, string, string) {
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return false, "jwt", "invalid-bearer-format"
+	}
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return false, "jwt", "malformed-token"
+	}
+
+	var header jwtHeader
+	var claims map[string]interface{}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(headerJSON, &header) != nil {
+		return false, "jwt", "malformed-token"
+	}
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(claimsJSON, &claims) != nil {
+		return false, "jwt", "malformed-token"
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false, "jwt", "malformed-token"
+	}
+
+	if header.Alg != "HS256" && header.Alg != "RS256" && header.Alg != "ES256" {
+		return false, "jwt", "unsupported-algorithm"
+	}
+	keys, err := loadJWTKeys(authConfig)
+	if err != nil {
+		return false, "jwt", "invalid-key"
+	}
+	published := false
+	for _, key := range keys {
+		published = published || key.kid != ""
+	}
+	if header.Kid != "" && published {
+		var matching []jwtKey
+		for _, key := range keys {
+			if key.kid == header.Kid {
+				matching = append(matching, key)
+			}
+		}
+		if len(matching) == 0 {
+			return false, "jwt", "unknown-key"
+		}
+		keys = matching
+	}
+	verified := false
+	for _, key := range keys {
+		if verifyJWTSignature(header.Alg, key.key, parts[0]+"."+parts[1], sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return false, "jwt", "invalid-signature"
+	}
+
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok && now >= exp {
+		return false, "jwt", "expired-token"
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now < nbf {
+		return false, "jwt", "token-not-yet-valid"
+	}
+	if authConfig.Issuer != "" && claims["iss"] != authConfig.Issuer {
+		return false, "jwt", "invalid-issuer"
+	}
+	if authConfig.Audience != "" {
+		audiences := claimValues(claims, "aud")
+		found := false
+		for _, audience := range audiences {
+			found = found || audience == authConfig.Audience
+		}
+		if !found {
+			return false, "jwt", "invalid-audience"
+		}
+	}
+
+	names := make([]string, 0, len(authConfig.Claims))
+	for name := range authConfig.Claims {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		values := claimValues(claims, name)
+		matcher := authConfig.Claims[name]
+		if len(values) == 0 && (matcher.Exists == nil || *matcher.Exists) {
+			return false, "jwt", "missing-claim"
+		}
+		if !matcher.matches(values) {
+			return false, "jwt", "invalid-claim"
+		}
+	}
+	return true, "jwt", "success"
+}
@@ -0,0 +1,191 @@
+package mocker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// signJWT builds a token for claims signed by key, an HS256 secret or an
+// RSA or P-256 private key.
+func signJWT(t *testing.T, key interface{}, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	header := map[string]string{"typ": "JWT"}
+	switch key.(type) {
+	case []byte:
+		header["alg"] = "HS256"
+	case *rsa.PrivateKey:
+		header["alg"] = "RS256"
+	case *ecdsa.PrivateKey:
+		header["alg"] = "ES256"
+	}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encode := func(value interface{}) string {
+		data, err := json.Marshal(value)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	input := encode(header) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		var err error
+		sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		require.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestAuthenticateJWT(t *testing.T) {
+	secret := []byte("jwt-secret")
+	admin := "admin"
+	authConfig := &AuthConfig{
+		Type: "jwt",
+		Secret: string(secret),
+		Issuer: "https://issuer.test",
+		Audience: "orders",
+		Claims: map[string]Matcher{"role": {Equals: &admin}},
+	}
+	now := time.Now().Unix()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"sub": "user-1",
+			"iss": "https://issuer.test",
+			"aud": []string{"billing", "orders"},
+			"exp": now + 60,
+			"role": []string{"user", "admin"},
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name string
+		authHeader string
+		wantAuth bool
+		wantResult string
+	}{
+		{"valid token", "Bearer " + signJWT(t, secret, "", claims(nil)), true, "success"},
+		{"invalid format", "Basic dGVzdA==", false, "invalid-bearer-format"},
+		{"malformed token", "Bearer not-a-jwt", false, "malformed-token"},
+		{"none algorithm", "Bearer eyJhbGciOiJub25lIn0.e30.", false, "unsupported-algorithm"},
+		{"wrong secret", "Bearer " + signJWT(t, []byte("other"), "", claims(nil)), false, "invalid-signature"},
+		{"expired", "Bearer " + signJWT(t, secret, "", claims(map[string]interface{}{"exp": now - 10})), false, "expired-token"},
+		{"not yet valid", "Bearer " + signJWT(t, secret, "", claims(map[string]interface{}{"nbf": now + 60})), false, "token-not-yet-valid"},
+		{"wrong issuer", "Bearer " + signJWT(t, secret, "", claims(map[string]interface{}{"iss": "https://other.test"})), false, "invalid-issuer"},
+		{"wrong audience", "Bearer " + signJWT(t, secret, "", claims(map[string]interface{}{"aud": "billing"})), false, "invalid-audience"},
+		{"missing claim", "Bearer " + signJWT(t, secret, "", claims(map[string]interface{}{"role": nil})), false, "missing-claim"},
+		{"invalid claim", "Bearer " + signJWT(t, secret, "", claims(map[string]interface{}{"role": "user"})), false, "invalid-claim"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			success, authType, result := authenticateJWT(tt.authHeader, authConfig)
+			assert.Equal(t, tt.wantAuth, success)
+			assert.Equal(t, "jwt", authType)
+			assert.Equal(t, tt.wantResult, result)
+		})
+	}
+}
+
+func TestAuthenticateJWTPublicKeys(t *testing.T) {
+	dir := t.TempDir()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	claims := map[string]interface{}{"sub": "user-1", "exp": time.Now().Unix() + 60}
+
+	der, err := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	pemPath := filepath.Join(dir, "public.pem")
+	require.NoError(t, os.WriteFile(pemPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0644))
+	pemAuth := &AuthConfig{Type: "jwt", PublicKey: pemPath}
+
+	ok, _, result := authenticateJWT("Bearer "+signJWT(t, rsaKey, "", claims), pemAuth)
+	assert.True(t, ok)
+	assert.Equal(t, "success", result)
+	_, _, result = authenticateJWT("Bearer "+signJWT(t, ecKey, "", claims), pemAuth)
+	assert.Equal(t, "invalid-signature", result)
+	_, _, result = authenticateJWT("Bearer "+signJWT(t, []byte("secret"), "", claims), pemAuth)
+	assert.Equal(t, "invalid-signature", result, "an HS256 token must not verify against a public key")
+
+	encode := func(n *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(n.Bytes())
+	}
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa-1", "use": "sig", "n": encode(rsaKey.N), "e": encode(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec-1", "crv": "P-256", "x": encode(ecKey.X), "y": encode(ecKey.Y)},
+	}})
+	require.NoError(t, err)
+	jwksPath := filepath.Join(dir, "jwks.json")
+	require.NoError(t, os.WriteFile(jwksPath, jwks, 0644))
+	jwksAuth := &AuthConfig{Type: "jwt", JWKS: jwksPath}
+
+	ok, _, result = authenticateJWT("Bearer "+signJWT(t, rsaKey, "rsa-1", claims), jwksAuth)
+	assert.True(t, ok)
+	assert.Equal(t, "success", result)
+	ok, _, result = authenticateJWT("Bearer "+signJWT(t, ecKey, "ec-1", claims), jwksAuth)
+	assert.True(t, ok)
+	assert.Equal(t, "success", result)
+	_, _, result = authenticateJWT("Bearer "+signJWT(t, ecKey, "rsa-1", claims), jwksAuth)
+	assert.Equal(t, "invalid-signature", result)
+	_, _, result = authenticateJWT("Bearer "+signJWT(t, ecKey, "ec-2", claims), jwksAuth)
+	assert.Equal(t, "unknown-key", result)
+
+	keyedConfig := NewConfig(GET("/keyed").Body("ok"))
+	keyedConfig.Endpoints[0].Auth = jwksAuth
+	keyed, err := New(keyedConfig)
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(jwksPath))
+	req := httptest.NewRequest("GET", "/keyed", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, ecKey, "ec-1", claims))
+	w := httptest.NewRecorder()
+	keyed.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code, "keys are loaded once, not per request")
+
+	server, err := New(NewConfig(GET("/orders").JWTAuth("secret", map[string]string{"scope": "orders"}).Body("ok")))
+	require.NoError(t, err)
+	req = httptest.NewRequest("GET", "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+signJWT(t, []byte("secret"), "", map[string]interface{}{"scope": "orders"}))
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	assert.Equal(t, 200, w.Code)
+}
@@ -0,0 +1,121 @@
+package mocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+type RequestLog struct {
+	Timestamp string `json:"timestamp"`
+	Method string `json:"method"`
+	Protocol string `json:"protocol,omitempty"`
+	Path string `json:"path"`
+	Query string `json:"query,omitempty"`
+	StatusCode int `json:"status_code"`
+	ResponseTime string `json:"response_time"`
+	UserAgent string `json:"user_agent,omitempty"`
+	RemoteAddr string `json:"remote_addr"`
+	ContentLength int64 `json:"content_length"`
+	AuthType string `json:"auth_type,omitempty"`
+	AuthResult string `json:"auth_result,omitempty"`
+	PathParams map[string]string `json:"path_params,omitempty"`
+	Proxied bool `json:"proxied,omitempty"`
+}
+
+type Logger struct {
+	writer io.Writer
+	format string
+}
+
+func NewLogger(config LogConfig) (*Logger, error) {
+	if !config.Enabled {
+		return &Logger{writer: io.Discard, format: config.Format}, nil
+	}
+
+	var writer io.Writer
+	if config.Output == "stdout" || config.Output == "" {
+		writer = os.Stdout
+	} else {
+		file, err := os.OpenFile(config.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open log file: %v",err)
+		}
+		writer = file
+	}
+
+	format := config.Format
+	if format != "json" && format != "plain" {
+		format = "plain"
+	}
+	
+	return &Logger{writer: writer, format: format}, nil
+}
+
+// Close releases the log file, if any. Stdout and discarded output are left
+// untouched.
+func (l *Logger) Close() error {
+	if file, ok := l.writer.(*os.File); ok && file != os.Stdout {
+		return file.Close()
+	}
+	return nil
+}
+
+func (l *Logger) LogRequest(reqLog RequestLog) {
+	if l.writer == io.Discard {
+		return
+	}
+
+	if l.format == "json" {
+		data, err := json.Marshal(reqLog)
+		if err != nil {
+			return
+		}
+		fmt.Fprintln(l.writer, string(data))
+	} else {
+		query := ""
+		if reqLog.Query != "" {
+			query = "?" + reqLog.Query
+		}
+		authInfo := ""
+		if reqLog.AuthType != "" {
+			authInfo = fmt.Sprintf(" - Auth: %s (%s)", reqLog.AuthType, reqLog.AuthResult)
+		}
+		paramsInfo := ""
+		if len(reqLog.PathParams) > 0 {
+			names := make([]string, 0, len(reqLog.PathParams))
+			for name := range reqLog.PathParams {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			pairs := make([]string, 0, len(names))
+			for _, name := range names {
+				pairs = append(pairs, name+"="+reqLog.PathParams[name])
+			}
+			paramsInfo = " - Params: " + strings.Join(pairs, ", ")
+		}
+		if reqLog.Proxied {
+			paramsInfo += " - Proxied"
+		}
+		protocol := ""
+		if reqLog.Protocol != "" {
+			protocol = " " + reqLog.Protocol
+		}
+		fmt.Fprintf(l.writer, "[%s] %s %s%s%s - %d - %s - %s - %d bytes%s%s\r\n",
+			reqLog.Timestamp,
+			reqLog.Method,
+			reqLog.Path,
+			query,
+			protocol,
+			reqLog.StatusCode,
+			reqLog.ResponseTime,
+			reqLog.RemoteAddr,
+			reqLog.ContentLength,
+			authInfo,
+			paramsInfo,
+			)
+	}
+}
@@ -0,0 +1,464 @@
+package mocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+func (m *Matcher) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		value := node.Value
+		m.Equals = &value
+		return nil
+	}
+	type plain Matcher
+	return node.Decode((*plain)(m))
+}
+
+func (m *Matcher) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		equals := matchString(value)
+		m.Equals = &equals
+		return nil
+	}
+	if value, ok := fields["equals"]; ok {
+		equals := matchString(value)
+		m.Equals = &equals
+	}
+	if value, ok := fields["regex"]; ok {
+		regex, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("regex must be a string")
+		}
+		m.Regex = regex
+	}
+	if value, ok := fields["values"]; ok {
+		list, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("values must be a list")
+		}
+		m.Values = make([]string, 0, len(list))
+		for _, item := range list {
+			m.Values = append(m.Values, matchString(item))
+		}
+	}
+	if value, ok := fields["exists"]; ok {
+		exists, ok := value.(bool)
+		if !ok {
+			return fmt.Errorf("exists must be true or false")
+		}
+		m.Exists = &exists
+	}
+	for name, bound := range map[string]**float64{"gt": &m.Gt, "gte": &m.Gte, "lt": &m.Lt, "lte": &m.Lte} {
+		if value, ok := fields[name]; ok {
+			number, ok := value.(float64)
+			if !ok {
+				return fmt.Errorf("%s must be a number", name)
+			}
+			*bound = &number
+		}
+	}
+	return nil
+}
+
+// matcherRegexps caches compiled Matcher.Regex patterns.
+var matcherRegexps sync.Map
+
+func matcherRegexp(pattern string) (*regexp.Regexp, error) {
+	if re, ok := matcherRegexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matcherRegexps.Store(pattern, re)
+	return re, nil
+}
+
+func (m Matcher) validate() error {
+	if m.Equals == nil && m.Regex == "" && m.Exists == nil && m.Values == nil && !m.compares() {
+		return fmt.Errorf("needs equals, regex, exists, values, gt, gte, lt or lte")
+	}
+	if m.Regex != "" {
+		if _, err := matcherRegexp(m.Regex); err != nil {
+			return fmt.Errorf("invalid regex %q: %v", m.Regex, err)
+		}
+	}
+	return nil
+}
+
+// matches reports whether values, all occurrences of a request value,
+// satisfy m. An absent value has no occurrences.
+func (m Matcher) matches(values []string) bool {
+	if m.Exists != nil && *m.Exists != (len(values) > 0) {
+		return false
+	}
+	if m.Values != nil && !sameValues(values, m.Values) {
+		return false
+	}
+	if m.Equals == nil && m.Regex == "" && !m.compares() {
+		return true
+	}
+
+	var re *regexp.Regexp
+	if m.Regex != "" {
+		var err error
+		if re, err = matcherRegexp(m.Regex); err != nil {
+			return false
+		}
+	}
+	for _, value := range values {
+		if m.Equals != nil && value != *m.Equals {
+			continue
+		}
+		if re != nil && !re.MatchString(value) {
+			continue
+		}
+		if m.compares() && !m.inRange(value) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// sameValues reports whether a and b hold the same values in any order.
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string(nil), a...), append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Matcher) compares() bool {
+	return m.Gt != nil || m.Gte != nil || m.Lt != nil || m.Lte != nil
+}
+
+// inRange reports whether value is a number within m's bounds.
+func (m Matcher) inRange(value string) bool {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return false
+	}
+	return (m.Gt == nil || number > *m.Gt) &&
+		(m.Gte == nil || number >= *m.Gte) &&
+		(m.Lt == nil || number < *m.Lt) &&
+		(m.Lte == nil || number <= *m.Lte)
+}
+
+// matchString renders a decoded JSON value the way it is compared.
+func matchString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case nil:
+		return "null"
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// matchRequest gives rules access to a request, reading and decoding the
+// body at most once.
+type matchRequest struct {
+	r *http.Request
+	body []byte
+	document interface{}
+	isJSON bool
+	read bool
+}
+
+func (mr *matchRequest) bodyValues(expr string) []string {
+	if !mr.read {
+		mr.read = true
+		mr.body = readRequestBody(mr.r)
+		mr.isJSON = json.Unmarshal(mr.body, &mr.document) == nil
+	}
+
+	steps, err := parseJSONPath(expr)
+	if err != nil {
+		return nil
+	}
+	if !mr.isJSON {
+		if len(steps) == 0 && len(mr.body) > 0 {
+			return []string{string(mr.body)}
+		}
+		return nil
+	}
+
+	found := evalJSONPath(mr.document, steps)
+	values := make([]string, 0, len(found))
+	for _, value := range found {
+		values = append(values, matchString(value))
+	}
+	return values
+}
+
+func (match MatchConfig) matches(mr *matchRequest) bool {
+	r := mr.r
+	for name, matcher := range match.Query {
+		if !matcher.matches(r.URL.Query()[name]) {
+			return false
+		}
+	}
+	for name, matcher := range match.Headers {
+		if !matcher.matches(r.Header.Values(name)) {
+			return false
+		}
+	}
+	params := pathParams(r)
+	for name, matcher := range match.PathParams {
+		var values []string
+		if value, ok := params[name]; ok {
+			values = []string{value}
+		}
+		if !matcher.matches(values) {
+			return false
+		}
+	}
+	for name, matcher := range match.Cookies {
+		var values []string
+		for _, cookie := range r.Cookies() {
+			if cookie.Name == name {
+				values = append(values, cookie.Value)
+			}
+		}
+		if !matcher.matches(values) {
+			return false
+		}
+	}
+	for expr, matcher := range match.Body {
+		if !matcher.matches(mr.bodyValues(expr)) {
+			return false
+		}
+	}
+	return true
+}
+
+// maxRequestBody is the largest request body that body matchers and
+// templates read.
+const maxRequestBody = 10 << 20
+
+// readRequestBody returns the request body and leaves r.Body readable. A
+// body over maxRequestBody yields nil, and reading r.Body then fails.
+func readRequestBody(r *http.Request) []byte {
+	if r.Body == nil {
+		return nil
+	}
+	limited := http.MaxBytesReader(nil, r.Body, maxRequestBody)
+	body, err := io.ReadAll(limited)
+	if err != nil {
+		// The reader keeps returning err.
+		r.Body = limited
+		return nil
+	}
+	r.Body.Close()
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body
+}
+
+// selectResponse applies the first of endpoint's response rules that
+// matches r, or else the next step of its sequence. Without either the
+// endpoint itself answers, unless it requires a scenario state that is not
+// current; then selectResponse reports false.
+func selectResponse(endpoint Endpoint, r *http.Request, sequence *sequenceCounter) (Endpoint, bool) {
+	state := scenarioStarted
+	if endpoint.Scenario != "" {
+		state = scenarios(r).State(endpoint.Scenario)
+	}
+	if endpoint.State != "" && endpoint.State != state {
+		return endpoint, false
+	}
+
+	if len(endpoint.Responses) > 0 {
+		mr := &matchRequest{r: r}
+		for _, rule := range endpoint.Responses {
+			if rule.State != "" && rule.State != state {
+				continue
+			}
+			if rule.Match.matches(mr) {
+				// The selected response records the state it depends on,
+				// so its transition only happens from that state.
+				selected := rule.apply(endpoint)
+				if rule.State != "" {
+					selected.State = rule.State
+				}
+				return selected, true
+			}
+		}
+	}
+
+	if sequence != nil {
+		if step, ok := sequence.next(r); ok {
+			return step.apply(endpoint), true
+		}
+	}
+	return endpoint, true
+}
+
+func (response Response) apply(endpoint Endpoint) Endpoint {
+	endpoint.Responses = nil
+	endpoint.Sequence = nil
+	if response.Status != 0 {
+		endpoint.Status = response.Status
+	}
+	if len(response.Headers) > 0 {
+		headers := make(map[string]string, len(endpoint.Headers)+len(response.Headers))
+		for key, value := range endpoint.Headers {
+			headers[key] = value
+		}
+		for key, value := range response.Headers {
+			headers[key] = value
+		}
+		endpoint.Headers = headers
+	}
+
+	if response.Data != "" || response.Body != nil || response.BodyFile != "" || response.File != "" {
+		endpoint.Data, endpoint.Body, endpoint.BodyFile, endpoint.File = response.Data, response.Body, response.BodyFile, response.File
+	}
+
+	if response.Template {
+		endpoint.Template = true
+	}
+	if response.Count != 0 {
+		endpoint.Count = response.Count
+	}
+	if response.Delay != "" {
+		endpoint.Delay = response.Delay
+	}
+	if response.NextState != "" {
+		endpoint.NextState = response.NextState
+	}
+	return endpoint
+}
+
+// jsonPathStep is one step of a JSONPath expression: a key, an index or a
+// wildcard over all children.
+type jsonPathStep struct {
+	key string
+	index int
+	isIndex bool
+	wildcard bool
+}
+
+// parseJSONPath supports the subset of JSONPath used by body matchers:
+// $, .key, ['key'], [n] (negative counts from the end), .* and [*].
+func parseJSONPath(expr string) ([]jsonPathStep, error) {
+	expr = strings.TrimSpace(expr)
+	if !strings.HasPrefix(expr, "$") {
+		expr = "$." + expr
+	}
+
+	var steps []jsonPathStep
+	rest := expr[1:]
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end == -1 {
+				end = len(rest)
+			}
+			key := rest[:end]
+			if key == "" {
+				return nil, fmt.Errorf("invalid JSONPath %q: empty key", expr)
+			}
+			if key == "*" {
+				steps = append(steps, jsonPathStep{wildcard: true})
+			} else {
+				steps = append(steps, jsonPathStep{key: key})
+			}
+			rest = rest[end:]
+		case '[':
+			end := strings.Index(rest, "]")
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSONPath %q: missing ]", expr)
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			rest = rest[end+1:]
+			switch {
+			case inner == "*":
+				steps = append(steps, jsonPathStep{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				steps = append(steps, jsonPathStep{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil {
+					return nil, fmt.Errorf("invalid JSONPath %q: bad index %q", expr, inner)
+				}
+				steps = append(steps, jsonPathStep{index: index, isIndex: true})
+			}
+		default:
+			return nil, fmt.Errorf("invalid JSONPath %q: unexpected %q", expr, rest[0])
+		}
+	}
+	return steps, nil
+}
+
+func evalJSONPath(document interface{}, steps []jsonPathStep) []interface{} {
+	current := []interface{}{document}
+	for _, step := range steps {
+		var next []interface{}
+		for _, value := range current {
+			switch node := value.(type) {
+			case map[string]interface{}:
+				if step.wildcard {
+					keys := make([]string, 0, len(node))
+					for key := range node {
+						keys = append(keys, key)
+					}
+					sort.Strings(keys)
+					for _, key := range keys {
+						next = append(next, node[key])
+					}
+				} else if child, ok := node[step.key]; ok && !step.isIndex {
+					next = append(next, child)
+				}
+			case []interface{}:
+				if step.wildcard {
+					next = append(next, node...)
+				} else if step.isIndex {
+					index := step.index
+					if index < 0 {
+						index += len(node)
+					}
+					if index >= 0 && index < len(node) {
+						next = append(next, node[index])
+					}
+				}
+			}
+		}
+		current = next
+	}
+	return current
+}
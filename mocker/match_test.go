@@ -0,0 +1,182 @@
+package mocker
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseRules(t *testing.T) {
+	configContent := `port: 8080
+endpoints:
+  - path: /search
+    method: GET
+    data: '{"id": "uuid"}'
+    responses:
+      - match:
+          query:
+            q: zzz
+        body: '[]'
+      - match:
+          headers:
+            X-Tenant:
+              regex: ^beta-
+        status: 202
+        headers:
+          X-Variant: beta
+  - path: /users/{id}
+    method: POST
+    status: 201
+    body: '{"ok": true}'
+    responses:
+      - match:
+          body:
+            $.email:
+              regex: ^[^@]+$
+        status: 422
+        body: '{"error": "invalid email"}'
+      - match:
+          body:
+            tags[*]: admin
+          cookies:
+            session:
+              exists: true
+        status: 403
+        body: forbidden
+      - match:
+          path_params:
+            id: "0"
+        status: 404
+        body: ''
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	config, err := LoadConfig(tmpFile.Name())
+	require.NoError(t, err)
+	server, _, err := NewServer(config)
+	require.NoError(t, err)
+
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := serve(httptest.NewRequest("GET", "/search?q=zzz", nil))
+	assert.Equal(t, 200, rr.Code)
+	assert.Equal(t, "[]", rr.Body.String())
+
+	req := httptest.NewRequest("GET", "/search?q=abc", nil)
+	req.Header.Set("X-Tenant", "beta-eu")
+	rr = serve(req)
+	assert.Equal(t, 202, rr.Code)
+	assert.Equal(t, "beta", rr.Header().Get("X-Variant"))
+	var records []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &records))
+	assert.Len(t, records, 1)
+
+	rr = serve(httptest.NewRequest("GET", "/search", nil))
+	assert.Equal(t, 200, rr.Code)
+	assert.Empty(t, rr.Header().Get("X-Variant"))
+
+	rr = serve(httptest.NewRequest("POST", "/users/7", strings.NewReader(`{"email": "nope"}`)))
+	assert.Equal(t, 422, rr.Code)
+	assert.JSONEq(t, `{"error": "invalid email"}`, rr.Body.String())
+
+	req = httptest.NewRequest("POST", "/users/7", strings.NewReader(`{"email": "a@b.c", "tags": ["user", "admin"]}`))
+	rr = serve(req)
+	assert.Equal(t, 201, rr.Code, "the cookie condition must hold too")
+	req = httptest.NewRequest("POST", "/users/7", strings.NewReader(`{"email": "a@b.c", "tags": ["user", "admin"]}`))
+	req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+	rr = serve(req)
+	assert.Equal(t, 403, rr.Code)
+	assert.Equal(t, "forbidden", rr.Body.String())
+
+	rr = serve(httptest.NewRequest("POST", "/users/0", nil))
+	assert.Equal(t, 404, rr.Code)
+	assert.Empty(t, rr.Body.String())
+}
+
+func TestMatcher(t *testing.T) {
+	yes, no := true, false
+	equals := "42"
+
+	assert.True(t, Matcher{Equals: &equals}.matches([]string{"1", "42"}))
+	assert.False(t, Matcher{Equals: &equals}.matches(nil))
+	assert.True(t, Matcher{Regex: `^\d+$`}.matches([]string{"42"}))
+	assert.False(t, Matcher{Regex: `^\d+$`}.matches([]string{"x42"}))
+	assert.True(t, Matcher{Exists: &yes}.matches([]string{""}))
+	assert.True(t, Matcher{Exists: &no}.matches(nil))
+	assert.False(t, Matcher{Exists: &no}.matches([]string{"a"}))
+	assert.False(t, Matcher{Equals: &equals, Regex: "^4"}.matches([]string{"43", "4"}))
+
+	var m Matcher
+	require.NoError(t, json.Unmarshal([]byte(`42`), &m))
+	assert.Equal(t, "42", *m.Equals)
+	m = Matcher{}
+	require.NoError(t, json.Unmarshal([]byte(`{"exists": false}`), &m))
+	assert.False(t, *m.Exists)
+	assert.Error(t, Matcher{}.validate())
+	assert.Error(t, Matcher{Regex: "("}.validate())
+
+	hundred, ten := 100.0, 10.0
+	assert.True(t, Matcher{Gt: &hundred}.matches([]string{"100.5"}))
+	assert.False(t, Matcher{Gt: &hundred}.matches([]string{"100", "abc"}))
+	assert.True(t, Matcher{Gte: &ten, Lt: &hundred}.matches([]string{"10"}))
+	assert.False(t, Matcher{Lte: &ten}.matches([]string{"10.1"}))
+	assert.NoError(t, Matcher{Lt: &ten}.validate())
+	m = Matcher{}
+	require.NoError(t, json.Unmarshal([]byte(`{"gt": 100, "lte": 200}`), &m))
+	assert.Equal(t, 100.0, *m.Gt)
+	assert.Equal(t, 200.0, *m.Lte)
+	assert.Error(t, json.Unmarshal([]byte(`{"gt": "100"}`), &Matcher{}))
+
+	var document interface{}
+	require.NoError(t, json.Unmarshal([]byte(`{"user": {"name": "Ada"}, "items": [{"id": 1}, {"id": 2.5}], "ok": true}`), &document))
+	for expr, want := range map[string][]string{
+		"$.user.name":      {"Ada"},
+		"user['name']":     {"Ada"},
+		"$.items[*].id":    {"1", "2.5"},
+		"$.items[-1].id":   {"2.5"},
+		"$.ok":             {"true"},
+		"$.missing":        {},
+		"$.items[5].id":    {},
+		`$["user"].*`:      {"Ada"},
+	} {
+		steps, err := parseJSONPath(expr)
+		require.NoError(t, err, expr)
+		got := []string{}
+		for _, value := range evalJSONPath(document, steps) {
+			got = append(got, matchString(value))
+		}
+		assert.Equal(t, want, got, expr)
+	}
+
+	for _, expr := range []string{"$.items[", "$.items[x]", "$..name", "$x"} {
+		_, err := parseJSONPath(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestReadRequestBodyLimit(t *testing.T) {
+	r := httptest.NewRequest("POST", "/", strings.NewReader("small"))
+	assert.Equal(t, "small", string(readRequestBody(r)))
+	assert.Equal(t, "small", string(readRequestBody(r)), "the body stays readable")
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(strings.Repeat("a", maxRequestBody+1)))
+	assert.Nil(t, readRequestBody(r))
+	_, err := io.ReadAll(r.Body)
+	var tooLarge *http.MaxBytesError
+	assert.ErrorAs(t, err, &tooLarge)
+}
@@ -0,0 +1,395 @@
+// Package mocker serves mock REST APIs described by a Config: generated
+// fake data, literal bodies and files, stateful resources, auth, errors and
+// delays. New returns the http.Handler behind the apimocker command; the
+// mockertest package runs one for a Go test.
+package mocker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Server serves the current routing table and lets Reload and the admin
+// API replace it atomically while requests are in flight.
+type Server struct {
+	router atomic.Pointer[Router]
+	admin *Router
+	logger *Logger
+	logConfig LogConfig
+	port int
+	onUpdate func(messages []string)
+	journal *Journal
+	tlsConfig *tls.Config
+	tlsSettings *TLSConfig
+	http2 bool
+	ca *CertificateAuthority
+	// clientCAs holds the CAs of all mtls endpoints; client certificates
+	// are verified against them during the handshake.
+	clientCAs atomic.Pointer[x509.CertPool]
+	// adminConfig is the admin section of the current config.
+	adminConfig atomic.Pointer[AdminConfig]
+	// oauth is the built-in OAuth provider, created for the first config
+	// with an oauth section and kept across reloads.
+	oauth *oauthProvider
+
+	// mu guards the runtime state below. base is the config as loaded,
+	// config adds the changes made through the admin API.
+	mu sync.Mutex
+	base *Config
+	config *Config
+	ids []int
+	nextID int
+	settings AdminSettings
+	// pending holds the messages of an update made under mu, handed to
+	// onUpdate by unlock.
+	pending []string
+	updated bool
+}
+
+// AdminSettings are runtime switches applied on top of every endpoint.
+type AdminSettings struct {
+	ErrorsEnabled bool `json:"errors_enabled"`
+	Delay string `json:"delay"`
+}
+
+func NewServer(config *Config) (*Server, []string, error) {
+	logger, err := NewLogger(config.Logging)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create logger: %v", err)
+	}
+
+	server := &Server{
+		logger: logger,
+		logConfig: config.Logging,
+		port: config.Port,
+		settings: AdminSettings{ErrorsEnabled: true},
+		journal: NewJournal(config.Journal.Limit),
+		http2: config.HTTP2 == nil || *config.HTTP2,
+	}
+	router, messages, err := server.build(config, server.settings, logger, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	server.base, server.config = config, cloneConfig(config)
+	server.resetIDs()
+	server.router.Store(router)
+	server.adminConfig.Store(config.Admin)
+
+	if config.TLS != nil {
+		server.tlsSettings = config.TLS
+		server.tlsConfig, server.ca, err = buildTLSConfig(*config.TLS)
+		if err != nil {
+			return nil, nil, err
+		}
+		server.tlsConfig.GetConfigForClient = server.clientTLSConfig
+		server.tlsConfig.NextProtos = []string{"http/1.1"}
+		if server.http2 {
+			server.tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+		}
+		tlsMsg := fmt.Sprintf("HTTPS: %s", serverURLs(config)[len(serverURLs(config))-1])
+		if server.ca != nil {
+			tlsMsg += fmt.Sprintf(" (CA: %sca.pem)", adminPrefix)
+		}
+		messages = append(messages, tlsMsg)
+	}
+
+	server.admin = NewRouter(nil)
+	server.admin.SetFallback(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A wrong method on an admin path must not reach the endpoints or
+		// the proxy; the scenario routes live on the endpoint router.
+		if matches := server.admin.lookup(r.URL.Path); len(matches) > 0 {
+			w.Header().Set("Allow", strings.Join(allowedMethods(matches), ", "))
+			adminRespond(w, http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+			return
+		}
+		server.router.Load().ServeHTTP(w, r)
+	}))
+	admin := []struct {
+		pattern string
+		methods []string
+		handler http.HandlerFunc
+	}{
+		{adminPrefix + "endpoints", []string{http.MethodGet, http.MethodPost}, server.handleEndpoints},
+		{adminPrefix + "endpoints/{id:[0-9]+}", []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete}, server.handleEndpoint},
+		{adminPrefix + "settings", []string{http.MethodGet, http.MethodPatch}, server.handleSettings},
+		{adminPrefix + "reset", []string{http.MethodPost}, server.handleReset},
+		{adminPrefix + "requests", []string{http.MethodGet, http.MethodDelete}, server.handleRequests},
+		{adminPrefix + "verify", []string{http.MethodPost}, server.handleVerify},
+		{adminPrefix + "ca.pem", []string{http.MethodGet}, server.handleCA},
+	}
+	for _, route := range admin {
+		for _, method := range route.methods {
+			if err := server.admin.Handle(route.pattern, method, route.handler); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return server, messages, nil
+}
+
+// Reload swaps in the endpoints of config, dropping changes made through
+// the admin API. Unchanged endpoints keep their resources, sequences and
+// scenario states. On error the previous routing table stays live. The
+// listening port cannot change without a restart.
+func (s *Server) Reload(config *Config) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	logger := s.logger
+	if config.Logging != s.logConfig {
+		newLogger, err := NewLogger(config.Logging)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create logger: %v", err)
+		}
+		logger = newLogger
+	}
+
+	requestedPort := config.Port
+	config.Port = s.port
+	router, messages, err := s.build(config, s.settings, logger, s.router.Load())
+	if err != nil {
+		if logger != s.logger {
+			logger.Close()
+		}
+		return nil, err
+	}
+
+	s.router.Store(router)
+	s.adminConfig.Store(config.Admin)
+	s.base, s.config = config, cloneConfig(config)
+	s.resetIDs()
+	s.journal.SetLimit(config.Journal.Limit)
+	if logger != s.logger {
+		s.logger.Close()
+		s.logger, s.logConfig = logger, config.Logging
+	}
+
+	if !reflect.DeepEqual(config.TLS, s.tlsSettings) {
+		messages = append(messages, "TLS changes require a restart")
+	}
+	if http2 := config.HTTP2 == nil || *config.HTTP2; http2 != s.http2 {
+		messages = append(messages, "HTTP/2 changes require a restart")
+	}
+	if requestedPort != s.port {
+		messages = append(messages, fmt.Sprintf("Port change to %d requires a restart (still serving on %d)", requestedPort, s.port))
+	}
+	return messages, nil
+}
+
+// ServeHTTP answers admin API requests, if the admin API is on, and
+// journals every other request with the status it was answered with.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, adminPrefix) {
+		if s.authorizeAdmin(w, r) {
+			s.admin.ServeHTTP(w, r)
+		}
+		return
+	}
+
+	body, truncated := peekRequestBody(r, maxJournalBody)
+	entry := &JournalEntry{
+		Timestamp: time.Now().Format(time.RFC3339),
+		Method: r.Method,
+		Protocol: r.Proto,
+		Path: r.URL.Path,
+		Query: r.URL.Query(),
+		Headers: r.Header.Clone(),
+		Body: string(body),
+		Truncated: truncated,
+	}
+	sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+	s.admin.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), journalKey{}, entry)))
+	entry.Status = sw.status
+	s.journal.Add(*entry)
+}
+
+// OnUpdate registers fn to receive the endpoint messages after every
+// change made through the admin API.
+func (s *Server) OnUpdate(fn func(messages []string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onUpdate = fn
+}
+
+// build creates the routing table for config with settings applied. It
+// takes over the runtime state of unchanged endpoints from previous, if
+// given.
+func (s *Server) build(config *Config, settings AdminSettings, logger *Logger, previous *Router) (*Router, []string, error) {
+	effective := cloneConfig(config)
+	for i := range effective.Endpoints {
+		ep := &effective.Endpoints[i]
+		if !settings.ErrorsEnabled {
+			ep.Errors = nil
+		}
+		if settings.Delay != "" {
+			ep.Delay = settings.Delay
+			rules := make([]ResponseRule, len(ep.Responses))
+			for j, rule := range ep.Responses {
+				rule.Delay = ""
+				rules[j] = rule
+			}
+			ep.Responses = rules
+			if ep.Sequence != nil {
+				sequence := *ep.Sequence
+				sequence.Steps = make([]Response, len(ep.Sequence.Steps))
+				for j, step := range ep.Sequence.Steps {
+					step.Delay = ""
+					sequence.Steps[j] = step
+				}
+				ep.Sequence = &sequence
+			}
+		}
+	}
+	if config.OAuth != nil {
+		if s.oauth == nil {
+			provider, err := newOAuthProvider()
+			if err != nil {
+				return nil, nil, err
+			}
+			s.oauth = provider
+		}
+		s.oauth.configure(config.OAuth)
+	}
+	// jwt keys are loaded here rather than on every request; endpoints
+	// without a key of their own use the OAuth provider's.
+	for i := range effective.Endpoints {
+		ep := &effective.Endpoints[i]
+		if ep.Auth == nil || !strings.EqualFold(ep.Auth.Type, "jwt") {
+			continue
+		}
+		auth := *ep.Auth
+		if auth.Secret == "" && auth.PublicKey == "" && auth.JWKS == "" && s.oauth != nil && config.OAuth != nil {
+			auth.keys = s.oauth.keys()
+		} else {
+			keys, err := loadJWTKeys(&auth)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load jwt keys of %s %s: %v", ep.Method, ep.Path, err)
+			}
+			auth.keys = keys
+		}
+		ep.Auth = &auth
+	}
+	// mtls CA files are read once per build, so a reload picks up changes.
+	var clientCAs *x509.CertPool
+	pools := make(map[string]*x509.CertPool)
+	for i := range effective.Endpoints {
+		ep := &effective.Endpoints[i]
+		if ep.Auth == nil || !strings.EqualFold(ep.Auth.Type, "mtls") {
+			continue
+		}
+		pool, ok := pools[ep.Auth.CA]
+		if !ok {
+			var data []byte
+			var err error
+			pool, data, err = loadClientCAs(ep.Auth.CA)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load mtls CA of %s %s: %v", ep.Method, ep.Path, err)
+			}
+			pools[ep.Auth.CA] = pool
+			if clientCAs == nil {
+				clientCAs = x509.NewCertPool()
+			}
+			clientCAs.AppendCertsFromPEM(data)
+		}
+		auth := *ep.Auth
+		auth.clientCAs = pool
+		ep.Auth = &auth
+	}
+	router, messages, err := rebuildRouter(effective, logger, previous)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config.OAuth != nil {
+		if err := s.oauth.register(router); err != nil {
+			return nil, nil, fmt.Errorf("failed to register OAuth provider: %v", err)
+		}
+		messages = append(messages, fmt.Sprintf("OAuth: %s/.well-known/openid-configuration", serverURLs(config)[0]))
+	}
+	if config.Admin != nil && config.Admin.Enabled {
+		adminMsg := fmt.Sprintf("Admin API: %s%s", serverURLs(config)[0], adminPrefix)
+		if config.Admin.Token != "" {
+			adminMsg += " (bearer token required)"
+		}
+		messages = append(messages, adminMsg)
+	}
+
+	s.clientCAs.Store(clientCAs)
+	return router, messages, nil
+}
+
+// update validates config and makes it live. Unchanged endpoints keep their
+// resources, sequences and scenario states unless reset is set. s.mu must
+// be held; unlock passes the new messages on to onUpdate.
+func (s *Server) update(config *Config, settings AdminSettings, reset bool) error {
+	if errs := validateConfigValue("admin API", config, nil); len(errs) > 0 {
+		return errs
+	}
+	for i := range config.Endpoints {
+		applyEndpointDefaults(&config.Endpoints[i])
+	}
+
+	var previous *Router
+	if !reset {
+		previous = s.router.Load()
+	}
+	router, messages, err := s.build(config, settings, s.logger, previous)
+	if err != nil {
+		return err
+	}
+	s.router.Store(router)
+	s.config, s.settings = config, settings
+	s.pending, s.updated = messages, true
+	return nil
+}
+
+// unlock releases s.mu and then notifies onUpdate of the last update, so a
+// slow or re-entrant callback does not hold up the server.
+func (s *Server) unlock() {
+	messages, updated, notify := s.pending, s.updated, s.onUpdate
+	s.pending, s.updated = nil, false
+	s.mu.Unlock()
+	if updated && notify != nil {
+		notify(messages)
+	}
+}
+
+// serverURLs lists the base URLs config is served on: HTTPS on port when
+// TLS is enabled, or HTTP on port and HTTPS on tls.port.
+func serverURLs(config *Config) []string {
+	switch {
+	case config.TLS == nil:
+		return []string{fmt.Sprintf("http://localhost:%d", config.Port)}
+	case config.TLS.Port == 0:
+		return []string{fmt.Sprintf("https://localhost:%d", config.Port)}
+	}
+	return []string{fmt.Sprintf("http://localhost:%d", config.Port), fmt.Sprintf("https://localhost:%d", config.TLS.Port)}
+}
+
+// New validates config and returns a Server for it, ready to be used as an
+// http.Handler. Endpoint defaults are applied as for a config file
+// (method GET, status 200, count 1); config itself is left unchanged.
+func New(config *Config) (*Server, error) {
+	config = cloneConfig(config)
+	if errs := validateConfigValue("mocker.New", config, nil); len(errs) > 0 {
+		return nil, errs
+	}
+	for i := range config.Endpoints {
+		applyEndpointDefaults(&config.Endpoints[i])
+	}
+
+	server, _, err := NewServer(config)
+	return server, err
+}
+
+// Requests returns the journaled requests, oldest first.
+func (s *Server) Requests() []JournalEntry {
+	return s.journal.Entries()
+}
@@ -0,0 +1,158 @@
+package mocker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServerReload(t *testing.T) {
+	config := &Config{
+		Port: 8080,
+		Endpoints: []Endpoint{
+			{Path: "/users", Method: "GET", Status: 200, Count: 1, Data: `{"id": "uuid"}`},
+		},
+	}
+	server, messages, err := NewServer(config)
+	require.NoError(t, err)
+	assert.Len(t, messages, 1)
+
+	get := func(path string) int {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr.Code
+	}
+	assert.Equal(t, 200, get("/users"))
+	assert.Equal(t, 404, get("/orders"))
+
+	messages, err = server.Reload(&Config{
+		Port: 9090,
+		Endpoints: []Endpoint{
+			{Path: "/orders", Method: "GET", Status: 201, Count: 1, Data: `{"id": "uuid"}`},
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 404, get("/users"))
+	assert.Equal(t, 201, get("/orders"))
+	assert.Contains(t, messages[0], "localhost:8080/orders")
+	assert.Contains(t, messages[len(messages)-1], "requires a restart")
+
+	_, err = server.Reload(&Config{
+		Endpoints: []Endpoint{
+			{Path: "/orders", Method: "GET", Status: 200},
+			{Path: "/orders", Method: "GET", Status: 200},
+		},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 201, get("/orders"), "previous config should stay live")
+}
+
+func TestServerReloadKeepsState(t *testing.T) {
+	config := NewConfig(Resource("/notes").Data(`{"id": "int", "text": "sentence"}`).Count(1))
+	server, err := New(config)
+	require.NoError(t, err)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rr
+	}
+	require.Equal(t, 201, do("POST", "/notes", `{"text": "kept"}`).Code)
+	// Reload takes configs as LoadConfig returns them, with defaults applied.
+	reload := func(endpoints ...*EndpointBuilder) {
+		config := NewConfig(endpoints...)
+		for i := range config.Endpoints {
+			applyEndpointDefaults(&config.Endpoints[i])
+		}
+		_, err := server.Reload(config)
+		require.NoError(t, err)
+	}
+
+	reload(
+		Resource("/notes").Data(`{"id": "int", "text": "sentence"}`).Count(1).Header("X-Version", "2"),
+		GET("/other").Body("x"),
+	)
+	notes := do("GET", "/notes", "")
+	assert.Equal(t, "2", notes.Header().Get("X-Version"))
+	assert.Contains(t, notes.Body.String(), "kept", "unrelated edits keep the records")
+
+	reload(Resource("/notes").Data(`{"id": "int", "text": "sentence"}`).Count(2))
+	assert.NotContains(t, do("GET", "/notes", "").Body.String(), "kept", "a new count re-seeds")
+}
+
+func TestNew(t *testing.T) {
+	yes, found := true, `[{"id": 1}]`
+	config := NewConfig(
+		GET("/users/{id}").Data(`{"id": "int", "name": "name"}`).Single().Seed(1),
+		POST("/users").Status(201).Header("X-Created", "yes").Body(`{"created": true}`),
+		On("get", "/search").Body(`[]`).When(MatchConfig{Query: map[string]Matcher{"q": {Exists: &yes}}}, Response{Body: &found}),
+		GET("/jobs/1").Sequence(Response{Status: 202}, Response{Status: 200}).Body("done"),
+		Resource("/notes").Data(`{"id": "uuid", "text": "sentence"}`).Count(2),
+		GET("/secret").BearerAuth("token").Body("ok"),
+	)
+	server, err := New(config)
+	require.NoError(t, err)
+	assert.Equal(t, "get", config.Endpoints[2].Method, "config is left unchanged")
+
+	do := func(method, path string, headers ...string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		server.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := do("GET", "/users/7")
+	assert.Equal(t, 200, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"id":7`)
+	rr = do("POST", "/users")
+	assert.Equal(t, 201, rr.Code)
+	assert.Equal(t, "yes", rr.Header().Get("X-Created"))
+	assert.Equal(t, `[]`, do("GET", "/search").Body.String())
+	assert.JSONEq(t, `[{"id": 1}]`, do("GET", "/search?q=a").Body.String())
+	assert.Equal(t, 202, do("GET", "/jobs/1").Code)
+	assert.Equal(t, 200, do("GET", "/jobs/1").Code)
+	assert.Equal(t, 401, do("GET", "/secret").Code)
+	assert.Equal(t, 200, do("GET", "/secret", "Authorization", "Bearer token").Code)
+	var notes []map[string]interface{}
+	require.NoError(t, json.Unmarshal(do("GET", "/notes").Body.Bytes(), &notes))
+	assert.Len(t, notes, 2)
+	assert.Len(t, server.Requests(), 9)
+
+	_, err = New(NewConfig(GET("/a").Delay("soon"), GET("/a")))
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	require.Len(t, errs, 2)
+	assert.Equal(t, `mocker.New: endpoints[0].delay: invalid delay "soon" (expected e.g. 300ms, 2s, 1m or 1h30m)`, errs[0].Error())
+	assert.Equal(t, `mocker.New: endpoints[1]: duplicate endpoint GET /a`, errs[1].Error())
+}
+
+// testServer is a Server listening on a local httptest.Server, started
+// like mockertest.Start, which these tests cannot import.
+type testServer struct {
+	*httptest.Server
+	Mock *Server
+}
+
+func startTest(t *testing.T, config *Config) *testServer {
+	t.Helper()
+	server, err := New(config)
+	require.NoError(t, err)
+	ts := httptest.NewUnstartedServer(server)
+	ts.Config.Protocols = server.Protocols()
+	ts.EnableHTTP2 = server.http2
+	if tlsConfig := server.TLSConfig(); tlsConfig != nil {
+		ts.TLS = tlsConfig.Clone()
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	t.Cleanup(ts.Close)
+	return &testServer{Server: ts, Mock: server}
+}
@@ -0,0 +1,46 @@
+// Package mockertest runs a mocker.Server on a local httptest.Server for Go
+// tests. It lives apart from mocker so that programs embedding the mock
+// server do not link the testing packages.
+package mockertest
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Hanashiko/apimocker/mocker"
+)
+
+// Server is a mocker.Server listening on a local httptest.Server.
+type Server struct {
+	*httptest.Server
+	Mock *mocker.Server
+}
+
+// Start serves config on an httptest.Server that is closed when t
+// finishes, over HTTPS if config has a tls section; ts.Client() trusts it.
+// The admin API is on unless config has an admin section, since the
+// server only listens on the loopback interface. An invalid config fails t
+// immediately.
+func Start(t testing.TB, config *mocker.Config) *Server {
+	t.Helper()
+	if config.Admin == nil {
+		withAdmin := *config
+		withAdmin.Admin = &mocker.AdminConfig{Enabled: true}
+		config = &withAdmin
+	}
+	server, err := mocker.New(config)
+	if err != nil {
+		t.Fatalf("mocker: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(server)
+	ts.Config.Protocols = server.Protocols()
+	ts.EnableHTTP2 = server.Protocols().HTTP2()
+	if tlsConfig := server.TLSConfig(); tlsConfig != nil {
+		ts.TLS = tlsConfig.Clone()
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	t.Cleanup(ts.Close)
+	return &Server{Server: ts, Mock: server}
+}
@@ -0,0 +1,47 @@
+package mockertest
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/Hanashiko/apimocker/mocker"
+)
+
+func TestStart(t *testing.T) {
+	ts := Start(t, mocker.NewConfig(
+		mocker.POST("/orders").Status(201).Body(`{"id": "{{ .Body.id }}"}`).Template(),
+	))
+
+	resp, err := http.Post(ts.URL+"/orders", "application/json", strings.NewReader(`{"id": "A1"}`))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, `{"id": "A1"}`, string(body))
+
+	requests := ts.Mock.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "POST /orders", requests[0].Endpoint)
+	assert.Equal(t, `{"id": "A1"}`, requests[0].Body)
+}
+
+func TestStartTLS(t *testing.T) {
+	dir := t.TempDir()
+	config := mocker.NewConfig(mocker.GET("/ping").Body("pong"))
+	config.TLS = &mocker.TLSConfig{Auto: true, CACert: filepath.Join(dir, "ca.pem"), CAKey: filepath.Join(dir, "ca-key.pem")}
+	ts := Start(t, config)
+	require.True(t, strings.HasPrefix(ts.URL, "https://"))
+
+	resp, err := ts.Client().Get(ts.URL + "/ping")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "pong", string(body))
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+}
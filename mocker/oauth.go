@@ -0,0 +1,595 @@
+package mocker
+
+import (
+	"crypto"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html"
+	"math/big"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthPaths are served by the built-in OAuth provider when the config has
+// an oauth section.
+var oauthPaths = []string{"/.well-known/openid-configuration", "/jwks.json", "/authorize", "/token", "/userinfo"}
+
+// oauthCodeTTL is how long an authorization code can be exchanged.
+const oauthCodeTTL = 5 * time.Minute
+
+// oauthRefreshTTL is how long a refresh token can be used. Every refresh
+// issues a new one, so sessions in use do not run out.
+const oauthRefreshTTL = 24 * time.Hour
+
+// defaultTokenTTL is the lifetime of issued tokens without oauth.token_ttl.
+const defaultTokenTTL = time.Hour
+
+// defaultOAuthUser signs in when the oauth section lists no users.
+var defaultOAuthUser = OAuthUser{
+	Username: "user",
+	Password: "password",
+	Claims: map[string]interface{}{"name": "Test User", "email": "user@example.com"},
+}
+
+// oauthGrant is what an authorization code or refresh token stands for. A
+// grant without a user is a client_credentials grant.
+type oauthGrant struct {
+	clientID string
+	redirectURI string
+	challenge string
+	challengeMethod string
+	user *OAuthUser
+	scope string
+	nonce string
+	authTime int64
+	expires time.Time
+}
+
+// oauthProvider is the built-in authorization server. Its signing key and
+// grants outlive reloads, so tokens stay valid while the config changes.
+type oauthProvider struct {
+	key *rsa.PrivateKey
+	kid string
+
+	mu sync.Mutex
+	config *OAuthConfig
+	codes map[string]oauthGrant
+	refreshTokens map[string]oauthGrant
+}
+
+func newOAuthProvider() (*oauthProvider, error) {
+	key, err := rsa.GenerateKey(cryptorand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OAuth signing key: %v", err)
+	}
+	thumbprint := sha256.Sum256(key.N.Bytes())
+	return &oauthProvider{
+		key: key,
+		kid: base64.RawURLEncoding.EncodeToString(thumbprint[:8]),
+		config: &OAuthConfig{},
+		codes: map[string]oauthGrant{},
+		refreshTokens: map[string]oauthGrant{},
+	}, nil
+}
+
+func (p *oauthProvider) configure(config *OAuthConfig) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.config = config
+}
+
+func (p *oauthProvider) settings() *OAuthConfig {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.config
+}
+
+// keys returns the key that verifies the provider's tokens.
+func (p *oauthProvider) keys() []jwtKey {
+	return []jwtKey{{kid: p.kid, key: &p.key.PublicKey}}
+}
+
+// register serves the provider endpoints on router.
+func (p *oauthProvider) register(router *Router) error {
+	routes := []struct {
+		path string
+		methods []string
+		handler http.HandlerFunc
+	}{
+		{"/.well-known/openid-configuration", []string{http.MethodGet}, p.handleDiscovery},
+		{"/jwks.json", []string{http.MethodGet}, p.handleJWKS},
+		{"/authorize", []string{http.MethodGet, http.MethodPost}, p.handleAuthorize},
+		{"/token", []string{http.MethodPost}, p.handleToken},
+		{"/userinfo", []string{http.MethodGet, http.MethodPost}, p.handleUserinfo},
+	}
+	for _, route := range routes {
+		for _, method := range route.methods {
+			if err := router.Handle(route.path, method, route.handler); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// issuer is oauth.issuer or, by default, the URL the request was sent to.
+func (p *oauthProvider) issuer(r *http.Request) string {
+	if issuer := p.settings().Issuer; issuer != "" {
+		return strings.TrimSuffix(issuer, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+func (p *oauthProvider) tokenTTL() time.Duration {
+	if ttl, err := time.ParseDuration(p.settings().TokenTTL); err == nil && ttl > 0 {
+		return ttl
+	}
+	return defaultTokenTTL
+}
+
+// client looks up a registered client. Without registered clients any
+// client ID is accepted as a public client.
+func (p *oauthProvider) client(id string) (OAuthClient, bool) {
+	config := p.settings()
+	if len(config.Clients) == 0 {
+		return OAuthClient{ID: id}, id != ""
+	}
+	for _, client := range config.Clients {
+		if client.ID == id {
+			return client, true
+		}
+	}
+	return OAuthClient{}, false
+}
+
+func (p *oauthProvider) users() []OAuthUser {
+	if users := p.settings().Users; len(users) > 0 {
+		return users
+	}
+	return []OAuthUser{defaultOAuthUser}
+}
+
+func (p *oauthProvider) user(username string) *OAuthUser {
+	for _, user := range p.users() {
+		if user.Username == username {
+			return &user
+		}
+	}
+	return nil
+}
+
+// login returns the user with these credentials, or nil.
+func (p *oauthProvider) login(username, password string) *OAuthUser {
+	if user := p.user(username); user != nil && user.Password == password {
+		return user
+	}
+	return nil
+}
+
+// take removes and returns the unexpired grant stored under token.
+func (p *oauthProvider) take(grants map[string]oauthGrant, token string) (oauthGrant, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	grant, ok := grants[token]
+	delete(grants, token)
+	if !ok || (!grant.expires.IsZero() && time.Now().After(grant.expires)) {
+		return oauthGrant{}, false
+	}
+	return grant, true
+}
+
+// store saves grant under a new token and drops the expired grants, so
+// codes and refresh tokens that are never used do not pile up.
+func (p *oauthProvider) store(grants map[string]oauthGrant, grant oauthGrant) string {
+	token := randomToken()
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, stored := range grants {
+		if !stored.expires.IsZero() && now.After(stored.expires) {
+			delete(grants, key)
+		}
+	}
+	grants[token] = grant
+	return token
+}
+
+// sign returns an RS256 JWT for claims.
+func (p *oauthProvider) sign(claims map[string]interface{}) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": p.kid})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(cryptorand.Reader, p.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+func randomToken() string {
+	data := make([]byte, 32)
+	cryptorand.Read(data)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func hasScope(scope, name string) bool {
+	for _, field := range strings.Fields(scope) {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// pkceVerified checks a code_verifier against the code_challenge the code
+// was requested with.
+func pkceVerified(grant oauthGrant, verifier string) bool {
+	if grant.challengeMethod == "S256" {
+		digest := sha256.Sum256([]byte(verifier))
+		return base64.RawURLEncoding.EncodeToString(digest[:]) == grant.challenge
+	}
+	return verifier == grant.challenge
+}
+
+// oauthError answers with an RFC 6749 error response.
+func oauthError(w http.ResponseWriter, statusCode int, code, description string) {
+	adminRespond(w, statusCode, map[string]string{"error": code, "error_description": description})
+}
+
+// handleDiscovery serves GET /.well-known/openid-configuration.
+func (p *oauthProvider) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	issuer := p.issuer(r)
+	adminRespond(w, http.StatusOK, map[string]interface{}{
+		"issuer": issuer,
+		"authorization_endpoint": issuer + "/authorize",
+		"token_endpoint": issuer + "/token",
+		"userinfo_endpoint": issuer + "/userinfo",
+		"jwks_uri": issuer + "/jwks.json",
+		"response_types_supported": []string{"code"},
+		"grant_types_supported": []string{"authorization_code", "client_credentials", "refresh_token", "password"},
+		"subject_types_supported": []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"scopes_supported": []string{"openid", "profile", "email", "offline_access"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_basic", "client_secret_post", "none"},
+		"code_challenge_methods_supported": []string{"S256", "plain"},
+	})
+}
+
+// handleJWKS serves GET /jwks.json, the public key tokens are signed with.
+func (p *oauthProvider) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	adminRespond(w, http.StatusOK, map[string]interface{}{"keys": []jwk{{
+		Kty: "RSA",
+		Kid: p.kid,
+		Alg: "RS256",
+		Use: "sig",
+		N: base64.RawURLEncoding.EncodeToString(p.key.N.Bytes()),
+		E: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.key.E)).Bytes()),
+	}}})
+}
+
+// handleAuthorize serves the authorization code flow. GET shows a sign-in
+// form, or signs in the user named by login_hint right away; the form
+// posts back here. Errors in client_id or redirect_uri are answered
+// directly, all others are sent to the redirect URI.
+func (p *oauthProvider) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	params := r.Form
+	clientID := params.Get("client_id")
+	client, ok := p.client(clientID)
+	if !ok {
+		oauthError(w, http.StatusBadRequest, "invalid_client", fmt.Sprintf("unknown client %q", clientID))
+		return
+	}
+	redirectURI := params.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	target, err := url.Parse(redirectURI)
+	if err != nil || !target.IsAbs() {
+		oauthError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("redirect_uri %q must be an absolute URL", redirectURI))
+		return
+	}
+	if len(client.RedirectURIs) > 0 {
+		registered := false
+		for _, uri := range client.RedirectURIs {
+			registered = registered || uri == redirectURI
+		}
+		if !registered {
+			oauthError(w, http.StatusBadRequest, "invalid_request", fmt.Sprintf("redirect_uri %q is not registered for client %q", redirectURI, clientID))
+			return
+		}
+	}
+	redirect := func(values map[string]string) {
+		query := target.Query()
+		for name, value := range values {
+			query.Set(name, value)
+		}
+		if state := params.Get("state"); state != "" {
+			query.Set("state", state)
+		}
+		target.RawQuery = query.Encode()
+		http.Redirect(w, r, target.String(), http.StatusFound)
+	}
+
+	if responseType := params.Get("response_type"); responseType != "code" {
+		redirect(map[string]string{"error": "unsupported_response_type", "error_description": fmt.Sprintf("response_type %q is not supported, use code", responseType)})
+		return
+	}
+	challenge, method := params.Get("code_challenge"), params.Get("code_challenge_method")
+	if challenge != "" && method == "" {
+		method = "plain"
+	}
+	if method != "" && method != "plain" && method != "S256" {
+		redirect(map[string]string{"error": "invalid_request", "error_description": fmt.Sprintf("code_challenge_method %q is not supported", method)})
+		return
+	}
+	if method != "" && challenge == "" {
+		redirect(map[string]string{"error": "invalid_request", "error_description": "code_challenge is required with code_challenge_method"})
+		return
+	}
+
+	var user *OAuthUser
+	if r.Method == http.MethodPost {
+		if user = p.login(params.Get("username"), params.Get("password")); user == nil {
+			p.loginForm(w, r, client, "Invalid username or password")
+			return
+		}
+	} else if user = p.user(params.Get("login_hint")); user == nil {
+		p.loginForm(w, r, client, "")
+		return
+	}
+
+	code := p.store(p.codes, oauthGrant{
+		clientID: clientID,
+		redirectURI: params.Get("redirect_uri"),
+		challenge: challenge,
+		challengeMethod: method,
+		user: user,
+		scope: params.Get("scope"),
+		nonce: params.Get("nonce"),
+		authTime: time.Now().Unix(),
+		expires: time.Now().Add(oauthCodeTTL),
+	})
+	redirect(map[string]string{"code": code})
+}
+
+// oauthLoginPage is the sign-in form of /authorize. It posts the
+// authorization request back along with the credentials.
+const oauthLoginPage = `<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in - apimocker</title></head>
+<body>
+<h1>Sign in to %s</h1>
+%s<form method="post" action="%s">
+%s<p><label>Username <input name="username" value="%s" autofocus></label></p>
+<p><label>Password <input name="password" type="password"></label></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+<p>Users: %s</p>
+</body>
+</html>
+`
+
+func (p *oauthProvider) loginForm(w http.ResponseWriter, r *http.Request, client OAuthClient, message string) {
+	if message != "" {
+		message = fmt.Sprintf("<p><strong>%s</strong></p>\n", html.EscapeString(message))
+	}
+	names := make([]string, 0, len(r.Form))
+	for name := range r.Form {
+		if name != "username" && name != "password" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var hidden strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&hidden, "<input type=\"hidden\" name=\"%s\" value=\"%s\">\n", html.EscapeString(name), html.EscapeString(r.Form.Get(name)))
+	}
+	var usernames []string
+	for _, user := range p.users() {
+		usernames = append(usernames, html.EscapeString(user.Username))
+	}
+	username := r.Form.Get("username")
+	if username == "" {
+		username = r.Form.Get("login_hint")
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	statusCode := http.StatusOK
+	if r.Method == http.MethodPost {
+		statusCode = http.StatusUnauthorized
+	}
+	w.WriteHeader(statusCode)
+	fmt.Fprintf(w, oauthLoginPage, html.EscapeString(client.ID), message, html.EscapeString(r.URL.Path),
+		hidden.String(), html.EscapeString(username), strings.Join(usernames, ", "))
+}
+
+// handleToken serves POST /token for the authorization_code,
+// refresh_token, password and client_credentials grants. Clients
+// authenticate with HTTP Basic or client_id and client_secret form fields;
+// public clients send client_id alone.
+func (p *oauthProvider) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		oauthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	form := r.PostForm
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		if id, err := url.QueryUnescape(clientID); err == nil {
+			clientID = id
+		}
+		if value, err := url.QueryUnescape(secret); err == nil {
+			secret = value
+		}
+	} else {
+		clientID, secret = form.Get("client_id"), form.Get("client_secret")
+	}
+	client, ok := p.client(clientID)
+	if !ok || (len(p.settings().Clients) > 0 && secret != client.Secret) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="apimocker"`)
+		}
+		oauthError(w, http.StatusUnauthorized, "invalid_client", "client authentication failed")
+		return
+	}
+
+	var grant oauthGrant
+	switch grantType := form.Get("grant_type"); grantType {
+	case "authorization_code":
+		grant, ok = p.take(p.codes, form.Get("code"))
+		if !ok || grant.clientID != clientID {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "invalid or expired code")
+			return
+		}
+		if grant.redirectURI != form.Get("redirect_uri") {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "redirect_uri does not match the authorization request")
+			return
+		}
+		if grant.challenge != "" && !pkceVerified(grant, form.Get("code_verifier")) {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code_challenge")
+			return
+		}
+	case "refresh_token":
+		grant, ok = p.take(p.refreshTokens, form.Get("refresh_token"))
+		if !ok || grant.clientID != clientID {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "invalid refresh_token")
+			return
+		}
+	case "password":
+		user := p.login(form.Get("username"), form.Get("password"))
+		if user == nil {
+			oauthError(w, http.StatusBadRequest, "invalid_grant", "invalid username or password")
+			return
+		}
+		grant = oauthGrant{clientID: clientID, user: user, scope: form.Get("scope"), authTime: time.Now().Unix()}
+	case "client_credentials":
+		if client.Secret == "" && len(p.settings().Clients) > 0 {
+			oauthError(w, http.StatusBadRequest, "unauthorized_client", "client_credentials requires a client with a secret")
+			return
+		}
+		grant = oauthGrant{clientID: clientID, scope: form.Get("scope")}
+	case "":
+		oauthError(w, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		oauthError(w, http.StatusBadRequest, "unsupported_grant_type", fmt.Sprintf("grant_type %q is not supported", grantType))
+		return
+	}
+	p.respondTokens(w, r, grant)
+}
+
+// respondTokens issues an access token for grant and, when it has a user,
+// a refresh token and, for the openid scope, an ID token.
+func (p *oauthProvider) respondTokens(w http.ResponseWriter, r *http.Request, grant oauthGrant) {
+	now := time.Now()
+	ttl := p.tokenTTL()
+	issuer := p.issuer(r)
+	claims := func() map[string]interface{} {
+		claims := map[string]interface{}{}
+		subject := grant.clientID
+		if grant.user != nil {
+			for name, value := range grant.user.Claims {
+				claims[name] = value
+			}
+			subject = grant.user.Username
+		}
+		claims["iss"] = issuer
+		claims["sub"] = subject
+		claims["aud"] = grant.clientID
+		claims["iat"] = now.Unix()
+		claims["exp"] = now.Add(ttl).Unix()
+		return claims
+	}
+
+	accessClaims := claims()
+	accessClaims["client_id"] = grant.clientID
+	accessClaims["jti"] = randomToken()
+	if grant.scope != "" {
+		accessClaims["scope"] = grant.scope
+	}
+	accessToken, err := p.sign(accessClaims)
+	if err != nil {
+		oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+		return
+	}
+	body := map[string]interface{}{
+		"access_token": accessToken,
+		"token_type": "Bearer",
+		"expires_in": int(ttl.Seconds()),
+	}
+	if grant.scope != "" {
+		body["scope"] = grant.scope
+	}
+
+	if grant.user != nil {
+		if hasScope(grant.scope, "openid") {
+			idClaims := claims()
+			idClaims["auth_time"] = grant.authTime
+			if grant.nonce != "" {
+				idClaims["nonce"] = grant.nonce
+			}
+			idToken, err := p.sign(idClaims)
+			if err != nil {
+				oauthError(w, http.StatusInternalServerError, "server_error", err.Error())
+				return
+			}
+			body["id_token"] = idToken
+		}
+		refresh := grant
+		refresh.redirectURI, refresh.challenge, refresh.challengeMethod, refresh.nonce = "", "", "", ""
+		refresh.expires = now.Add(oauthRefreshTTL)
+		body["refresh_token"] = p.store(p.refreshTokens, refresh)
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	adminRespond(w, http.StatusOK, body)
+}
+
+// handleUserinfo serves /userinfo: the subject of a valid access token and
+// the claims of its user.
+func (p *oauthProvider) handleUserinfo(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	ok, _, result := authenticateJWT(authHeader, &AuthConfig{Type: "jwt", keys: p.keys()})
+	if !ok {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="invalid_token", error_description="%s"`, result))
+		oauthError(w, http.StatusUnauthorized, "invalid_token", result)
+		return
+	}
+	var claims struct {
+		Subject string `json:"sub"`
+	}
+	payload, _ := base64.RawURLEncoding.DecodeString(strings.Split(authHeader, ".")[1])
+	json.Unmarshal(payload, &claims)
+
+	info := map[string]interface{}{}
+	if user := p.user(claims.Subject); user != nil {
+		for name, value := range user.Claims {
+			info[name] = value
+		}
+	}
+	info["sub"] = claims.Subject
+	adminRespond(w, http.StatusOK, info)
+}
@@ -0,0 +1,299 @@
+package mocker
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOAuthProvider(t *testing.T) {
+	config := NewConfig(
+		GET("/admin").Body("ok"),
+		GET("/me").Body("ok"),
+	)
+	admin := "admin"
+	config.Endpoints[0].Auth = &AuthConfig{Type: "jwt", Claims: map[string]Matcher{"role": {Equals: &admin}}}
+	config.Endpoints[1].Auth = &AuthConfig{Type: "jwt", Audience: "spa"}
+	config.OAuth = &OAuthConfig{
+		Clients: []OAuthClient{
+			{ID: "spa", RedirectURIs: []string{"http://localhost:3000/callback"}},
+			{ID: "backend", Secret: "s3cret"},
+		},
+		Users: []OAuthUser{
+			{Username: "alice", Password: "wonderland", Claims: map[string]interface{}{"role": "admin", "email": "alice@example.com"}},
+			{Username: "bob", Password: "builder"},
+		},
+	}
+	ts := startTest(t, config)
+	client := ts.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+
+	var discovery map[string]interface{}
+	resp, err := client.Get(ts.URL + "/.well-known/openid-configuration")
+	require.NoError(t, err)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&discovery))
+	resp.Body.Close()
+	assert.Equal(t, ts.URL, discovery["issuer"])
+	assert.Equal(t, ts.URL+"/token", discovery["token_endpoint"])
+	assert.Equal(t, ts.URL+"/jwks.json", discovery["jwks_uri"])
+
+	token := func(form url.Values, basic ...string) (int, map[string]interface{}) {
+		req, err := http.NewRequest("POST", ts.URL+"/token", strings.NewReader(form.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if len(basic) == 2 {
+			req.SetBasicAuth(basic[0], basic[1])
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		var body map[string]interface{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+		return resp.StatusCode, body
+	}
+	get := func(path, accessToken string) (int, string) {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp.StatusCode, string(body)
+	}
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	digest := sha256.Sum256([]byte(verifier))
+	authorize := url.Values{
+		"response_type": {"code"},
+		"client_id": {"spa"},
+		"redirect_uri": {"http://localhost:3000/callback"},
+		"scope": {"openid profile"},
+		"state": {"xyz"},
+		"nonce": {"n-0S6"},
+		"code_challenge": {base64.RawURLEncoding.EncodeToString(digest[:])},
+		"code_challenge_method": {"S256"},
+	}
+	signIn := func(username, password string) *http.Response {
+		form := url.Values{"username": {username}, "password": {password}}
+		for name, values := range authorize {
+			form[name] = values
+		}
+		resp, err := client.PostForm(ts.URL+"/authorize", form)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp
+	}
+	codeFrom := func(resp *http.Response) string {
+		require.Equal(t, http.StatusFound, resp.StatusCode)
+		location, err := url.Parse(resp.Header.Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "localhost:3000", location.Host)
+		assert.Equal(t, "xyz", location.Query().Get("state"))
+		return location.Query().Get("code")
+	}
+
+	resp, err = client.Get(ts.URL + "/authorize?" + authorize.Encode())
+	require.NoError(t, err)
+	page, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Contains(t, string(page), `name="password"`)
+	assert.Contains(t, string(page), `name="code_challenge"`)
+	assert.Equal(t, http.StatusUnauthorized, signIn("alice", "wrong").StatusCode)
+
+	code := codeFrom(signIn("alice", "wonderland"))
+	exchange := url.Values{
+		"grant_type": {"authorization_code"},
+		"client_id": {"spa"},
+		"code": {code},
+		"redirect_uri": {"http://localhost:3000/callback"},
+		"code_verifier": {"wrong-verifier"},
+	}
+	status, body := token(exchange)
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "invalid_grant", body["error"])
+	status, _ = token(exchange)
+	assert.Equal(t, 400, status, "a code is used at most once")
+
+	exchange.Set("code", codeFrom(signIn("alice", "wonderland")))
+	exchange.Set("code_verifier", verifier)
+	status, body = token(exchange)
+	require.Equal(t, 200, status, "%v", body)
+	assert.Equal(t, "Bearer", body["token_type"])
+	accessToken := body["access_token"].(string)
+	refreshToken := body["refresh_token"].(string)
+	idPayload, err := base64.RawURLEncoding.DecodeString(strings.Split(body["id_token"].(string), ".")[1])
+	require.NoError(t, err)
+	var idClaims map[string]interface{}
+	require.NoError(t, json.Unmarshal(idPayload, &idClaims))
+	assert.Equal(t, "alice", idClaims["sub"])
+	assert.Equal(t, "n-0S6", idClaims["nonce"])
+	assert.Equal(t, ts.URL, idClaims["iss"])
+
+	status, _ = get("/admin", accessToken)
+	assert.Equal(t, 200, status)
+	status, _ = get("/me", accessToken)
+	assert.Equal(t, 200, status)
+	status, userinfo := get("/userinfo", accessToken)
+	assert.Equal(t, 200, status)
+	assert.JSONEq(t, `{"sub": "alice", "role": "admin", "email": "alice@example.com"}`, userinfo)
+	status, _ = get("/userinfo", "not-a-token")
+	assert.Equal(t, 401, status)
+
+	refresh := url.Values{"grant_type": {"refresh_token"}, "client_id": {"spa"}, "refresh_token": {refreshToken}}
+	status, body = token(refresh)
+	require.Equal(t, 200, status, "%v", body)
+	assert.NotEqual(t, refreshToken, body["refresh_token"])
+	status, body = token(refresh)
+	assert.Equal(t, 400, status, "refresh tokens are rotated")
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	status, body = token(url.Values{"grant_type": {"password"}, "client_id": {"spa"}, "username": {"bob"}, "password": {"builder"}})
+	require.Equal(t, 200, status, "%v", body)
+	status, _ = get("/admin", body["access_token"].(string))
+	assert.Equal(t, 401, status, "bob has no role claim")
+	status, body = token(url.Values{"grant_type": {"password"}, "client_id": {"spa"}, "username": {"bob"}, "password": {"wrong"}})
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "invalid_grant", body["error"])
+
+	status, body = token(url.Values{"grant_type": {"client_credentials"}}, "backend", "s3cret")
+	require.Equal(t, 200, status, "%v", body)
+	assert.Nil(t, body["refresh_token"])
+	status, _ = get("/me", body["access_token"].(string))
+	assert.Equal(t, 401, status, "the token is issued to backend, not spa")
+	status, body = token(url.Values{"grant_type": {"client_credentials"}}, "backend", "wrong")
+	assert.Equal(t, 401, status)
+	assert.Equal(t, "invalid_client", body["error"])
+	status, body = token(url.Values{"grant_type": {"client_credentials"}, "client_id": {"spa"}})
+	assert.Equal(t, 400, status)
+	assert.Equal(t, "unauthorized_client", body["error"])
+
+	resp, err = client.Get(ts.URL + "/authorize?client_id=spa&response_type=code&redirect_uri=http://evil.test/cb")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 400, resp.StatusCode, "unregistered redirect URIs are not redirected to")
+
+	hinted := url.Values{"client_id": {"any"}, "response_type": {"token"}, "redirect_uri": {"http://app.test/cb"}, "state": {"s"}}
+	resp, err = client.Get(ts.URL + "/authorize?" + hinted.Encode())
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, 400, resp.StatusCode, "only registered clients are accepted when clients are listed")
+}
+
+func TestOAuthProviderDefaults(t *testing.T) {
+	config := NewConfig(GET("/me").Status(200).Body("ok"))
+	config.Endpoints[0].Auth = &AuthConfig{Type: "jwt"}
+	config.OAuth = &OAuthConfig{Issuer: "https://auth.test", TokenTTL: "1m"}
+	server, err := New(config)
+	require.NoError(t, err)
+	serve := func(req *http.Request) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := serve(httptest.NewRequest("GET", "/authorize?response_type=code&client_id=web&redirect_uri=http://app.test/cb&login_hint=user", nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+
+	form := url.Values{"grant_type": {"authorization_code"}, "client_id": {"web"}, "redirect_uri": {"http://app.test/cb"}, "code": {location.Query().Get("code")}}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = serve(req)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, float64(60), body["expires_in"])
+	assert.Nil(t, body["id_token"], "no id_token without the openid scope")
+
+	req = httptest.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+body["access_token"].(string))
+	assert.Equal(t, 200, serve(req).Code)
+
+	_, err = server.Reload(cloneConfig(config))
+	require.NoError(t, err)
+	assert.Equal(t, 200, serve(req).Code, "tokens stay valid across reloads")
+
+	w = serve(httptest.NewRequest("GET", "/.well-known/openid-configuration", nil))
+	assert.Contains(t, w.Body.String(), `"issuer":"https://auth.test"`)
+}
+
+func TestOAuthProviderClientAuthentication(t *testing.T) {
+	token := func(server *Server, form url.Values) int {
+		req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w.Code
+	}
+	password := func(clientID, secret string) url.Values {
+		return url.Values{"grant_type": {"password"}, "client_id": {clientID}, "client_secret": {secret}, "username": {"user"}, "password": {"password"}}
+	}
+	credentials := func(clientID, secret string) url.Values {
+		return url.Values{"grant_type": {"client_credentials"}, "client_id": {clientID}, "client_secret": {secret}}
+	}
+
+	// Without clients the provider is open: every grant accepts any client
+	// id and ignores secrets.
+	config := NewConfig(GET("/me").Body("ok"))
+	config.OAuth = &OAuthConfig{}
+	open, err := New(config)
+	require.NoError(t, err)
+	assert.Equal(t, 200, token(open, credentials("anyone", "")))
+	assert.Equal(t, 200, token(open, credentials("anyone", "wrong")))
+	assert.Equal(t, 200, token(open, password("anyone", "")))
+	assert.Equal(t, 401, token(open, credentials("", "")), "a client id is still required")
+
+	// With clients only they may ask for tokens, confidential ones with
+	// their secret.
+	config.OAuth = &OAuthConfig{Clients: []OAuthClient{{ID: "spa"}, {ID: "backend", Secret: "s3cret"}}}
+	closed, err := New(config)
+	require.NoError(t, err)
+	assert.Equal(t, 401, token(closed, credentials("anyone", "")))
+	assert.Equal(t, 401, token(closed, password("anyone", "")))
+	assert.Equal(t, 401, token(closed, credentials("backend", "wrong")))
+	assert.Equal(t, 401, token(closed, password("backend", "")))
+	assert.Equal(t, 200, token(closed, password("backend", "s3cret")))
+	assert.Equal(t, 200, token(closed, credentials("backend", "s3cret")))
+	assert.Equal(t, 200, token(closed, password("spa", "")))
+	assert.Equal(t, 400, token(closed, credentials("spa", "")), "public clients cannot use client_credentials")
+}
+
+func TestOAuthProviderGrantExpiry(t *testing.T) {
+	provider, err := newOAuthProvider()
+	require.NoError(t, err)
+
+	expired := provider.store(provider.codes, oauthGrant{clientID: "spa", expires: time.Now().Add(-time.Second)})
+	live := provider.store(provider.codes, oauthGrant{clientID: "spa", expires: time.Now().Add(oauthCodeTTL)})
+	assert.NotContains(t, provider.codes, expired, "expired codes are pruned")
+	assert.Contains(t, provider.codes, live)
+
+	w := httptest.NewRecorder()
+	form := url.Values{"grant_type": {"password"}, "client_id": {"spa"}, "username": {"user"}, "password": {"password"}}
+	req := httptest.NewRequest("POST", "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	provider.handleToken(w, req)
+	require.Equal(t, 200, w.Code, w.Body.String())
+	var body map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	grant := provider.refreshTokens[body["refresh_token"].(string)]
+	assert.WithinDuration(t, time.Now().Add(oauthRefreshTTL), grant.expires, time.Minute, "refresh tokens expire")
+
+	stale := provider.store(provider.refreshTokens, oauthGrant{clientID: "spa", expires: time.Now().Add(-time.Second)})
+	_, ok := provider.take(provider.refreshTokens, stale)
+	assert.False(t, ok)
+}
@@ -0,0 +1,1035 @@
+package mocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type openAPIDocument struct {
+	OpenAPI string `yaml:"openapi"`
+	Paths map[string]openAPIPathItem `yaml:"paths"`
+	Components openAPIComponents `yaml:"components"`
+	Security []map[string][]string `yaml:"security"`
+}
+
+type openAPIComponents struct {
+	Schemas map[string]*openAPISchema `yaml:"schemas"`
+	Responses map[string]*openAPIResponse `yaml:"responses"`
+	Parameters map[string]*openAPIParameter `yaml:"parameters"`
+	SecuritySchemes map[string]openAPISecurityScheme `yaml:"securitySchemes"`
+}
+
+type openAPIPathItem struct {
+	Parameters []*openAPIParameter `yaml:"parameters"`
+	Get *openAPIOperation `yaml:"get"`
+	Put *openAPIOperation `yaml:"put"`
+	Post *openAPIOperation `yaml:"post"`
+	Delete *openAPIOperation `yaml:"delete"`
+	Options *openAPIOperation `yaml:"options"`
+	Head *openAPIOperation `yaml:"head"`
+	Patch *openAPIOperation `yaml:"patch"`
+}
+
+type openAPIOperation struct {
+	Parameters []*openAPIParameter `yaml:"parameters"`
+	Responses map[string]*openAPIResponse `yaml:"responses"`
+	Security *[]map[string][]string `yaml:"security"`
+}
+
+type openAPIParameter struct {
+	Ref string `yaml:"$ref"`
+	Name string `yaml:"name"`
+	In string `yaml:"in"`
+	Schema *openAPISchema `yaml:"schema"`
+}
+
+type openAPIResponse struct {
+	Ref string `yaml:"$ref"`
+	Content map[string]openAPIMediaType `yaml:"content"`
+}
+
+type openAPIMediaType struct {
+	Schema *openAPISchema `yaml:"schema"`
+	Example interface{} `yaml:"example"`
+}
+
+type openAPISchema struct {
+	Ref string `yaml:"$ref"`
+	Type string `yaml:"type"`
+	Format string `yaml:"format"`
+	Enum []interface{} `yaml:"enum"`
+	Minimum *float64 `yaml:"minimum"`
+	Maximum *float64 `yaml:"maximum"`
+	Pattern string `yaml:"pattern"`
+	Example interface{} `yaml:"example"`
+	Nullable bool `yaml:"nullable"`
+	Items *openAPISchema `yaml:"items"`
+	MinItems *int `yaml:"minItems"`
+	MaxItems *int `yaml:"maxItems"`
+	Properties map[string]*openAPISchema `yaml:"properties"`
+	AllOf []*openAPISchema `yaml:"allOf"`
+	OneOf []*openAPISchema `yaml:"oneOf"`
+	AnyOf []*openAPISchema `yaml:"anyOf"`
+}
+
+type openAPISecurityScheme struct {
+	Type string `yaml:"type"`
+	Scheme string `yaml:"scheme"`
+	Token string `yaml:"x-apimocker-token"`
+	Username string `yaml:"x-apimocker-username"`
+	Password string `yaml:"x-apimocker-password"`
+}
+
+const (
+	defaultImportToken = "mock-token"
+	defaultImportUsername = "user"
+	defaultImportPassword = "password"
+	defaultImportCount = 5
+	maxSchemaDepth = 8
+)
+
+func ImportOpenAPIFile(path string) ([]Endpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := importOpenAPI(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return endpoints, nil
+}
+
+// importOpenAPI builds endpoints from an OpenAPI 3 document in YAML or JSON.
+// Paths are emitted in sorted order with their methods in a fixed order so
+// repeated imports produce the same config.
+func importOpenAPI(data []byte) ([]Endpoint, error) {
+	var doc openAPIDocument
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(doc.OpenAPI, "3.") {
+		return nil, fmt.Errorf("unsupported OpenAPI version %q (expected 3.x)", doc.OpenAPI)
+	}
+
+	paths := make([]string, 0, len(doc.Paths))
+	for path := range doc.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var endpoints []Endpoint
+	for _, path := range paths {
+		item := doc.Paths[path]
+		operations := []struct {
+			method string
+			op *openAPIOperation
+		}{
+			{http.MethodGet, item.Get},
+			{http.MethodPost, item.Post},
+			{http.MethodPut, item.Put},
+			{http.MethodPatch, item.Patch},
+			{http.MethodDelete, item.Delete},
+			{http.MethodHead, item.Head},
+			{http.MethodOptions, item.Options},
+		}
+		for _, operation := range operations {
+			if operation.op == nil {
+				continue
+			}
+			endpoint, err := doc.endpoint(path, operation.method, item.Parameters, operation.op)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %v", operation.method, path, err)
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
+
+func (doc *openAPIDocument) endpoint(path, method string, shared []*openAPIParameter, op *openAPIOperation) (Endpoint, error) {
+	endpoint := Endpoint{
+		Path: doc.constrainPath(path, append(append([]*openAPIParameter{}, shared...), op.Parameters...)),
+		Method: method,
+		Status: http.StatusOK,
+		Count: 1,
+	}
+
+	statusKey, response := pickResponse(op.Responses)
+	if statusKey != "" && statusKey != "default" {
+		status, err := responseStatus(statusKey)
+		if err != nil {
+			return Endpoint{}, fmt.Errorf("invalid response status %q", statusKey)
+		}
+		endpoint.Status = status
+	}
+
+	if response != nil {
+		response = doc.resolveResponse(response)
+	}
+	if schema := jsonSchemaOf(response); schema != nil {
+		schema = doc.resolveSchema(schema, 0)
+		if schema.Type == "array" || schema.Items != nil {
+			if template, ok := doc.schemaTemplate(schema.Items, "", 0).(map[string]interface{}); ok {
+				endpoint.Data = marshalTemplate(template)
+				endpoint.Count = defaultImportCount
+				if schema.MinItems != nil && *schema.MinItems > 0 {
+					endpoint.Count = *schema.MinItems
+				}
+			}
+		} else if template, ok := doc.schemaTemplate(schema, "", 0).(map[string]interface{}); ok {
+			endpoint.Data = marshalTemplate(template)
+			endpoint.Single = true
+		}
+		if endpoint.Data == "" {
+			body := doc.literalBody(response, schema)
+			endpoint.Body = &body
+		}
+	}
+
+	security := doc.Security
+	if op.Security != nil {
+		security = *op.Security
+	}
+	endpoint.Auth = doc.authFor(security)
+	return endpoint, nil
+}
+
+// constrainPath adds regex constraints to path parameters whose schema
+// restricts their values, e.g. integer ids become {id:[0-9]+}.
+func (doc *openAPIDocument) constrainPath(path string, params []*openAPIParameter) string {
+	for _, param := range params {
+		param = doc.resolveParameter(param)
+		if param == nil || param.In != "path" || param.Schema == nil {
+			continue
+		}
+		schema := doc.resolveSchema(param.Schema, 0)
+		constraint := ""
+		switch {
+		case schema.Pattern != "":
+			constraint = strings.TrimSuffix(strings.TrimPrefix(schema.Pattern, "^"), "$")
+		case len(schema.Enum) > 0:
+			values := make([]string, 0, len(schema.Enum))
+			for _, value := range schema.Enum {
+				values = append(values, regexp.QuoteMeta(fmt.Sprintf("%v", value)))
+			}
+			constraint = strings.Join(values, "|")
+		case schema.Type == "integer":
+			constraint = "[0-9]+"
+		case schema.Format == "uuid":
+			constraint = "[0-9a-fA-F-]{36}"
+		}
+		if constraint != "" && !strings.Contains(constraint, "/") {
+			path = strings.Replace(path, "{"+param.Name+"}", "{"+param.Name+":"+constraint+"}", 1)
+		}
+	}
+	return path
+}
+
+// pickResponse prefers the lowest 2xx status, then "default", then the
+// lowest declared status. Exact codes win over wildcards such as "2XX".
+func pickResponse(responses map[string]*openAPIResponse) (string, *openAPIResponse) {
+	keys := make([]string, 0, len(responses))
+	for key := range responses {
+		if key != "default" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if wildcard(keys[i]) != wildcard(keys[j]) {
+			return !wildcard(keys[i])
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, key := range keys {
+		if strings.HasPrefix(key, "2") {
+			return key, responses[key]
+		}
+	}
+	if response, ok := responses["default"]; ok {
+		return "default", response
+	}
+	if len(keys) > 0 {
+		return keys[0], responses[keys[0]]
+	}
+	return "", nil
+}
+
+// wildcard reports whether key is a status range such as "2XX".
+func wildcard(key string) bool {
+	return len(key) == 3 && strings.EqualFold(key[1:], "XX")
+}
+
+// responseStatus converts a response key to a status code. Ranges map to
+// the lowest code of their class, so "2XX" becomes 200.
+func responseStatus(key string) (int, error) {
+	if wildcard(key) && key[0] >= '1' && key[0] <= '5' {
+		return int(key[0]-'0') * 100, nil
+	}
+	return strconv.Atoi(key)
+}
+
+// literalBody returns the JSON body for a response whose records cannot be
+// generated, such as an array of strings or a bare number. The example from
+// the spec is used when there is one, otherwise a value is generated once
+// from the schema.
+func (doc *openAPIDocument) literalBody(response *openAPIResponse, schema *openAPISchema) string {
+	example := jsonExampleOf(response)
+	if example == nil {
+		example = schema.Example
+	}
+	if example == nil {
+		// Round-trip through JSON so array bounds read as compileSchema expects.
+		spec, _ := json.Marshal(doc.schemaTemplate(schema, "", 0))
+		var template interface{}
+		json.Unmarshal(spec, &template)
+		generate, _ := compileSchema(template, "")
+		example = generate(newFakeSource(nil))
+	}
+	body, _ := json.Marshal(example)
+	return string(body)
+}
+
+func jsonExampleOf(response *openAPIResponse) interface{} {
+	if media, ok := response.Content["application/json"]; ok {
+		return media.Example
+	}
+	for contentType, media := range response.Content {
+		if strings.HasSuffix(contentType, "+json") {
+			return media.Example
+		}
+	}
+	return nil
+}
+
+func jsonSchemaOf(response *openAPIResponse) *openAPISchema {
+	if response == nil {
+		return nil
+	}
+	if media, ok := response.Content["application/json"]; ok {
+		return media.Schema
+	}
+	for contentType, media := range response.Content {
+		if strings.HasSuffix(contentType, "+json") {
+			return media.Schema
+		}
+	}
+	return nil
+}
+
+func refName(ref, prefix string) string {
+	if !strings.HasPrefix(ref, prefix) {
+		return ""
+	}
+	return strings.TrimPrefix(ref, prefix)
+}
+
+func (doc *openAPIDocument) resolveSchema(schema *openAPISchema, depth int) *openAPISchema {
+	for schema != nil && schema.Ref != "" && depth < maxSchemaDepth {
+		schema = doc.Components.Schemas[refName(schema.Ref, "#/components/schemas/")]
+		depth++
+	}
+	if schema == nil || schema.Ref != "" {
+		return &openAPISchema{}
+	}
+	return schema
+}
+
+func (doc *openAPIDocument) resolveResponse(response *openAPIResponse) *openAPIResponse {
+	if response.Ref == "" {
+		return response
+	}
+	return doc.Components.Responses[refName(response.Ref, "#/components/responses/")]
+}
+
+func (doc *openAPIDocument) resolveParameter(param *openAPIParameter) *openAPIParameter {
+	if param == nil || param.Ref == "" {
+		return param
+	}
+	return doc.Components.Parameters[refName(param.Ref, "#/components/parameters/")]
+}
+
+// importFieldNames maps common property names to faker types when the
+// schema itself only says "string".
+var importFieldNames = map[string]string{
+	"name": "name",
+	"fullname": "name",
+	"email": "email",
+	"username": "username",
+	"password": "password",
+	"phone": "phone",
+	"url": "url",
+	"website": "url",
+	"lat": "lat",
+	"latitude": "lat",
+	"lng": "lng",
+	"lon": "lng",
+	"longitude": "lng",
+	"ip": "ipv4",
+	"description": "sentence",
+}
+
+// schemaTemplate converts an OpenAPI schema into the data template
+// understood by compileSchema.
+func (doc *openAPIDocument) schemaTemplate(schema *openAPISchema, name string, depth int) interface{} {
+	if depth > maxSchemaDepth {
+		return nil
+	}
+	schema = doc.resolveSchema(schema, 0)
+
+	if len(schema.AllOf) > 0 {
+		merged := map[string]interface{}{}
+		for _, part := range schema.AllOf {
+			if fields, ok := doc.schemaTemplate(part, name, depth+1).(map[string]interface{}); ok {
+				for key, value := range fields {
+					merged[key] = value
+				}
+			}
+		}
+		return merged
+	}
+	if len(schema.OneOf) > 0 {
+		return doc.schemaTemplate(schema.OneOf[0], name, depth+1)
+	}
+	if len(schema.AnyOf) > 0 {
+		return doc.schemaTemplate(schema.AnyOf[0], name, depth+1)
+	}
+
+	switch {
+	case schema.Type == "object" || len(schema.Properties) > 0:
+		fields := make(map[string]interface{}, len(schema.Properties))
+		for key, property := range schema.Properties {
+			fields[key] = doc.schemaTemplate(property, key, depth+1)
+		}
+		return fields
+	case schema.Type == "array" || schema.Items != nil:
+		spec := map[string]interface{}{"$items": doc.schemaTemplate(schema.Items, name, depth+1)}
+		if schema.MinItems != nil {
+			spec["$min"] = *schema.MinItems
+		}
+		if schema.MaxItems != nil {
+			spec["$max"] = *schema.MaxItems
+		}
+		return spec
+	}
+
+	fieldType := scalarFieldType(schema, name)
+	if schema.Nullable {
+		return fmt.Sprintf("nullable(%s, 0.1)", fieldType)
+	}
+	return fieldType
+}
+
+func scalarFieldType(schema *openAPISchema, name string) string {
+	if len(schema.Enum) > 0 {
+		values := make([]string, 0, len(schema.Enum))
+		for _, value := range schema.Enum {
+			values = append(values, fmt.Sprintf("%v", value))
+		}
+		return "enum:" + strings.Join(values, "|")
+	}
+
+	switch schema.Format {
+	case "uuid":
+		return "uuid"
+	case "email":
+		return "email"
+	case "uri", "url":
+		return "url"
+	case "ipv4":
+		return "ipv4"
+	case "password":
+		return "password"
+	case "date":
+		return "date"
+	case "date-time":
+		return "date:2020-01-01..2030-12-31:2006-01-02T15:04:05Z07:00"
+	case "float", "double":
+		return numericFieldType("float", schema)
+	case "int32", "int64":
+		return numericFieldType("int", schema)
+	}
+
+	if schema.Type == "string" && schema.Pattern != "" {
+		return "regex:" + strings.TrimSuffix(strings.TrimPrefix(schema.Pattern, "^"), "$")
+	}
+
+	switch schema.Type {
+	case "integer":
+		return numericFieldType("int", schema)
+	case "number":
+		return numericFieldType("float", schema)
+	case "boolean":
+		return "bool"
+	}
+
+	if example, ok := schema.Example.(string); ok && example != "" && !strings.Contains(example, "|") {
+		return "enum:" + example
+	}
+	if fieldType, ok := importFieldNames[strings.ToLower(name)]; ok {
+		return fieldType
+	}
+	return "string"
+}
+
+func numericFieldType(base string, schema *openAPISchema) string {
+	if schema.Minimum == nil && schema.Maximum == nil {
+		if example, ok := schema.Example.(int); ok && base == "int" {
+			return fmt.Sprintf("int:%d..%d", example, example)
+		}
+		return base
+	}
+
+	low, high := 0.0, 1000.0
+	if schema.Minimum != nil {
+		low = *schema.Minimum
+		if schema.Maximum == nil {
+			high = low + 1000
+		}
+	}
+	if schema.Maximum != nil {
+		high = *schema.Maximum
+		if schema.Minimum == nil && high < low {
+			low = high - 1000
+		}
+	}
+
+	if base == "int" {
+		return fmt.Sprintf("int:%d..%d", int(math.Ceil(low)), int(math.Floor(high)))
+	}
+	return fmt.Sprintf("float:%s..%s", strconv.FormatFloat(low, 'f', -1, 64), strconv.FormatFloat(high, 'f', -1, 64))
+}
+
+// authFor maps the first security requirement apimocker can emulate to an
+// AuthConfig. Credentials come from x-apimocker-* extensions on the scheme
+// or fall back to fixed defaults.
+func (doc *openAPIDocument) authFor(requirements []map[string][]string) *AuthConfig {
+	for _, requirement := range requirements {
+		names := make([]string, 0, len(requirement))
+		for name := range requirement {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			scheme, ok := doc.Components.SecuritySchemes[name]
+			if !ok {
+				continue
+			}
+			switch {
+			case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "basic"):
+				auth := &AuthConfig{Type: "basic", Username: scheme.Username, Password: scheme.Password}
+				if auth.Username == "" {
+					auth.Username = defaultImportUsername
+				}
+				if auth.Password == "" {
+					auth.Password = defaultImportPassword
+				}
+				return auth
+			case scheme.Type == "http" && strings.EqualFold(scheme.Scheme, "bearer"),
+				scheme.Type == "oauth2", scheme.Type == "openIdConnect":
+				auth := &AuthConfig{Type: "bearer", Token: scheme.Token}
+				if auth.Token == "" {
+					auth.Token = defaultImportToken
+				}
+				return auth
+			}
+		}
+	}
+	return nil
+}
+
+func marshalTemplate(template map[string]interface{}) string {
+	data, _ := json.MarshalIndent(template, "", "  ")
+	return string(data) + "\n"
+}
+
+// mergeEndpoints appends imported endpoints that are not already declared
+// in the config, so hand-written endpoints override the spec.
+func mergeEndpoints(declared, imported []Endpoint) []Endpoint {
+	seen := make(map[string]bool, len(declared))
+	for _, ep := range declared {
+		seen[routeKey(ep.Method, ep.Path)] = true
+	}
+
+	merged := declared
+	for _, ep := range imported {
+		if seen[routeKey(ep.Method, ep.Path)] {
+			continue
+		}
+		merged = append(merged, ep)
+	}
+	return merged
+}
+
+// ExportOpenAPI describes config as an OpenAPI 3 document.
+func ExportOpenAPI(config *Config) map[string]interface{} {
+	paths := map[string]interface{}{}
+	schemes := map[string]interface{}{}
+
+	addOperation := func(path, method string, operation map[string]interface{}) {
+		openAPIPath, params := exportPath(path)
+		if len(params) > 0 {
+			existing, _ := operation["parameters"].([]interface{})
+			operation["parameters"] = append(params, existing...)
+		}
+		item, ok := paths[openAPIPath].(map[string]interface{})
+		if !ok {
+			item = map[string]interface{}{}
+			paths[openAPIPath] = item
+		}
+		item[strings.ToLower(method)] = operation
+	}
+
+	for _, ep := range config.Endpoints {
+		security := exportSecurity(ep.Auth, schemes)
+
+		if ep.Kind == "resource" {
+			record := templateSchema(ep.Data)
+			list := map[string]interface{}{"type": "array", "items": record}
+			itemPath := resourceItemPath(ep)
+			body := map[string]interface{}{
+				"required": true,
+				"content": map[string]interface{}{"application/json": map[string]interface{}{"schema": record}},
+			}
+
+			addOperation(ep.Path, http.MethodGet, exportOperation(ep, http.StatusOK, list, queryParameters(), security))
+			create := exportOperation(ep, http.StatusCreated, record, nil, security)
+			create["requestBody"] = body
+			addOperation(ep.Path, http.MethodPost, create)
+			addOperation(itemPath, http.MethodGet, exportOperation(ep, http.StatusOK, record, nil, security))
+			for _, method := range []string{http.MethodPut, http.MethodPatch} {
+				update := exportOperation(ep, http.StatusOK, record, nil, security)
+				update["requestBody"] = body
+				addOperation(itemPath, method, update)
+			}
+			addOperation(itemPath, http.MethodDelete, exportOperation(ep, http.StatusNoContent, nil, nil, security))
+			continue
+		}
+
+		if ep.Body != nil || ep.BodyFile != "" {
+			var body []byte
+			if ep.Body != nil {
+				body = []byte(*ep.Body)
+			} else {
+				body, _ = os.ReadFile(ep.BodyFile)
+			}
+			var params []interface{}
+			if _, isList := jsonRecords(body); isList {
+				params = queryParameters()
+			}
+			operation := exportOperation(ep, ep.Status, nil, params, security)
+			response := operation["responses"].(map[string]interface{})[strconv.Itoa(ep.Status)].(map[string]interface{})
+			var example interface{} = string(body)
+			if json.Valid(body) {
+				json.Unmarshal(body, &example)
+			}
+			mediaType, _, _ := mime.ParseMediaType(bodyContentType(body))
+			for name, value := range ep.Headers {
+				if strings.EqualFold(name, "Content-Type") {
+					if parsed, _, err := mime.ParseMediaType(value); err == nil {
+						mediaType = parsed
+					}
+				}
+			}
+			if len(body) > 0 {
+				response["content"] = map[string]interface{}{
+					mediaType: map[string]interface{}{"example": example},
+				}
+			}
+			addOperation(ep.Path, ep.Method, operation)
+			continue
+		}
+
+		if ep.File != "" {
+			operation := exportOperation(ep, ep.Status, nil, nil, security)
+			response := operation["responses"].(map[string]interface{})[strconv.Itoa(ep.Status)].(map[string]interface{})
+			response["content"] = map[string]interface{}{
+				fileContentType(ep.File): map[string]interface{}{
+					"schema": map[string]interface{}{"type": "string", "format": "binary"},
+				},
+			}
+			addOperation(ep.Path, ep.Method, operation)
+			continue
+		}
+
+		record := templateSchema(ep.Data)
+		if ep.Single {
+			addOperation(ep.Path, ep.Method, exportOperation(ep, ep.Status, record, nil, security))
+			continue
+		}
+		list := map[string]interface{}{"type": "array", "items": record}
+		addOperation(ep.Path, ep.Method, exportOperation(ep, ep.Status, list, queryParameters(), security))
+	}
+
+	var servers []interface{}
+	for _, serverURL := range serverURLs(config) {
+		servers = append(servers, map[string]interface{}{"url": serverURL})
+	}
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title": "apimocker",
+			"version": "1.0.0",
+			"description": "Generated from the apimocker configuration",
+		},
+		"servers": servers,
+		"paths": paths,
+	}
+	if len(schemes) > 0 {
+		doc["components"] = map[string]interface{}{"securitySchemes": schemes}
+	}
+	return doc
+}
+
+func exportOperation(ep Endpoint, status int, schema map[string]interface{}, params []interface{}, security []interface{}) map[string]interface{} {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	response := map[string]interface{}{"description": http.StatusText(status)}
+	if schema != nil {
+		response["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": schema},
+		}
+	}
+	if len(ep.Headers) > 0 {
+		headers := map[string]interface{}{}
+		for name, value := range ep.Headers {
+			headers[name] = map[string]interface{}{
+				"schema": map[string]interface{}{"type": "string"},
+				"example": value,
+			}
+		}
+		response["headers"] = headers
+	}
+
+	responses := map[string]interface{}{strconv.Itoa(status): response}
+	errorSchema := map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}},
+	}
+	for _, errorConfig := range ep.Errors {
+		description := errorConfig.Message
+		if description == "" {
+			description = http.StatusText(errorConfig.Status)
+		}
+		errorResponse := map[string]interface{}{"description": description}
+		if errorConfig.Message != "" {
+			errorResponse["content"] = map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": errorSchema},
+			}
+		}
+		responses[strconv.Itoa(errorConfig.Status)] = errorResponse
+	}
+	alternatives := make([]Response, 0, len(ep.Responses))
+	for _, rule := range ep.Responses {
+		alternatives = append(alternatives, rule.Response)
+	}
+	if ep.Sequence != nil {
+		alternatives = append(alternatives, ep.Sequence.Steps...)
+	}
+	for _, alternative := range alternatives {
+		if alternative.Status == 0 {
+			continue
+		}
+		if _, exists := responses[strconv.Itoa(alternative.Status)]; !exists {
+			responses[strconv.Itoa(alternative.Status)] = map[string]interface{}{"description": http.StatusText(alternative.Status)}
+		}
+	}
+	if security != nil {
+		responses["401"] = map[string]interface{}{
+			"description": "Authentication required",
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": errorSchema},
+			},
+		}
+	}
+
+	operation := map[string]interface{}{"responses": responses}
+	if len(params) > 0 {
+		operation["parameters"] = params
+	}
+	if security != nil {
+		operation["security"] = security
+	}
+	return operation
+}
+
+// exportPath strips parameter constraints from path and describes each
+// parameter, keeping the constraint as a pattern.
+func exportPath(path string) (string, []interface{}) {
+	segments, err := compilePathPattern(path)
+	if err != nil {
+		return path, nil
+	}
+
+	parts := make([]string, 0, len(segments))
+	var params []interface{}
+	for _, segment := range segments {
+		if segment.param == "" {
+			parts = append(parts, segment.literal)
+			continue
+		}
+		parts = append(parts, "{"+segment.param+"}")
+		schema := map[string]interface{}{"type": "string"}
+		if segment.pattern != nil {
+			expr := strings.TrimSuffix(strings.TrimPrefix(segment.pattern.String(), "^(?:"), ")$")
+			schema["pattern"] = "^" + expr + "$"
+		}
+		params = append(params, map[string]interface{}{
+			"name": segment.param,
+			"in": "path",
+			"required": true,
+			"schema": schema,
+		})
+	}
+	return "/" + strings.Join(parts, "/"), params
+}
+
+// queryParameters documents the parameters handled by ApplyQueryFilters
+// and the list response envelope.
+func queryParameters() []interface{} {
+	param := func(name, description string, schema map[string]interface{}) interface{} {
+		return map[string]interface{}{
+			"name": name,
+			"in": "query",
+			"description": description,
+			"schema": schema,
+		}
+	}
+	integer := map[string]interface{}{"type": "integer", "minimum": 0}
+	text := map[string]interface{}{"type": "string"}
+
+	return []interface{}{
+		param("count", "Number of items to return", integer),
+		param("limit", "Alias for count", integer),
+		param("offset", "Number of items to skip", integer),
+		param("sort", "Field to sort by", text),
+		param("order", "Sort order", map[string]interface{}{"type": "string", "enum": []interface{}{"asc", "desc"}, "default": "asc"}),
+		param("filter", "Filter by field, format field:value", text),
+		param("meta", "Wrap the response in a data/meta envelope", map[string]interface{}{"type": "boolean"}),
+		param("seed", "Seed for deterministic data", map[string]interface{}{"type": "integer"}),
+	}
+}
+
+func exportSecurity(auth *AuthConfig, schemes map[string]interface{}) []interface{} {
+	if auth == nil {
+		return nil
+	}
+
+	var name string
+	switch strings.ToLower(auth.Type) {
+	case "basic":
+		name = "basicAuth"
+		schemes[name] = map[string]interface{}{"type": "http", "scheme": "basic"}
+	case "bearer":
+		name = "bearerAuth"
+		schemes[name] = map[string]interface{}{"type": "http", "scheme": "bearer"}
+	case "jwt":
+		name = "jwtAuth"
+		schemes[name] = map[string]interface{}{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"}
+	default:
+		return nil
+	}
+	return []interface{}{map[string]interface{}{name: []interface{}{}}}
+}
+
+// templateSchema derives a JSON schema from a data template. Templates that
+// are not valid JSON describe the fallback id/name/email record.
+func templateSchema(data string) map[string]interface{} {
+	var template map[string]interface{}
+	if err := json.Unmarshal([]byte(data), &template); err != nil {
+		template = map[string]interface{}{"id": "uuid", "name": "name", "email": "email"}
+	}
+	return nodeSchema(template)
+}
+
+func nodeSchema(node interface{}) map[string]interface{} {
+	switch spec := node.(type) {
+	case string:
+		return fieldTypeSchema(spec)
+	case map[string]interface{}:
+		if items, isArray := spec["$items"]; isArray {
+			schema := map[string]interface{}{"type": "array", "items": nodeSchema(items)}
+			minLen, maxLen := arrayBounds(spec)
+			schema["minItems"], schema["maxItems"] = minLen, maxLen
+			return schema
+		}
+		properties := make(map[string]interface{}, len(spec))
+		for key, value := range spec {
+			properties[key] = nodeSchema(value)
+		}
+		return map[string]interface{}{"type": "object", "properties": properties}
+	case []interface{}:
+		schema := map[string]interface{}{"type": "array", "minItems": defaultArrayMin, "maxItems": defaultArrayMax}
+		if len(spec) > 0 {
+			schema["items"] = nodeSchema(spec[0])
+		} else {
+			schema["items"] = map[string]interface{}{}
+			schema["maxItems"] = 0
+			delete(schema, "minItems")
+		}
+		return schema
+	case bool:
+		return map[string]interface{}{"type": "boolean", "example": spec}
+	case float64:
+		if spec == math.Trunc(spec) {
+			return map[string]interface{}{"type": "integer", "example": spec}
+		}
+		return map[string]interface{}{"type": "number", "example": spec}
+	default:
+		return map[string]interface{}{"nullable": true}
+	}
+}
+
+// fieldTypeSchema is the inverse of parseFieldType.
+func fieldTypeSchema(spec string) map[string]interface{} {
+	spec = strings.TrimSpace(spec)
+	str := func(format string) map[string]interface{} {
+		schema := map[string]interface{}{"type": "string"}
+		if format != "" {
+			schema["format"] = format
+		}
+		return schema
+	}
+
+	if strings.HasPrefix(spec, "nullable(") && strings.HasSuffix(spec, ")") {
+		inner := strings.TrimSuffix(strings.TrimPrefix(spec, "nullable("), ")")
+		if comma := strings.LastIndex(inner, ","); comma != -1 {
+			if _, err := strconv.ParseFloat(strings.TrimSpace(inner[comma+1:]), 64); err == nil {
+				inner = inner[:comma]
+			}
+		}
+		schema := fieldTypeSchema(inner)
+		schema["nullable"] = true
+		return schema
+	}
+
+	name, args, hasArgs := strings.Cut(spec, ":")
+	switch name {
+	case "uuid":
+		return str("uuid")
+	case "email":
+		return str("email")
+	case "url":
+		return str("uri")
+	case "ipv4":
+		return str("ipv4")
+	case "password":
+		return str("password")
+	case "bool":
+		return map[string]interface{}{"type": "boolean"}
+	case "lat":
+		return map[string]interface{}{"type": "number", "minimum": -90, "maximum": 90}
+	case "lng":
+		return map[string]interface{}{"type": "number", "minimum": -180, "maximum": 180}
+	case "timestamp":
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case "int":
+		schema := map[string]interface{}{"type": "integer"}
+		if low, high, err := parseIntRange(args); hasArgs && err == nil {
+			schema["minimum"], schema["maximum"] = low, high
+		}
+		return schema
+	case "float":
+		schema := map[string]interface{}{"type": "number"}
+		rangeStr, _, _ := strings.Cut(args, ":")
+		if lowStr, highStr, isRange := strings.Cut(rangeStr, ".."); hasArgs && isRange {
+			if low, err := strconv.ParseFloat(lowStr, 64); err == nil {
+				schema["minimum"] = low
+			}
+			if high, err := strconv.ParseFloat(highStr, 64); err == nil {
+				schema["maximum"] = high
+			}
+		}
+		return schema
+	case "enum":
+		values := []interface{}{}
+		for _, value := range strings.Split(args, "|") {
+			values = append(values, value)
+		}
+		schema := str("")
+		schema["enum"] = values
+		return schema
+	case "regex":
+		schema := str("")
+		schema["pattern"] = "^" + args + "$"
+		return schema
+	case "date":
+		_, layout, hasLayout := strings.Cut(args, ":")
+		switch {
+		case !hasLayout || layout == defaultDateLayout:
+			return str("date")
+		case layout == time.RFC3339:
+			return str("date-time")
+		}
+		return str("")
+	default:
+		return str("")
+	}
+}
+
+func fileContentType(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".png":
+		return "image/png"
+	case ".gif":
+		return "image/gif"
+	case ".mp4":
+		return "video/mp4"
+	}
+	if contentType := mime.TypeByExtension(filepath.Ext(path)); contentType != "" {
+		return contentType
+	}
+	return "application/octet-stream"
+}
+
+// MarshalOpenAPI encodes doc as JSON or YAML.
+func MarshalOpenAPI(doc map[string]interface{}, format string) ([]byte, error) {
+	if format == "json" {
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	}
+
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+func openAPIHandler(config *Config) http.HandlerFunc {
+	data, err := MarshalOpenAPI(ExportOpenAPI(config), "json")
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, "Failed to generate OpenAPI document", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
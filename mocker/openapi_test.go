@@ -0,0 +1,377 @@
+package mocker
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testOpenAPISpec = `openapi: 3.0.3
+info:
+  title: Users
+  version: "1.0"
+security:
+  - bearerAuth: []
+paths:
+  /users:
+    get:
+      responses:
+        "200":
+          description: ok
+          content:
+            application/json:
+              schema:
+                type: array
+                minItems: 3
+                items:
+                  $ref: "#/components/schemas/User"
+    post:
+      security: []
+      responses:
+        "400":
+          description: invalid
+        "201":
+          description: created
+          content:
+            application/json:
+              schema:
+                $ref: "#/components/schemas/User"
+  /users/{id}:
+    parameters:
+      - $ref: "#/components/parameters/UserId"
+    get:
+      responses:
+        "200":
+          $ref: "#/components/responses/UserResponse"
+  /stats:
+    get:
+      security:
+        - basicAuth: []
+      responses:
+        default:
+          description: stats
+          content:
+            application/json:
+              schema:
+                type: object
+                properties:
+                  total: {type: integer, minimum: 0, maximum: 500}
+                  ratio: {type: number, minimum: 0, maximum: 1}
+components:
+  parameters:
+    UserId:
+      name: id
+      in: path
+      required: true
+      schema: {type: integer}
+  responses:
+    UserResponse:
+      description: user
+      content:
+        application/json:
+          schema:
+            $ref: "#/components/schemas/User"
+  securitySchemes:
+    bearerAuth:
+      type: http
+      scheme: bearer
+      x-apimocker-token: secret
+    basicAuth:
+      type: http
+      scheme: basic
+  schemas:
+    User:
+      type: object
+      properties:
+        id: {type: string, format: uuid}
+        name: {type: string}
+        age: {type: integer, minimum: 18, maximum: 99}
+        status: {type: string, enum: [active, banned]}
+        country: {type: string, example: UA}
+        created: {type: string, format: date-time}
+        nickname: {type: string, nullable: true}
+        address:
+          allOf:
+            - type: object
+              properties:
+                city: {type: string}
+            - type: object
+              properties:
+                zip: {type: string, pattern: "^[0-9]{5}$"}
+        tags:
+          type: array
+          maxItems: 4
+          items: {type: string}
+`
+
+func TestImportOpenAPI(t *testing.T) {
+	endpoints, err := importOpenAPI([]byte(testOpenAPISpec))
+	require.NoError(t, err)
+	require.Len(t, endpoints, 4)
+
+	byRoute := map[string]Endpoint{}
+	for _, endpoint := range endpoints {
+		byRoute[endpoint.Method+" "+endpoint.Path] = endpoint
+
+		var template map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(endpoint.Data), &template))
+		_, err := compileSchema(template, "")
+		assert.NoError(t, err, endpoint.Path)
+	}
+
+	list := byRoute["GET /users"]
+	assert.Equal(t, 200, list.Status)
+	assert.Equal(t, 3, list.Count)
+	assert.False(t, list.Single)
+	assert.Equal(t, &AuthConfig{Type: "bearer", Token: "secret"}, list.Auth)
+
+	var user map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(list.Data), &user))
+	assert.Equal(t, "uuid", user["id"])
+	assert.Equal(t, "name", user["name"])
+	assert.Equal(t, "int:18..99", user["age"])
+	assert.Equal(t, "enum:active|banned", user["status"])
+	assert.Equal(t, "enum:UA", user["country"])
+	assert.Equal(t, "nullable(string, 0.1)", user["nickname"])
+	assert.Equal(t, map[string]interface{}{"city": "string", "zip": "regex:[0-9]{5}"}, user["address"])
+	assert.Equal(t, map[string]interface{}{"$items": "string", "$max": float64(4)}, user["tags"])
+
+	create := byRoute["POST /users"]
+	assert.Equal(t, 201, create.Status)
+	assert.True(t, create.Single)
+	assert.Nil(t, create.Auth)
+
+	detail, ok := byRoute["GET /users/{id:[0-9]+}"]
+	require.True(t, ok)
+	assert.True(t, detail.Single)
+	assert.Equal(t, list.Data, detail.Data)
+
+	stats := byRoute["GET /stats"]
+	assert.Equal(t, 200, stats.Status)
+	assert.Equal(t, &AuthConfig{Type: "basic", Username: "user", Password: "password"}, stats.Auth)
+	assert.Contains(t, stats.Data, `"float:0..1"`)
+	assert.Contains(t, stats.Data, `"int:0..500"`)
+}
+
+func TestImportOpenAPIErrors(t *testing.T) {
+	_, err := importOpenAPI([]byte(`swagger: "2.0"`))
+	assert.Error(t, err)
+
+	_, err = importOpenAPI([]byte(`openapi: [`))
+	assert.Error(t, err)
+}
+
+func TestImportOpenAPIStatusRanges(t *testing.T) {
+	endpoints, err := importOpenAPI([]byte(`openapi: 3.0.3
+paths:
+  /ranged:
+    get:
+      responses:
+        "2XX":
+          description: any success
+        "4XX":
+          description: client error
+  /exact:
+    get:
+      responses:
+        "2XX":
+          description: any success
+        "201":
+          description: created
+  /errors:
+    get:
+      responses:
+        "5XX":
+          description: server error
+`))
+	require.NoError(t, err)
+	require.Len(t, endpoints, 3)
+	statuses := map[string]int{}
+	for _, ep := range endpoints {
+		statuses[ep.Path] = ep.Status
+	}
+	assert.Equal(t, map[string]int{"/ranged": 200, "/exact": 201, "/errors": 500}, statuses)
+}
+
+func TestImportOpenAPIScalarResponses(t *testing.T) {
+	endpoints, err := importOpenAPI([]byte(`openapi: 3.0.3
+paths:
+  /tags:
+    get:
+      responses:
+        "200":
+          description: tags
+          content:
+            application/json:
+              schema:
+                type: array
+                minItems: 2
+                maxItems: 2
+                items: {type: string, format: email}
+  /count:
+    get:
+      responses:
+        "200":
+          description: count
+          content:
+            application/json:
+              schema: {type: integer, minimum: 5, maximum: 5}
+  /colours:
+    get:
+      responses:
+        "200":
+          description: colours
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {type: string}
+              example: [red, green]
+`))
+	require.NoError(t, err)
+	require.Len(t, endpoints, 3)
+	bodies := map[string]string{}
+	for _, ep := range endpoints {
+		assert.Empty(t, ep.Data, ep.Path)
+		require.NotNil(t, ep.Body, ep.Path)
+		bodies[ep.Path] = *ep.Body
+	}
+
+	var tags []string
+	require.NoError(t, json.Unmarshal([]byte(bodies["/tags"]), &tags))
+	require.Len(t, tags, 2)
+	assert.Contains(t, tags[0], "@")
+	assert.Equal(t, "5", bodies["/count"])
+	assert.Equal(t, `["red","green"]`, bodies["/colours"])
+}
+
+func TestLoadConfigWithSpec(t *testing.T) {
+	dir := t.TempDir()
+	specPath := filepath.Join(dir, "spec.yaml")
+	require.NoError(t, os.WriteFile(specPath, []byte(testOpenAPISpec), 0644))
+
+	configPath := filepath.Join(dir, "mock.yaml")
+	require.NoError(t, os.WriteFile(configPath, []byte(`port: 0
+spec: `+specPath+`
+endpoints:
+  - path: /users/{userId}
+    method: GET
+    status: 418
+`), 0644))
+
+	config, err := LoadConfig(configPath)
+	require.NoError(t, err)
+	require.Len(t, config.Endpoints, 4)
+	assert.Equal(t, 418, config.Endpoints[0].Status)
+
+	_, _, err = NewServer(config)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("spec: missing.yaml\n"), 0644))
+	_, err = LoadConfig(configPath)
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs))
+	assert.Equal(t, 1, errs[0].Line)
+	assert.Contains(t, errs[0].Message, "spec:")
+}
+
+func TestExportOpenAPI(t *testing.T) {
+	config := &Config{
+		Port: 8080,
+		Endpoints: []Endpoint{
+			{
+				Path:    "/users/{id:[0-9]+}",
+				Method:  "GET",
+				Status:  200,
+				Data:    `{"id": "int:1..100", "role": "enum:admin|user", "tags": ["string"], "deleted_at": "nullable(date, 0.5)"}`,
+				Single:  true,
+				Headers: map[string]string{"X-Total": "1"},
+				Auth:    &AuthConfig{Type: "bearer", Token: "secret"},
+				Errors:  []ErrorConfig{{Status: 503, Probability: 0.1, Message: "down"}},
+			},
+			{Path: "/posts", Method: "GET", Status: 200, Count: 5, Data: `{"title": "sentence"}`},
+			{Path: "/avatar", Method: "GET", Status: 200, File: "test-image.jpg"},
+			{Path: "/notes", Kind: "resource", Data: `{"id": "uuid"}`},
+		},
+	}
+
+	doc := ExportOpenAPI(config)
+	paths := doc["paths"].(map[string]interface{})
+	assert.Contains(t, paths, "/users/{id}")
+	assert.Contains(t, paths, "/notes/{id}")
+
+	get := paths["/users/{id}"].(map[string]interface{})["get"].(map[string]interface{})
+	param := get["parameters"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "^[0-9]+$", param["schema"].(map[string]interface{})["pattern"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"bearerAuth": []interface{}{}}}, get["security"])
+
+	responses := get["responses"].(map[string]interface{})
+	assert.Contains(t, responses, "503")
+	assert.Contains(t, responses, "401")
+	ok := responses["200"].(map[string]interface{})
+	assert.Contains(t, ok["headers"], "X-Total")
+	schema := ok["content"].(map[string]interface{})["application/json"].(map[string]interface{})["schema"].(map[string]interface{})
+	props := schema["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 100}, props["id"])
+	assert.Equal(t, []interface{}{"admin", "user"}, props["role"].(map[string]interface{})["enum"])
+	assert.Equal(t, "array", props["tags"].(map[string]interface{})["type"])
+	assert.Equal(t, map[string]interface{}{"type": "string", "format": "date", "nullable": true}, props["deleted_at"])
+
+	posts := paths["/posts"].(map[string]interface{})["get"].(map[string]interface{})
+	var names []string
+	for _, p := range posts["parameters"].([]interface{}) {
+		names = append(names, p.(map[string]interface{})["name"].(string))
+	}
+	assert.Equal(t, []string{"count", "limit", "offset", "sort", "order", "filter", "meta", "seed"}, names)
+
+	avatar := paths["/avatar"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Contains(t, avatar["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"], "image/jpeg")
+
+	notes := paths["/notes"].(map[string]interface{})
+	assert.Contains(t, notes, "post")
+	assert.Contains(t, paths["/notes/{id}"], "delete")
+
+	// The exported document imports back into equivalent endpoints.
+	data, err := MarshalOpenAPI(doc, "yaml")
+	require.NoError(t, err)
+	endpoints, err := importOpenAPI(data)
+	require.NoError(t, err)
+	var users *Endpoint
+	for i := range endpoints {
+		if strings.HasPrefix(endpoints[i].Path, "/users/") {
+			users = &endpoints[i]
+		}
+	}
+	require.NotNil(t, users)
+	assert.True(t, users.Single)
+	require.NotNil(t, users.Auth)
+	assert.Equal(t, "bearer", users.Auth.Type)
+}
+
+func TestOpenAPIEndpoint(t *testing.T) {
+	config := &Config{
+		Port:      8080,
+		Endpoints: []Endpoint{{Path: "/users", Method: "GET", Status: 200, Count: 1, Data: `{"id": "uuid"}`}},
+	}
+	server, _, err := NewServer(config)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/__apimocker/openapi.json", nil)
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/users")
+}
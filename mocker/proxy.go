@@ -0,0 +1,358 @@
+package mocker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// recordSkippedHeaders are response headers that describe the upstream
+// connection rather than the response and are left out of recordings.
+var recordSkippedHeaders = map[string]bool{
+	"Connection": true,
+	"Content-Encoding": true,
+	"Content-Length": true,
+	"Date": true,
+	"Keep-Alive": true,
+	"Proxy-Authenticate": true,
+	"Trailer": true,
+	"Transfer-Encoding": true,
+	"Upgrade": true,
+}
+
+// recordURLKey carries the URL a client requested, before the proxy
+// rewrites it for the target.
+type recordURLKey struct{}
+
+func parseTargetURL(target string) (*url.URL, error) {
+	targetURL, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %v", err)
+	}
+	if targetURL.Scheme != "http" && targetURL.Scheme != "https" || targetURL.Host == "" {
+		return nil, fmt.Errorf("invalid target %q: expected an http or https URL", target)
+	}
+	return targetURL, nil
+}
+
+// createProxyHandler forwards requests to config.Target and logs them as
+// proxied. The client's Host header is kept unless RewriteHost is set.
+func createProxyHandler(config ProxyConfig, logger *Logger) (http.Handler, error) {
+	targetURL, err := parseTargetURL(config.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	if config.RewriteHost {
+		director := proxy.Director
+		proxy.Director = func(r *http.Request) {
+			director(r)
+			r.Host = targetURL.Host
+		}
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		data, _ := json.Marshal(map[string]string{"error": fmt.Sprintf("Proxy error: %v", err)})
+		w.Write(data)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		proxy.ServeHTTP(sw, r)
+		if entry := journalEntry(r); entry != nil {
+			entry.Proxied = true
+		}
+
+		duration := time.Since(start)
+		reqLog := RequestLog{
+			Timestamp: start.Format(time.RFC3339),
+			Method: r.Method,
+			Protocol: r.Proto,
+			Path: r.URL.Path,
+			Query: r.URL.RawQuery,
+			StatusCode: sw.status,
+			ResponseTime: duration.String(),
+			UserAgent: r.Header.Get("User-Agent"),
+			RemoteAddr: r.RemoteAddr,
+			ContentLength: sw.written,
+			Proxied: true,
+		}
+		logger.LogRequest(reqLog)
+	}), nil
+}
+
+// statusWriter records the status code and body size written through it.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	written int64
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	w.status = statusCode
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Recorder proxies requests to a real backend and captures every response
+// as an Endpoint. The latest response for a method, path and query wins,
+// and the recording is written to output after each capture.
+type Recorder struct {
+	proxy *httputil.ReverseProxy
+	output string
+	filesDir string
+	port int
+	// OnRecord, if set, is called with every captured endpoint.
+	OnRecord func(Endpoint)
+
+	mu sync.Mutex
+	recordings []recording
+	index map[string]int
+}
+
+// recording is a captured response and the query it was requested with.
+type recording struct {
+	query url.Values
+	endpoint Endpoint
+}
+
+func NewRecorder(target, output string, port int) (*Recorder, error) {
+	targetURL, err := parseTargetURL(target)
+	if err != nil {
+		return nil, err
+	}
+
+	recorder := &Recorder{
+		output: output,
+		filesDir: strings.TrimSuffix(output, filepath.Ext(output)) + "_files",
+		port: port,
+		index: make(map[string]int),
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+	director := proxy.Director
+	proxy.Director = func(r *http.Request) {
+		director(r)
+		r.Host = targetURL.Host
+		// Let the transport negotiate and decode compression so bodies are
+		// recorded as plain text.
+		r.Header.Del("Accept-Encoding")
+	}
+	proxy.ModifyResponse = recorder.capture
+	recorder.proxy = proxy
+	return recorder, nil
+}
+
+func (rec *Recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), recordURLKey{}, r.URL)
+	rec.proxy.ServeHTTP(w, r.WithContext(ctx))
+}
+
+func (rec *Recorder) capture(resp *http.Response) error {
+	method := resp.Request.Method
+	if method == http.MethodHead || method == http.MethodOptions {
+		return nil
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	path, query := "/", url.Values{}
+	if requested, ok := resp.Request.Context().Value(recordURLKey{}).(*url.URL); ok {
+		if requested.Path != "" {
+			path = requested.Path
+		}
+		query = requested.Query()
+	}
+	endpoint := Endpoint{
+		Path: path,
+		Method: method,
+		Status: resp.StatusCode,
+	}
+
+	for name, values := range resp.Header {
+		if recordSkippedHeaders[name] || len(values) == 0 {
+			continue
+		}
+		if endpoint.Headers == nil {
+			endpoint.Headers = make(map[string]string)
+		}
+		// One line per value, so repeated headers such as Set-Cookie
+		// replay as they were sent.
+		endpoint.Headers[name] = strings.Join(values, "\n")
+	}
+
+	// Recorded bodies are literal: without template: true they replay
+	// byte for byte, even if they contain "{{".
+	if utf8.Valid(body) {
+		text := string(body)
+		endpoint.Body = &text
+	} else {
+		file, err := rec.writeFile(endpoint, query, resp.Header.Get("Content-Type"), body)
+		if err != nil {
+			return err
+		}
+		endpoint.File = file
+	}
+
+	return rec.add(endpoint, query)
+}
+
+// writeFile stores a binary response body next to the recording.
+func (rec *Recorder) writeFile(endpoint Endpoint, query url.Values, contentType string, body []byte) (string, error) {
+	ext := ".bin"
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+
+	name := strings.Trim(endpoint.Path, "/")
+	if name == "" {
+		name = "root"
+	}
+	if len(query) > 0 {
+		name += "_" + strings.NewReplacer("&", "_", "=", "-").Replace(query.Encode())
+	}
+	name = strings.ToLower(endpoint.Method) + "_" + strings.NewReplacer("/", "_", "{", "", "}", "", ":", "_").Replace(name) + ext
+
+	if err := os.MkdirAll(rec.filesDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(rec.filesDir, name)
+	if err := os.WriteFile(path, body, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (rec *Recorder) add(endpoint Endpoint, query url.Values) error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	key := routeKey(endpoint.Method, endpoint.Path) + "?" + query.Encode()
+	if i, ok := rec.index[key]; ok {
+		rec.recordings[i].endpoint = endpoint
+	} else {
+		rec.index[key] = len(rec.recordings)
+		rec.recordings = append(rec.recordings, recording{query: query, endpoint: endpoint})
+	}
+
+	data, err := MarshalConfig(&Config{Port: rec.port, Endpoints: rec.endpoints()})
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(rec.output, data, 0644); err != nil {
+		return err
+	}
+	if rec.OnRecord != nil {
+		rec.OnRecord(endpoint)
+	}
+	return nil
+}
+
+// Endpoints returns the endpoints recorded so far.
+func (rec *Recorder) Endpoints() []Endpoint {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.endpoints()
+}
+
+// endpoints merges the recordings into one endpoint per method and path.
+// The response without a query, or else the first one recorded, answers
+// by default; the others become rules matching their query, the most
+// specific first.
+func (rec *Recorder) endpoints() []Endpoint {
+	var endpoints []Endpoint
+	variants := make(map[string][]recording)
+	for _, recorded := range rec.recordings {
+		key := routeKey(recorded.endpoint.Method, recorded.endpoint.Path)
+		if _, ok := variants[key]; !ok {
+			endpoints = append(endpoints, recorded.endpoint)
+		}
+		variants[key] = append(variants[key], recorded)
+	}
+
+	for i, endpoint := range endpoints {
+		recorded := variants[routeKey(endpoint.Method, endpoint.Path)]
+		base := 0
+		for j, variant := range recorded {
+			if len(variant.query) == 0 {
+				base = j
+				break
+			}
+		}
+		endpoint = recorded[base].endpoint
+		var rules []ResponseRule
+		for j, variant := range recorded {
+			if j == base {
+				continue
+			}
+			match := MatchConfig{Query: make(map[string]Matcher, len(variant.query))}
+			for name, values := range variant.query {
+				if len(values) == 1 {
+					value := values[0]
+					match.Query[name] = Matcher{Equals: &value}
+				} else {
+					match.Query[name] = Matcher{Values: values}
+				}
+			}
+			rules = append(rules, ResponseRule{Match: match, Response: Response{
+				Status: variant.endpoint.Status,
+				Headers: variant.endpoint.Headers,
+				Body: variant.endpoint.Body,
+				File: variant.endpoint.File,
+			}})
+		}
+		sort.SliceStable(rules, func(a, b int) bool {
+			if len(rules[a].Match.Query) != len(rules[b].Match.Query) {
+				return len(rules[a].Match.Query) > len(rules[b].Match.Query)
+			}
+			return queryValues(rules[a].Match) > queryValues(rules[b].Match)
+		})
+		endpoint.Responses = rules
+		endpoints[i] = endpoint
+	}
+	return endpoints
+}
+
+// queryValues counts the query values a recorded rule requires.
+func queryValues(match MatchConfig) int {
+	count := 0
+	for _, matcher := range match.Query {
+		count += len(matcher.Values)
+		if matcher.Equals != nil {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,238 @@
+package mocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users":
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("X-Request-Id", "abc")
+			w.Header().Add("Set-Cookie", "a=1")
+			w.Header().Add("Set-Cookie", "b=2")
+			if r.URL.Query().Get("page") == "2" {
+				w.Write([]byte(`[{"id":2,"name":"Grace"}]`))
+				return
+			}
+			w.Write([]byte(`[{"id":1,"name":"Ada"}]`))
+		case "/avatar":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte{0x89, 'P', 'N', 'G', 0xff, 0xfe})
+		case "/gone":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "recorded.yaml")
+	recorder, err := NewRecorder(backend.URL, output, 6060)
+	require.NoError(t, err)
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("GET", "/users?page=1", nil),
+		httptest.NewRequest("GET", "/users?page=2", nil),
+		httptest.NewRequest("GET", "/avatar", nil),
+		httptest.NewRequest("DELETE", "/gone", nil),
+		httptest.NewRequest("HEAD", "/users", nil),
+	} {
+		rr := httptest.NewRecorder()
+		recorder.ServeHTTP(rr, req)
+		if req.URL.RequestURI() == "/users?page=1" && req.Method == "GET" {
+			assert.Equal(t, `[{"id":1,"name":"Ada"}]`, rr.Body.String())
+		}
+	}
+
+	endpoints := recorder.Endpoints()
+	require.Len(t, endpoints, 3)
+	users := endpoints[0]
+	assert.Equal(t, "/users", users.Path)
+	assert.Equal(t, 200, users.Status)
+	require.NotNil(t, users.Body)
+	assert.Equal(t, `[{"id":1,"name":"Ada"}]`, *users.Body)
+	assert.Equal(t, "abc", users.Headers["X-Request-Id"])
+	assert.Equal(t, "a=1\nb=2", users.Headers["Set-Cookie"])
+	require.Len(t, users.Responses, 1, "other queries become rules")
+	assert.Equal(t, "2", *users.Responses[0].Match.Query["page"].Equals)
+	assert.Equal(t, `[{"id":2,"name":"Grace"}]`, *users.Responses[0].Body)
+	assert.NotContains(t, users.Headers, "Content-Length")
+	assert.NotContains(t, users.Headers, "Date")
+
+	avatar := endpoints[1]
+	assert.Nil(t, avatar.Body)
+	assert.Equal(t, filepath.Join(dir, "recorded_files", "get_avatar.png"), avatar.File)
+
+	gone := endpoints[2]
+	assert.Equal(t, 204, gone.Status)
+	require.NotNil(t, gone.Body)
+	assert.Equal(t, "", *gone.Body)
+
+	// The recording replays through the normal config path.
+	config, err := LoadConfig(output)
+	require.NoError(t, err)
+	assert.Equal(t, 6060, config.Port)
+	server, _, err := NewServer(config)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/users", nil))
+	assert.Equal(t, 200, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, []string{"a=1", "b=2"}, rr.Header().Values("Set-Cookie"))
+	assert.Equal(t, `[{"id":1,"name":"Ada"}]`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/users?page=2", nil))
+	assert.Equal(t, `[{"id":2,"name":"Grace"}]`, rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/avatar", nil))
+	assert.Equal(t, "image/png", rr.Header().Get("Content-Type"))
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G', 0xff, 0xfe}, rr.Body.Bytes())
+
+	_, err = NewRecorder("staging.example", output, 6060)
+	assert.Error(t, err)
+}
+
+func TestRecorderRepeatedQuery(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, strings.Join(r.URL.Query()["tag"], ","))
+	}))
+	defer backend.Close()
+
+	output := filepath.Join(t.TempDir(), "recorded.yaml")
+	recorder, err := NewRecorder(backend.URL, output, 6060)
+	require.NoError(t, err)
+	for _, target := range []string{"/items", "/items?tag=a", "/items?tag=a&tag=b"} {
+		recorder.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	items := recorder.Endpoints()[0]
+	require.Len(t, items.Responses, 2)
+	assert.Equal(t, []string{"a", "b"}, items.Responses[0].Match.Query["tag"].Values)
+
+	config, err := LoadConfig(output)
+	require.NoError(t, err)
+	server, _, err := NewServer(config)
+	require.NoError(t, err)
+	for target, want := range map[string]string{
+		"/items": "",
+		"/items?tag=a": "a",
+		"/items?tag=a&tag=b": "a,b",
+		"/items?tag=b&tag=a": "a,b",
+	} {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, want, rr.Body.String(), target)
+	}
+}
+
+func TestRecorderReplaysTemplateText(t *testing.T) {
+	bodies := map[string]string{
+		"/card": `{"greeting": "Hello {{name}}!", "partial": "{{> footer}}"}`,
+		"/card?lang=go": `{{ if }} {{ .Secret }}`,
+	}
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, bodies[r.URL.RequestURI()])
+	}))
+	defer backend.Close()
+
+	output := filepath.Join(t.TempDir(), "recorded.yaml")
+	recorder, err := NewRecorder(backend.URL, output, 6060)
+	require.NoError(t, err)
+	for target := range bodies {
+		recorder.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", target, nil))
+	}
+
+	config, err := LoadConfig(output)
+	require.NoError(t, err)
+	server, err := New(config)
+	require.NoError(t, err)
+	for target, want := range bodies {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		assert.Equal(t, 200, rr.Code, target)
+		assert.Equal(t, want, rr.Body.String(), target)
+	}
+}
+
+func TestProxyPassthrough(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend-Host", r.Host)
+		w.WriteHeader(http.StatusTeapot)
+		fmt.Fprintf(w, "%s %s", r.Method, r.URL.RequestURI())
+	}))
+	defer backend.Close()
+
+	var buf bytes.Buffer
+	logger := &Logger{writer: &buf, format: "json"}
+	config := &Config{
+		Port:      8080,
+		Endpoints: []Endpoint{{Path: "/users", Method: "GET", Status: 200, Count: 1, Data: `{"id": "uuid"}`}},
+		Proxy:     &ProxyConfig{Target: backend.URL},
+	}
+	router, messages, err := rebuildRouter(config, logger, nil)
+	require.NoError(t, err)
+	assert.Contains(t, messages, "Proxy: unmatched requests go to "+backend.URL)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/orders?page=2", nil))
+	assert.Equal(t, http.StatusTeapot, rr.Code)
+	assert.Equal(t, "GET /orders?page=2", rr.Body.String())
+	assert.Equal(t, "example.com", rr.Header().Get("X-Backend-Host"))
+
+	var reqLog RequestLog
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &reqLog))
+	assert.True(t, reqLog.Proxied)
+	assert.Equal(t, http.StatusTeapot, reqLog.StatusCode)
+	assert.Equal(t, int64(len("GET /orders?page=2")), reqLog.ContentLength)
+
+	// Methods the mock does not define for a path are proxied too.
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/users", nil))
+	assert.Equal(t, "POST /users", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/users", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	config.Proxy.RewriteHost = true
+	router, _, err = rebuildRouter(config, logger, nil)
+	require.NoError(t, err)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+	assert.Equal(t, strings.TrimPrefix(backend.URL, "http://"), rr.Header().Get("X-Backend-Host"))
+}
+
+func TestProxyUnreachable(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	target := backend.URL
+	backend.Close()
+
+	logger, _ := NewLogger(LogConfig{Enabled: false})
+	handler, err := createProxyHandler(ProxyConfig{Target: target}, logger)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/orders", nil))
+	assert.Equal(t, http.StatusBadGateway, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Proxy error")
+
+	_, err = createProxyHandler(ProxyConfig{Target: "localhost:9000"}, logger)
+	assert.Error(t, err)
+}
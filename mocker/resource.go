@@ -0,0 +1,367 @@
+package mocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type ResourceStore struct {
+	mu sync.RWMutex
+	idField string
+	schema string
+	items []map[string]interface{}
+	// src generates new ids; it is only used under mu.
+	src *fakeSource
+}
+
+// NewResourceStore seeds a collection with endpoint.Count records generated
+// from the endpoint's data schema.
+func NewResourceStore(endpoint Endpoint) (*ResourceStore, error) {
+	idField := endpoint.IDField
+	if idField == "" {
+		idField = "id"
+	}
+
+	src := newFakeSource(endpoint.Seed)
+	items, err := generateFakeDataWith(src, endpoint.Data, endpoint.Count)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &ResourceStore{idField: idField, schema: endpoint.Data, src: src}
+	for i, item := range items {
+		if _, numeric := item[idField].(int); numeric {
+			item[idField] = i + 1
+		} else if _, exists := item[idField]; !exists {
+			item[idField] = src.uuid()
+		}
+		store.items = append(store.items, item)
+	}
+	return store, nil
+}
+
+func copyRecord(item map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(item))
+	for key, value := range item {
+		copied[key] = value
+	}
+	return copied
+}
+
+// intID returns id as an int if it is a whole number, whatever type JSON
+// decoding gave it.
+func intID(id interface{}) (int, bool) {
+	switch v := id.(type) {
+	case int:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) <= 1<<53 {
+			return int(v), true
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return int(n), true
+		}
+	}
+	return 0, false
+}
+
+func (s *ResourceStore) indexOf(id string) int {
+	for i, item := range s.items {
+		if fmt.Sprintf("%v", item[s.idField]) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextID continues numeric id sequences and otherwise asks the schema for a
+// fresh value, falling back to a UUID when the schema has no id field. Both
+// come from the store's source, so a seeded store repeats its ids.
+func (s *ResourceStore) nextID() interface{} {
+	var candidate interface{}
+	if generated, err := generateFakeDataWith(s.src, s.schema, 1); err == nil && len(generated) == 1 {
+		candidate = generated[0][s.idField]
+	}
+
+	if _, numeric := candidate.(int); numeric {
+		maxID := 0
+		for _, item := range s.items {
+			if id, ok := intID(item[s.idField]); ok && id > maxID {
+				maxID = id
+			}
+		}
+		return maxID + 1
+	}
+
+	if candidate != nil && s.indexOf(fmt.Sprintf("%v", candidate)) == -1 {
+		return candidate
+	}
+	return s.src.uuid()
+}
+
+func (s *ResourceStore) List() []map[string]interface{} {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := make([]map[string]interface{}, 0, len(s.items))
+	for _, item := range s.items {
+		items = append(items, copyRecord(item))
+	}
+	return items
+}
+
+func (s *ResourceStore) Get(id string) (map[string]interface{}, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	index := s.indexOf(id)
+	if index == -1 {
+		return nil, false
+	}
+	return copyRecord(s.items[index]), true
+}
+
+// Create adds item, assigning an id when it has none. Whole-number ids
+// are stored as int, so later ids continue after them. It reports false
+// and stores nothing when a record with the given id already exists.
+func (s *ResourceStore) Create(item map[string]interface{}) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	created := copyRecord(item)
+	if id, exists := created[s.idField]; !exists || id == nil || id == "" {
+		created[s.idField] = s.nextID()
+	} else if s.indexOf(fmt.Sprintf("%v", id)) != -1 {
+		return nil, false
+	} else if n, ok := intID(id); ok {
+		created[s.idField] = n
+	}
+	s.items = append(s.items, created)
+	return copyRecord(created), true
+}
+
+// Replace swaps the record for id with item, keeping the original id.
+func (s *ResourceStore) Replace(id string, item map[string]interface{}) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(id)
+	if index == -1 {
+		return nil, false
+	}
+	replaced := copyRecord(item)
+	replaced[s.idField] = s.items[index][s.idField]
+	s.items[index] = replaced
+	return copyRecord(replaced), true
+}
+
+// Update merges the fields of patch into the record for id.
+func (s *ResourceStore) Update(id string, patch map[string]interface{}) (map[string]interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(id)
+	if index == -1 {
+		return nil, false
+	}
+	updated := copyRecord(s.items[index])
+	for key, value := range patch {
+		if key == s.idField {
+			continue
+		}
+		updated[key] = value
+	}
+	s.items[index] = updated
+	return copyRecord(updated), true
+}
+
+func (s *ResourceStore) Delete(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	index := s.indexOf(id)
+	if index == -1 {
+		return false
+	}
+	s.items = append(s.items[:index], s.items[index+1:]...)
+	return true
+}
+
+// createResourceHandler serves both the collection path and the item path
+// of a resource endpoint; the item id arrives as a path parameter.
+func createResourceHandler(endpoint Endpoint, store *ResourceStore, logger *Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		authSuccess, authType, authResult := authenticateRequest(r, endpoint.Auth)
+
+		respond := func(statusCode int, body interface{}) {
+			var responseData []byte
+			if body != nil {
+				responseData, _ = json.Marshal(body)
+				if w.Header().Get("Content-Type") == "" {
+					w.Header().Set("Content-Type", "application/json")
+				}
+			}
+			w.WriteHeader(statusCode)
+			w.Write(responseData)
+
+			duration := time.Since(start)
+			reqLog := RequestLog{
+				Timestamp: start.Format(time.RFC3339),
+				Method: r.Method,
+				Protocol: r.Proto,
+				Path: r.URL.Path,
+				Query: r.URL.RawQuery,
+				StatusCode: statusCode,
+				ResponseTime: duration.String(),
+				UserAgent: r.Header.Get("User-Agent"),
+				RemoteAddr: r.RemoteAddr,
+				ContentLength: int64(len(responseData)),
+				AuthType: authType,
+				AuthResult: authResult,
+				PathParams: pathParams(r),
+			}
+			logger.LogRequest(reqLog)
+		}
+
+		if !authSuccess {
+			respond(http.StatusUnauthorized, map[string]string{"error": "Authentication required"})
+			return
+		}
+
+		if endpoint.Delay != "" {
+			delay := parseDuration(endpoint.Delay)
+			if delay > 0 {
+				time.Sleep(delay)
+			}
+		}
+
+		if shouldError, errorConfig := shouldTriggerError(endpoint.Errors); shouldError {
+			if errorConfig.Message != "" {
+				respond(errorConfig.Status, map[string]string{"error": errorConfig.Message})
+			} else {
+				respond(errorConfig.Status, nil)
+			}
+			return
+		}
+
+		writeHeaders(w.Header(), endpoint.Headers)
+
+		readBody := func() (map[string]interface{}, bool) {
+			var item map[string]interface{}
+			if err := json.NewDecoder(r.Body).Decode(&item); err != nil || item == nil {
+				respond(http.StatusBadRequest, map[string]string{"error": "Request body must be a JSON object"})
+				return nil, false
+			}
+			return item, true
+		}
+		notFound := func() {
+			respond(http.StatusNotFound, map[string]string{"error": "Resource not found"})
+		}
+
+		id, isItem := pathParams(r)[store.idField]
+		if !isItem {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead:
+				params := r.URL.Query()
+				items := store.List()
+				filteredData := ApplyQueryFilters(items, params)
+				if params.Get("meta") == "true" {
+					respond(http.StatusOK, map[string]interface{}{
+						"data": filteredData,
+						"meta": map[string]interface{}{
+							"count": len(filteredData),
+							"total": len(items),
+							"offset": params.Get("offset"),
+							"limit": params.Get("count"),
+							"sort": params.Get("sort"),
+							"order": params.Get("order"),
+							"filter": params.Get("filter"),
+							"status": http.StatusOK,
+						},
+					})
+				} else {
+					respond(http.StatusOK, filteredData)
+				}
+			case http.MethodPost:
+				item, ok := readBody()
+				if !ok {
+					return
+				}
+				created, ok := store.Create(item)
+				if !ok {
+					respond(http.StatusConflict, map[string]string{"error": "Resource already exists"})
+					return
+				}
+				w.Header().Set("Location", strings.TrimSuffix(r.URL.Path, "/")+"/"+fmt.Sprintf("%v", created[store.idField]))
+				respond(http.StatusCreated, created)
+			default:
+				respond(http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+			}
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+			item, found := store.Get(id)
+			if !found {
+				notFound()
+				return
+			}
+			respond(http.StatusOK, item)
+		case http.MethodPut, http.MethodPatch:
+			item, ok := readBody()
+			if !ok {
+				return
+			}
+			var updated map[string]interface{}
+			var found bool
+			if r.Method == http.MethodPut {
+				updated, found = store.Replace(id, item)
+			} else {
+				updated, found = store.Update(id, item)
+			}
+			if !found {
+				notFound()
+				return
+			}
+			respond(http.StatusOK, updated)
+		case http.MethodDelete:
+			if !store.Delete(id) {
+				notFound()
+				return
+			}
+			respond(http.StatusNoContent, nil)
+		default:
+			respond(http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+		}
+	}
+}
+
+func resourceItemPath(endpoint Endpoint) string {
+	idField := endpoint.IDField
+	if idField == "" {
+		idField = "id"
+	}
+	return strings.TrimSuffix(endpoint.Path, "/") + "/{" + idField + "}"
+}
+
+func registerResourceRoutes(router *Router, endpoint Endpoint, handler http.Handler) error {
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if err := router.Handle(endpoint.Path, method, handler); err != nil {
+			return err
+		}
+	}
+	for _, method := range []string{http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+		if err := router.Handle(resourceItemPath(endpoint), method, handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
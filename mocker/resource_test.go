@@ -0,0 +1,204 @@
+package mocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResourceStore(t *testing.T) {
+	store, err := NewResourceStore(Endpoint{
+		Data: `{"id": "int", "name": "name"}`,
+		Count: 3,
+	})
+	require.NoError(t, err)
+
+	items := store.List()
+	require.Len(t, items, 3)
+	assert.Equal(t, 1, items[0]["id"])
+	assert.Equal(t, 3, items[2]["id"])
+
+	created, ok := store.Create(map[string]interface{}{"name": "Alice"})
+	require.True(t, ok)
+	assert.Equal(t, 4, created["id"])
+
+	_, ok = store.Create(map[string]interface{}{"id": 4, "name": "Duplicate"})
+	assert.False(t, ok)
+
+	item, found := store.Get("4")
+	require.True(t, found)
+	assert.Equal(t, "Alice", item["name"])
+
+	updated, found := store.Update("4", map[string]interface{}{"email": "alice@example.com", "id": 99})
+	require.True(t, found)
+	assert.Equal(t, 4, updated["id"])
+	assert.Equal(t, "Alice", updated["name"])
+	assert.Equal(t, "alice@example.com", updated["email"])
+
+	replaced, found := store.Replace("4", map[string]interface{}{"name": "Bob"})
+	require.True(t, found)
+	assert.Equal(t, map[string]interface{}{"id": 4, "name": "Bob"}, replaced)
+
+	assert.True(t, store.Delete("4"))
+	assert.False(t, store.Delete("4"))
+	_, found = store.Get("4")
+	assert.False(t, found)
+	assert.Len(t, store.List(), 3)
+
+	// Ids set by clients over JSON arrive as float64 or json.Number.
+	created, ok = store.Create(map[string]interface{}{"id": float64(7), "name": "Carol"})
+	require.True(t, ok)
+	assert.Equal(t, 7, created["id"])
+	created, ok = store.Create(map[string]interface{}{"name": "Dan"})
+	require.True(t, ok)
+	assert.Equal(t, 8, created["id"])
+	_, ok = store.Create(map[string]interface{}{"id": json.Number("8")})
+	assert.False(t, ok)
+	created, ok = store.Create(map[string]interface{}{"id": json.Number("20")})
+	require.True(t, ok)
+	assert.Equal(t, 20, created["id"])
+	created, ok = store.Create(map[string]interface{}{"name": "Eve"})
+	require.True(t, ok)
+	assert.Equal(t, 21, created["id"])
+}
+
+func TestResourceStoreUUIDIDs(t *testing.T) {
+	store, err := NewResourceStore(Endpoint{
+		Data: `{"uid": "uuid", "name": "name"}`,
+		Count: 2,
+		IDField: "uid",
+	})
+	require.NoError(t, err)
+
+	created, ok := store.Create(map[string]interface{}{"name": "Alice"})
+	require.True(t, ok)
+	id, ok := created["uid"].(string)
+	require.True(t, ok)
+	assert.Len(t, id, 36)
+
+	_, found := store.Get(id)
+	assert.True(t, found)
+}
+
+func TestResourceStoreSeeded(t *testing.T) {
+	seed := int64(7)
+	endpoint := Endpoint{Data: `{"uid": "uuid", "name": "name"}`, Count: 2, IDField: "uid", Seed: &seed}
+
+	snapshot := func() []map[string]interface{} {
+		store, err := NewResourceStore(endpoint)
+		require.NoError(t, err)
+		created, ok := store.Create(map[string]interface{}{"name": "Alice"})
+		require.True(t, ok)
+		return append(store.List(), created)
+	}
+	assert.Equal(t, snapshot(), snapshot())
+}
+
+func TestResourceHandler(t *testing.T) {
+	logger := &Logger{writer: io.Discard, format: "json"}
+	endpoint := Endpoint{
+		Path: "/users",
+		Kind: "resource",
+		Data: `{"id": "int", "name": "name", "email": "email"}`,
+		Count: 3,
+	}
+
+	router := NewRouter(logger)
+	store, err := NewResourceStore(endpoint)
+	require.NoError(t, err)
+	require.NoError(t, registerResourceRoutes(router, endpoint, createResourceHandler(endpoint, store, logger)))
+
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		var reader io.Reader
+		if body != "" {
+			reader = bytes.NewBufferString(body)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(method, target, reader))
+		return rr
+	}
+
+	rr := do("POST", "/users", `{"name": "Zed Created", "email": "zed@example.com"}`)
+	require.Equal(t, 201, rr.Code)
+	assert.Equal(t, "/users/4", rr.Header().Get("Location"))
+
+	var created map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &created))
+	assert.Equal(t, float64(4), created["id"])
+
+	rr = do("GET", "/users/4", "")
+	require.Equal(t, 200, rr.Code)
+	assert.Contains(t, rr.Body.String(), "Zed Created")
+
+	rr = do("POST", "/users", `{"id": 4, "name": "Duplicate"}`)
+	assert.Equal(t, 409, rr.Code)
+
+	rr = do("POST", "/users", `{"id": 7, "name": "Client id"}`)
+	require.Equal(t, 201, rr.Code)
+	rr = do("POST", "/users", `{"name": "Auto id"}`)
+	require.Equal(t, 201, rr.Code)
+	assert.Equal(t, "/users/8", rr.Header().Get("Location"), "auto ids continue after client ids")
+	require.Equal(t, 204, do("DELETE", "/users/7", "").Code)
+	require.Equal(t, 204, do("DELETE", "/users/8", "").Code)
+
+	rr = do("GET", "/users?filter=name:zed&meta=true", "")
+	require.Equal(t, 200, rr.Code)
+	var listed map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &listed))
+	assert.Len(t, listed["data"], 1)
+	assert.Equal(t, float64(4), listed["meta"].(map[string]interface{})["total"])
+
+	rr = do("PATCH", "/users/4", `{"email": "new@example.com"}`)
+	require.Equal(t, 200, rr.Code)
+	assert.Contains(t, rr.Body.String(), "new@example.com")
+	assert.Contains(t, rr.Body.String(), "Zed Created")
+
+	rr = do("PUT", "/users/4", `{"name": "Replaced"}`)
+	require.Equal(t, 200, rr.Code)
+	assert.NotContains(t, rr.Body.String(), "email")
+
+	rr = do("PUT", "/users/4", `not json`)
+	assert.Equal(t, 400, rr.Code)
+
+	rr = do("DELETE", "/users/4", "")
+	assert.Equal(t, 204, rr.Code)
+
+	rr = do("GET", "/users/4", "")
+	assert.Equal(t, 404, rr.Code)
+
+	rr = do("GET", "/users?count=2", "")
+	var page []map[string]interface{}
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &page))
+	assert.Len(t, page, 2)
+}
+
+func TestResourceHandlerWithAuth(t *testing.T) {
+	logger := &Logger{writer: io.Discard, format: "json"}
+	endpoint := Endpoint{
+		Path: "/orders",
+		Kind: "resource",
+		Data: `{"id": "uuid"}`,
+		Count: 1,
+		Auth: &AuthConfig{Type: "bearer", Token: "secret"},
+	}
+
+	router := NewRouter(logger)
+	store, err := NewResourceStore(endpoint)
+	require.NoError(t, err)
+	require.NoError(t, registerResourceRoutes(router, endpoint, createResourceHandler(endpoint, store, logger)))
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`)))
+	assert.Equal(t, 401, rr.Code)
+
+	req := httptest.NewRequest("POST", "/orders", bytes.NewBufferString(`{}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, 201, rr.Code)
+}
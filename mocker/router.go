@@ -0,0 +1,586 @@
+package mocker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+type pathParamsKey struct{}
+
+type pathSegment struct {
+	literal string
+	param string
+	pattern *regexp.Regexp
+}
+
+type route struct {
+	pattern string
+	segments []pathSegment
+	handlers map[string]http.Handler
+}
+
+// stateHandlers serves a path and method shared by endpoints bound to
+// different scenario states, each answered while its state is current.
+type stateHandlers []stateHandler
+
+type stateHandler struct {
+	scenario string
+	state string
+	handler http.Handler
+}
+
+// stateBound lets handler share its path and method with other endpoints
+// bound to other states, if ep is bound to a scenario state.
+func stateBound(ep Endpoint, handler http.Handler) http.Handler {
+	if ep.Scenario == "" || ep.State == "" {
+		return handler
+	}
+	return stateHandlers{{scenario: ep.Scenario, state: ep.State, handler: handler}}
+}
+
+func (hs stateHandlers) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	store := scenarios(r)
+	for _, h := range hs {
+		if store.State(h.scenario) == h.state {
+			h.handler.ServeHTTP(w, r)
+			return
+		}
+	}
+	// The first endpoint answers that it is not available in this state.
+	hs[0].handler.ServeHTTP(w, r)
+}
+
+// mergeStateHandlers combines two sets of state-bound handlers, unless one of them is
+// not state-bound or both answer in the same state.
+func mergeStateHandlers(a, b http.Handler) (http.Handler, bool) {
+	left, ok := a.(stateHandlers)
+	if !ok {
+		return nil, false
+	}
+	right, ok := b.(stateHandlers)
+	if !ok {
+		return nil, false
+	}
+	merged := append(stateHandlers(nil), left...)
+	for _, h := range right {
+		for _, existing := range merged {
+			if existing.scenario == h.scenario && existing.state == h.state {
+				return nil, false
+			}
+		}
+		merged = append(merged, h)
+	}
+	return merged, true
+}
+
+type Router struct {
+	routes []*route
+	logger *Logger
+	fallback http.Handler
+	scenarios *ScenarioStore
+	// state holds the runtime state of each endpoint by stateKey, for the
+	// next router built with this one as its predecessor.
+	state map[string]*endpointState
+}
+
+// endpointState is the runtime state of one endpoint: the records of a
+// resource and the positions in a sequence.
+type endpointState struct {
+	resource *ResourceStore
+	sequence *sequenceCounter
+}
+
+// stateKey identifies an endpoint for carrying its state over to a rebuilt
+// router. Only the fields that define the state take part: changing the
+// status, headers, auth, delays or errors of an endpoint keeps its records
+// and sequence positions.
+func stateKey(ep Endpoint) string {
+	var sequence *SequenceConfig
+	if ep.Sequence != nil {
+		stripped := *ep.Sequence
+		stripped.Steps = make([]Response, len(ep.Sequence.Steps))
+		for i, step := range ep.Sequence.Steps {
+			step.Delay = ""
+			stripped.Steps[i] = step
+		}
+		sequence = &stripped
+	}
+	data, _ := json.Marshal(struct {
+		Method string
+		Path string
+		Kind string
+		IDField string
+		Data string
+		Count int
+		Seed *int64
+		Scenario string
+		State string
+		Sequence *SequenceConfig
+	}{ep.Method, ep.Path, ep.Kind, ep.IDField, ep.Data, ep.Count, ep.Seed, ep.Scenario, ep.State, sequence})
+	return string(data)
+}
+
+func NewRouter(logger *Logger) *Router {
+	if logger == nil {
+		logger = &Logger{writer: io.Discard}
+	}
+	return &Router{logger: logger, scenarios: NewScenarioStore(), state: map[string]*endpointState{}}
+}
+
+func splitPath(path string) []string {
+	trimmed := strings.Trim(path, "/")
+	if trimmed == "" {
+		return []string{}
+	}
+	return strings.Split(trimmed, "/")
+}
+
+func compilePathPattern(pattern string) ([]pathSegment, error) {
+	// Request paths are matched segment by segment, so a parameter can never
+	// capture a "/" and a constraint that contains one would split in two.
+	depth, start := 0, 0
+	for i, c := range pattern {
+		switch c {
+		case '{':
+			if depth == 0 {
+				start = i + 1
+			}
+			depth++
+		case '}':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth > 0 {
+				name := pattern[start:]
+				if end := strings.IndexAny(name, ":/}"); end >= 0 {
+					name = name[:end]
+				}
+				return nil, fmt.Errorf("invalid pattern for parameter %q in path %q: parameters match a single segment and cannot contain /", name, pattern)
+			}
+		}
+	}
+
+	parts := splitPath(pattern)
+	segments := make([]pathSegment, 0, len(parts))
+	for _, part := range parts {
+		if !strings.HasPrefix(part, "{") || !strings.HasSuffix(part, "}") {
+			if strings.ContainsAny(part, "{}") {
+				return nil, fmt.Errorf("invalid segment %q in path %q: parameters must span the whole segment", part, pattern)
+			}
+			segments = append(segments, pathSegment{literal: part})
+			continue
+		}
+
+		inner := part[1 : len(part)-1]
+		name, expr, hasExpr := strings.Cut(inner, ":")
+		if name == "" {
+			return nil, fmt.Errorf("empty parameter name in path %q", pattern)
+		}
+		segment := pathSegment{param: name}
+		if hasExpr {
+			re, err := regexp.Compile("^(?:" + expr + ")$")
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for parameter %q in path %q: %v", name, pattern, err)
+			}
+			segment.pattern = re
+		}
+		segments = append(segments, segment)
+	}
+	return segments, nil
+}
+
+// match reports whether path fits the route and returns the captured
+// parameters along with a per-segment rank used to pick the most specific
+// route: literal segments beat constrained parameters, which beat bare ones.
+func (rt *route) match(parts []string) (map[string]string, []int, bool) {
+	if len(parts) != len(rt.segments) {
+		return nil, nil, false
+	}
+
+	params := make(map[string]string)
+	rank := make([]int, len(parts))
+	for i, segment := range rt.segments {
+		switch {
+		case segment.param == "":
+			if parts[i] != segment.literal {
+				return nil, nil, false
+			}
+			rank[i] = 2
+		case segment.pattern != nil:
+			if !segment.pattern.MatchString(parts[i]) {
+				return nil, nil, false
+			}
+			params[segment.param] = parts[i]
+			rank[i] = 1
+		default:
+			params[segment.param] = parts[i]
+		}
+	}
+	return params, rank, true
+}
+
+func moreSpecific(a, b []int) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// Handle registers handler for method on pattern. Endpoints sharing a path
+// are grouped into a single route and dispatched by method; a method can
+// only be registered twice by endpoints bound to different scenario
+// states.
+func (rt *Router) Handle(pattern, method string, handler http.Handler) error {
+	segments, err := compilePathPattern(pattern)
+	if err != nil {
+		return err
+	}
+	method = strings.ToUpper(method)
+
+	for _, existing := range rt.routes {
+		if !sameShape(existing.segments, segments) {
+			continue
+		}
+		if !sameParams(existing.segments, segments) {
+			return fmt.Errorf("path %q conflicts with %q", pattern, existing.pattern)
+		}
+		if current, exists := existing.handlers[method]; exists {
+			merged, ok := mergeStateHandlers(current, handler)
+			if !ok {
+				return fmt.Errorf("duplicate endpoint %s %s", method, pattern)
+			}
+			existing.handlers[method] = merged
+			return nil
+		}
+		existing.handlers[method] = handler
+		return nil
+	}
+
+	rt.routes = append(rt.routes, &route{
+		pattern: pattern,
+		segments: segments,
+		handlers: map[string]http.Handler{method: handler},
+	})
+	return nil
+}
+
+// allowedMethods lists the methods the matched routes answer together,
+// including the HEAD and OPTIONS responses the router provides on its own.
+func allowedMethods(matches []routeMatch) []string {
+	seen := map[string]bool{http.MethodOptions: true}
+	for _, m := range matches {
+		for method := range m.route.handlers {
+			seen[method] = true
+		}
+		if _, hasGet := m.route.handlers[http.MethodGet]; hasGet {
+			seen[http.MethodHead] = true
+		}
+	}
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// sameShape reports whether two patterns match exactly the same paths.
+func sameShape(a, b []pathSegment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if (a[i].param == "") != (b[i].param == "") {
+			return false
+		}
+		if a[i].param == "" && a[i].literal != b[i].literal {
+			return false
+		}
+		if a[i].param != "" && patternString(a[i]) != patternString(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameParams(a, b []pathSegment) bool {
+	for i := range a {
+		if a[i].param != b[i].param {
+			return false
+		}
+	}
+	return true
+}
+
+func patternString(segment pathSegment) string {
+	if segment.pattern == nil {
+		return ""
+	}
+	return segment.pattern.String()
+}
+
+// routeMatch is a route that fits a request path.
+type routeMatch struct {
+	route *route
+	params map[string]string
+	rank []int
+}
+
+// lookup returns every route that fits path.
+func (rt *Router) lookup(path string) []routeMatch {
+	parts := splitPath(path)
+
+	var matches []routeMatch
+	for _, candidate := range rt.routes {
+		if params, rank, ok := candidate.match(parts); ok {
+			matches = append(matches, routeMatch{route: candidate, params: params, rank: rank})
+		}
+	}
+	return matches
+}
+
+// bestMatch returns the most specific of matches with a handler for
+// method, so a less specific route still answers a method that a more
+// specific one lacks.
+func bestMatch(matches []routeMatch, method string) (routeMatch, bool) {
+	var best routeMatch
+	found := false
+	for _, m := range matches {
+		if _, ok := m.route.handlers[method]; !ok {
+			continue
+		}
+		if !found || moreSpecific(m.rank, best.rank) {
+			best, found = m, true
+		}
+	}
+	return best, found
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	matches := rt.lookup(r.URL.Path)
+	if len(matches) == 0 {
+		if rt.fallback != nil {
+			rt.fallback.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+		return
+	}
+
+	method := r.Method
+	matched, ok := bestMatch(matches, method)
+	if !ok && method == http.MethodHead {
+		method = http.MethodGet
+		matched, ok = bestMatch(matches, method)
+	}
+	if ok {
+		ctx := context.WithValue(r.Context(), scenariosKey{}, rt.scenarios)
+		if len(matched.params) > 0 {
+			ctx = context.WithValue(ctx, pathParamsKey{}, matched.params)
+		}
+		r = r.WithContext(ctx)
+		markJournaled(r, method, matched.route.pattern, matched.params)
+		if method != r.Method {
+			matched.route.handlers[method].ServeHTTP(&headResponseWriter{ResponseWriter: w}, r)
+			return
+		}
+		matched.route.handlers[method].ServeHTTP(w, r)
+		return
+	}
+
+	if r.Method == http.MethodOptions {
+		w.Header().Set("Allow", strings.Join(allowedMethods(matches), ", "))
+		w.WriteHeader(http.StatusNoContent)
+		rt.logRouting(r, start, http.StatusNoContent)
+		return
+	}
+
+	if rt.fallback != nil {
+		rt.fallback.ServeHTTP(w, r)
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(allowedMethods(matches), ", "))
+	http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+	rt.logRouting(r, start, http.StatusMethodNotAllowed)
+}
+
+// markJournaled records the endpoint that handles r in its journal entry.
+func markJournaled(r *http.Request, method, pattern string, params map[string]string) {
+	if entry := journalEntry(r); entry != nil {
+		entry.Endpoint = method + " " + pattern
+		entry.PathParams = params
+	}
+}
+
+// SetFallback routes requests that match no route, or a route without a
+// handler for their method, to handler instead of answering 404 or 405.
+func (rt *Router) SetFallback(handler http.Handler) {
+	rt.fallback = handler
+}
+
+func (rt *Router) logRouting(r *http.Request, start time.Time, statusCode int) {
+	reqLog := RequestLog{
+		Timestamp: start.Format(time.RFC3339),
+		Method: r.Method,
+		Protocol: r.Proto,
+		Path: r.URL.Path,
+		Query: r.URL.RawQuery,
+		StatusCode: statusCode,
+		ResponseTime: time.Since(start).String(),
+		UserAgent: r.Header.Get("User-Agent"),
+		RemoteAddr: r.RemoteAddr,
+		ContentLength: 0,
+		PathParams: pathParams(r),
+	}
+	rt.logger.LogRequest(reqLog)
+}
+
+// headResponseWriter answers HEAD requests with the GET handler's status
+// and headers while dropping the body.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w *headResponseWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func pathParams(r *http.Request) map[string]string {
+	params, _ := r.Context().Value(pathParamsKey{}).(map[string]string)
+	return params
+}
+
+// rebuildRouter registers every endpoint of config on a fresh router and
+// returns the endpoint descriptions shown in the TUI. It takes over the
+// resources, sequences and scenario states of previous, when given, for
+// the endpoints that did not change.
+func rebuildRouter(config *Config, logger *Logger, previous *Router) (*Router, []string, error) {
+	router := NewRouter(logger)
+	var messages []string
+	baseURL := serverURLs(config)[0]
+	for _, ep := range config.Endpoints {
+		if ep.Seed == nil {
+			ep.Seed = config.Seed
+		}
+		key := stateKey(ep)
+		state := &endpointState{}
+		if previous != nil && previous.state[key] != nil {
+			state = previous.state[key]
+		}
+		router.state[key] = state
+		path := ep.Path
+		method := ep.Method
+		msg := fmt.Sprintf("[%s] %s%s", method, baseURL, path)
+		if ep.Kind == "resource" {
+			msg = fmt.Sprintf("[RESOURCE] %s%s (GET, POST, GET/PUT/PATCH/DELETE %s)", baseURL, path, resourceItemPath(ep))
+		}
+
+		if ep.Status != 200 {
+			msg += fmt.Sprintf(" (status: %d)", ep.Status)
+		}
+		if ep.Delay != "" {
+			msg += fmt.Sprintf(" (delay: %s)", ep.Delay)
+		}
+		if len(ep.Errors) > 0 {
+			msg += " (with errors)"
+		}
+
+		messages = append(messages, msg)
+		if ep.Scenario != "" {
+			router.scenarios.register(ep.Scenario)
+		}
+
+		if ep.Kind == "resource" {
+			if state.resource == nil {
+				store, err := NewResourceStore(ep)
+				if err != nil {
+					return nil, nil, fmt.Errorf("failed to register resource %s: %v", path, err)
+				}
+				state.resource = store
+			}
+			if err := registerResourceRoutes(router, ep, createResourceHandler(ep, state.resource, logger)); err != nil {
+				return nil, nil, fmt.Errorf("failed to register resource %s: %v", path, err)
+			}
+			continue
+		}
+
+		if state.sequence == nil {
+			state.sequence = newSequenceCounter(ep.Sequence)
+		} else {
+			state.sequence.configure(ep.Sequence)
+		}
+		var handler http.Handler = createEndpointHandler(ep, state.sequence, logger)
+		if ep.File != "" && len(ep.Responses) == 0 && ep.Sequence == nil && ep.Scenario == "" {
+			handler = serveFileHandler(ep.File, ep, logger)
+		}
+
+		if err := router.Handle(path, method, stateBound(ep, handler)); err != nil {
+			return nil, nil, fmt.Errorf("failed to register endpoint %s %s: %v", method, path, err)
+		}
+	}
+
+	if previous != nil {
+		current := previous.scenarios.Snapshot()
+		for name := range router.scenarios.Snapshot() {
+			if state, ok := current[name]; ok {
+				router.scenarios.Set(name, state)
+			}
+		}
+	}
+
+	if err := router.Handle(adminPrefix+"openapi.json", http.MethodGet, openAPIHandler(config)); err != nil {
+		return nil, nil, err
+	}
+	scenariosHandler := createScenariosHandler(router.scenarios)
+	for _, method := range []string{http.MethodGet, http.MethodDelete} {
+		if err := router.Handle(adminPrefix+"scenarios", method, scenariosHandler); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, method := range []string{http.MethodPut, http.MethodDelete} {
+		if err := router.Handle(adminPrefix+"scenarios/{name}", method, scenariosHandler); err != nil {
+			return nil, nil, err
+		}
+	}
+	if names := router.scenarios.Snapshot(); len(names) > 0 {
+		scenarioNames := make([]string, 0, len(names))
+		for name := range names {
+			scenarioNames = append(scenarioNames, name)
+		}
+		sort.Strings(scenarioNames)
+		messages = append(messages, fmt.Sprintf("Scenarios: %s (%sscenarios)", strings.Join(scenarioNames, ", "), adminPrefix))
+	}
+
+	if config.Proxy != nil {
+		proxy, err := createProxyHandler(*config.Proxy, logger)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create proxy: %v", err)
+		}
+		router.SetFallback(proxy)
+		messages = append(messages, fmt.Sprintf("Proxy: unmatched requests go to %s", config.Proxy.Target))
+	}
+
+	if config.Logging.Enabled {
+		logMsg := fmt.Sprintf("Logging: %s format", config.Logging.Format)
+		if config.Logging.Output == "stdout" {
+			logMsg += " to stdout"
+		} else {
+			logMsg += fmt.Sprintf(" to %s", config.Logging.Output)
+		}
+		messages = append(messages, logMsg)
+	}
+
+	return router, messages, nil
+}
@@ -0,0 +1,210 @@
+package mocker
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterPathParams(t *testing.T) {
+	router := NewRouter(nil)
+	var captured map[string]string
+	capture := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			captured = pathParams(r)
+			w.Header().Set("X-Route", name)
+		})
+	}
+
+	require.NoError(t, router.Handle("/users", "GET", capture("list")))
+	require.NoError(t, router.Handle("/users/me", "GET", capture("me")))
+	require.NoError(t, router.Handle("/users/{id:[0-9]+}", "GET", capture("numeric")))
+	require.NoError(t, router.Handle("/users/{id}/posts/{postId}", "GET", capture("post")))
+	require.NoError(t, router.Handle("/codes/{code:[A-Z]{3}}", "GET", capture("code")))
+
+	tests := []struct {
+		name string
+		path string
+		expectedStatus int
+		expectedRoute string
+		expectedParams map[string]string
+	}{
+		{
+			name: "static path",
+			path: "/users",
+			expectedStatus: 200,
+			expectedRoute: "list",
+		},
+		{
+			name: "literal beats parameter",
+			path: "/users/me",
+			expectedStatus: 200,
+			expectedRoute: "me",
+		},
+		{
+			name: "constrained parameter",
+			path: "/users/42",
+			expectedStatus: 200,
+			expectedRoute: "numeric",
+			expectedParams: map[string]string{"id": "42"},
+		},
+		{
+			name: "constraint mismatch",
+			path: "/users/abc",
+			expectedStatus: 404,
+		},
+		{
+			name: "multiple parameters",
+			path: "/users/abc/posts/7",
+			expectedStatus: 200,
+			expectedRoute: "post",
+			expectedParams: map[string]string{"id": "abc", "postId": "7"},
+		},
+		{
+			name: "regex with quantifier",
+			path: "/codes/ABC",
+			expectedStatus: 200,
+			expectedRoute: "code",
+			expectedParams: map[string]string{"code": "ABC"},
+		},
+		{
+			name: "unknown path",
+			path: "/orders",
+			expectedStatus: 404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captured = nil
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest("GET", tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedStatus == 200 {
+				assert.Equal(t, tt.expectedRoute, rr.Header().Get("X-Route"))
+				assert.Equal(t, tt.expectedParams, captured)
+			}
+		})
+	}
+}
+
+func TestRouterInvalidPatterns(t *testing.T) {
+	router := NewRouter(nil)
+	require.NoError(t, router.Handle("/users/{id}", "GET", http.NotFoundHandler()))
+
+	assert.Error(t, router.Handle("/users/{id}", "GET", http.NotFoundHandler()))
+	assert.Error(t, router.Handle("/users/{userId}", "POST", http.NotFoundHandler()))
+	assert.Error(t, router.Handle("/files/{name:[}", "GET", http.NotFoundHandler()))
+	assert.Error(t, router.Handle("/files/file.{ext}", "GET", http.NotFoundHandler()))
+	assert.Error(t, router.Handle("/files/{}", "GET", http.NotFoundHandler()))
+	err := router.Handle("/files/{path:.+/.+}", "GET", http.NotFoundHandler())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `parameter "path"`)
+	assert.Contains(t, err.Error(), "cannot contain /")
+}
+
+func TestRouterMethodDispatch(t *testing.T) {
+	logger := &Logger{writer: io.Discard, format: "json"}
+	router := NewRouter(logger)
+
+	endpoints := []Endpoint{
+		{Path: "/users", Method: "GET", Status: 200, Data: `{"id": "uuid"}`, Count: 2},
+		{Path: "/users", Method: "POST", Status: 201, Data: `{"id": "uuid"}`, Count: 1},
+		{Path: "/users/{id}", Method: "DELETE", Status: 204, Count: 1},
+		{Path: "/users/me", Method: "GET", Status: 200, Data: `{"id": "uuid"}`, Count: 1},
+	}
+	for _, endpoint := range endpoints {
+		require.NoError(t, router.Handle(endpoint.Path, endpoint.Method, createLoggingHandler(endpoint, logger)))
+	}
+
+	tests := []struct {
+		name string
+		method string
+		path string
+		expectedStatus int
+		expectedAllow string
+		expectBody bool
+	}{
+		{
+			name: "GET dispatched",
+			method: "GET",
+			path: "/users",
+			expectedStatus: 200,
+			expectBody: true,
+		},
+		{
+			name: "POST dispatched",
+			method: "POST",
+			path: "/users",
+			expectedStatus: 201,
+			expectBody: true,
+		},
+		{
+			name: "HEAD answered by GET without body",
+			method: "HEAD",
+			path: "/users",
+			expectedStatus: 200,
+		},
+		{
+			name: "OPTIONS lists methods",
+			method: "OPTIONS",
+			path: "/users",
+			expectedStatus: 204,
+			expectedAllow: "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			name: "unsupported method",
+			method: "PUT",
+			path: "/users",
+			expectedStatus: 405,
+			expectedAllow: "GET, HEAD, OPTIONS, POST",
+		},
+		{
+			name: "no HEAD without GET",
+			method: "HEAD",
+			path: "/users/1",
+			expectedStatus: 405,
+			expectedAllow: "DELETE, OPTIONS",
+		},
+		{
+			name: "less specific route answers a method the specific one lacks",
+			method: "DELETE",
+			path: "/users/me",
+			expectedStatus: 204,
+		},
+		{
+			name: "405 lists the methods of all matching routes",
+			method: "PUT",
+			path: "/users/me",
+			expectedStatus: 405,
+			expectedAllow: "DELETE, GET, HEAD, OPTIONS",
+		},
+		{
+			name: "unknown path is not 405",
+			method: "PUT",
+			path: "/orders",
+			expectedStatus: 404,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			assert.Equal(t, tt.expectedAllow, rr.Header().Get("Allow"))
+			if tt.expectBody {
+				assert.NotEmpty(t, rr.Body.String())
+			} else if tt.method == "HEAD" && tt.expectedStatus == 200 {
+				assert.Empty(t, rr.Body.String())
+				assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,148 @@
+package mocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// scenarioStarted is the state every scenario begins in.
+const scenarioStarted = "started"
+
+type scenariosKey struct{}
+
+// ScenarioStore holds the current state of each named scenario. Endpoints
+// in a scenario can require a state and move the scenario to another one
+// when they respond.
+type ScenarioStore struct {
+	mu sync.Mutex
+	states map[string]string
+}
+
+func NewScenarioStore() *ScenarioStore {
+	return &ScenarioStore{states: make(map[string]string)}
+}
+
+// register makes a scenario known, so it is listed before its first
+// transition.
+func (s *ScenarioStore) register(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.states[name]; !ok {
+		s.states[name] = scenarioStarted
+	}
+}
+
+func (s *ScenarioStore) State(name string) string {
+	if s == nil {
+		return scenarioStarted
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if state, ok := s.states[name]; ok {
+		return state
+	}
+	return scenarioStarted
+}
+
+func (s *ScenarioStore) Set(name, state string) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states[name] = state
+}
+
+// Transition moves scenario name to next if it is still in state from, or
+// from any state when from is empty, and reports whether it did. The check
+// and the move happen under one lock, so of two concurrent requests only
+// one makes a transition.
+func (s *ScenarioStore) Transition(name, from, next string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	current, ok := s.states[name]
+	if !ok {
+		current = scenarioStarted
+	}
+	if from != "" && current != from {
+		return false
+	}
+	s.states[name] = next
+	return true
+}
+
+// Reset moves one scenario, or all of them when name is empty, back to the
+// started state.
+func (s *ScenarioStore) Reset(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for scenario := range s.states {
+		if name == "" || scenario == name {
+			s.states[scenario] = scenarioStarted
+		}
+	}
+}
+
+func (s *ScenarioStore) Snapshot() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	snapshot := make(map[string]string, len(s.states))
+	for name, state := range s.states {
+		snapshot[name] = state
+	}
+	return snapshot
+}
+
+// scenarios returns the store of the router serving r. Handlers used
+// without a router see every scenario in its started state.
+func scenarios(r *http.Request) *ScenarioStore {
+	store, _ := r.Context().Value(scenariosKey{}).(*ScenarioStore)
+	return store
+}
+
+// createScenariosHandler serves the scenario admin API:
+//
+//	GET    /__apimocker/scenarios         current state of every scenario
+//	DELETE /__apimocker/scenarios         reset all scenarios
+//	PUT    /__apimocker/scenarios/{name}  set a state, body {"state": "..."}
+//	DELETE /__apimocker/scenarios/{name}  reset one scenario
+func createScenariosHandler(store *ScenarioStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		respond := func(statusCode int, body interface{}) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(statusCode)
+			data, _ := json.Marshal(body)
+			w.Write(data)
+		}
+
+		name := pathParams(r)["name"]
+		switch {
+		case r.Method == http.MethodGet && name == "":
+			respond(http.StatusOK, store.Snapshot())
+		case r.Method == http.MethodDelete:
+			if _, known := store.Snapshot()[name]; name != "" && !known {
+				respond(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("Unknown scenario %q", name)})
+				return
+			}
+			store.Reset(name)
+			respond(http.StatusOK, store.Snapshot())
+		case r.Method == http.MethodPut:
+			var body struct {
+				State string `json:"state"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.State == "" {
+				respond(http.StatusBadRequest, map[string]string{"error": `Expected a JSON body like {"state": "..."}`})
+				return
+			}
+			store.Set(name, body.State)
+			respond(http.StatusOK, store.Snapshot())
+		default:
+			respond(http.StatusMethodNotAllowed, map[string]string{"error": "Method Not Allowed"})
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package mocker
+
+import (
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenarios(t *testing.T) {
+	configContent := `endpoints:
+  - path: /login
+    method: POST
+    scenario: session
+    next_state: logged_in
+    body: '{"token": "abc"}'
+  - path: /profile
+    scenario: session
+    status: 401
+    body: '{"error": "not logged in"}'
+    responses:
+      - state: logged_in
+        status: 200
+        body: '{"name": "Ada"}'
+  - path: /logout
+    method: POST
+    scenario: session
+    state: logged_in
+    next_state: started
+    status: 204
+    body: ''
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	config, err := LoadConfig(tmpFile.Name())
+	require.NoError(t, err)
+	config.Admin = &AdminConfig{Enabled: true}
+	server, messages, err := NewServer(config)
+	require.NoError(t, err)
+	assert.Contains(t, messages, "Scenarios: session (/__apimocker/scenarios)")
+
+	call := func(method, target, body string) (int, string) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return rr.Code, rr.Body.String()
+	}
+
+	code, _ := call("GET", "/profile", "")
+	assert.Equal(t, 401, code)
+	code, body := call("POST", "/logout", "")
+	assert.Equal(t, 404, code)
+	assert.Contains(t, body, `state \"started\" of scenario \"session\"`)
+
+	code, _ = call("POST", "/login", "")
+	assert.Equal(t, 200, code)
+	code, body = call("GET", "/profile", "")
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"name": "Ada"}`, body)
+
+	_, body = call("GET", "/__apimocker/scenarios", "")
+	assert.JSONEq(t, `{"session": "logged_in"}`, body)
+
+	code, _ = call("POST", "/logout", "")
+	assert.Equal(t, 204, code)
+	code, _ = call("GET", "/profile", "")
+	assert.Equal(t, 401, code)
+
+	code, _ = call("PUT", "/__apimocker/scenarios/session", `{"state": "logged_in"}`)
+	assert.Equal(t, 200, code)
+	code, _ = call("GET", "/profile", "")
+	assert.Equal(t, 200, code)
+
+	code, body = call("DELETE", "/__apimocker/scenarios", "")
+	assert.Equal(t, 200, code)
+	assert.JSONEq(t, `{"session": "started"}`, body)
+	code, _ = call("GET", "/profile", "")
+	assert.Equal(t, 401, code)
+
+	code, _ = call("DELETE", "/__apimocker/scenarios/nope", "")
+	assert.Equal(t, 404, code)
+	code, _ = call("PUT", "/__apimocker/scenarios/session", `{}`)
+	assert.Equal(t, 400, code)
+}
+
+func TestScenarioStateEndpoints(t *testing.T) {
+	server, err := New(NewConfig(
+		GET("/order").Scenario("checkout", "started", "").Body("open"),
+		GET("/order").Scenario("checkout", "paid", "").Body("paid"),
+		POST("/pay").Scenario("checkout", "started", "paid").Body("ok"),
+	))
+	require.NoError(t, err)
+	call := func(method, path string) (int, string) {
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest(method, path, nil))
+		return rr.Code, rr.Body.String()
+	}
+
+	_, body := call("GET", "/order")
+	assert.Equal(t, "open", body)
+
+	var wg sync.WaitGroup
+	var paid atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if code, _ := call("POST", "/pay"); code == 200 {
+				paid.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), paid.Load(), "only one request makes the transition")
+
+	_, body = call("GET", "/order")
+	assert.Equal(t, "paid", body)
+
+	_, err = New(NewConfig(
+		GET("/order").Scenario("checkout", "paid", "").Body("a"),
+		GET("/order").Scenario("checkout", "paid", "").Body("b"),
+	))
+	assert.ErrorContains(t, err, "duplicate endpoint GET /order")
+}
+
+func TestScenarioStoreTransition(t *testing.T) {
+	store := NewScenarioStore()
+	assert.True(t, store.Transition("flow", "started", "a"))
+	assert.False(t, store.Transition("flow", "started", "b"))
+	assert.Equal(t, "a", store.State("flow"))
+	assert.True(t, store.Transition("flow", "", "c"))
+	assert.Equal(t, "c", store.State("flow"))
+}
+
+func TestScenariosHandlerMethods(t *testing.T) {
+	handler := createScenariosHandler(NewScenarioStore())
+	for _, method := range []string{"POST", "PATCH"} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, "/__apimocker/scenarios", nil))
+		assert.Equal(t, 405, rr.Code, method)
+	}
+}
@@ -0,0 +1,103 @@
+package mocker
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// sequenceCounter tracks how far each client has advanced through an
+// endpoint's sequence. At most maxSequenceClients positions are kept; the
+// least recently seen client is forgotten and starts over.
+type sequenceCounter struct {
+	config *SequenceConfig
+	mu sync.Mutex
+	calls map[string]sequencePosition
+	tick uint64
+}
+
+// sequencePosition is a client's next call and when it was last seen.
+type sequencePosition struct {
+	call int
+	seen uint64
+}
+
+const maxSequenceClients = 10000
+
+func newSequenceCounter(config *SequenceConfig) *sequenceCounter {
+	if config == nil || len(config.Steps) == 0 {
+		return nil
+	}
+	return &sequenceCounter{config: config, calls: make(map[string]sequencePosition)}
+}
+
+// next advances the caller's position and returns its step. HEAD and
+// OPTIONS requests see the step without advancing. It reports false once
+// a sequence without Loop or StickLast is exhausted.
+func (sc *sequenceCounter) next(r *http.Request) (Response, bool) {
+	sc.mu.Lock()
+	config := sc.config
+	client := sequenceClient(r, config.PerClient)
+	position, known := sc.calls[client]
+	call := position.call
+	if r.Method != http.MethodHead && r.Method != http.MethodOptions {
+		if !known && len(sc.calls) >= maxSequenceClients {
+			sc.forgetLeastRecent()
+		}
+		sc.tick++
+		sc.calls[client] = sequencePosition{call: call + 1, seen: sc.tick}
+	}
+	sc.mu.Unlock()
+
+	steps := config.Steps
+	switch {
+	case call < len(steps):
+		return steps[call], true
+	case config.Loop:
+		return steps[call%len(steps)], true
+	case config.StickLast:
+		return steps[len(steps)-1], true
+	}
+	return Response{}, false
+}
+
+// forgetLeastRecent drops the client seen longest ago. sc.mu must be held.
+func (sc *sequenceCounter) forgetLeastRecent() {
+	oldest, oldestSeen := "", uint64(math.MaxUint64)
+	for client, position := range sc.calls {
+		if position.seen < oldestSeen {
+			oldest, oldestSeen = client, position.seen
+		}
+	}
+	delete(sc.calls, oldest)
+}
+
+// configure swaps in the steps of a rebuilt endpoint, such as ones with a
+// new delay, keeping every client's position.
+func (sc *sequenceCounter) configure(config *SequenceConfig) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.config = config
+}
+
+func (sc *sequenceCounter) reset() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	sc.calls = make(map[string]sequencePosition)
+}
+
+func sequenceClient(r *http.Request, perClient string) string {
+	switch {
+	case perClient == "ip":
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	case strings.HasPrefix(perClient, "header:"):
+		return r.Header.Get(strings.TrimPrefix(perClient, "header:"))
+	}
+	return ""
+}
@@ -0,0 +1,173 @@
+package mocker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseSequence(t *testing.T) {
+	configContent := `endpoints:
+  - path: /jobs/{id}
+    body: '{"state": "done"}'
+    sequence:
+      stick_last: true
+      steps:
+        - status: 202
+          body: '{"state": "pending"}'
+        - status: 202
+          body: '{"state": "pending"}'
+        - status: 200
+  - path: /flaky
+    body: ok
+    sequence:
+      steps:
+        - status: 500
+          body: fail
+  - path: /rotate
+    body: x
+    sequence:
+      loop: true
+      per_client: header:X-Client
+      steps:
+        - body: a
+        - body: b
+    responses:
+      - match:
+          query:
+            fixed: "1"
+        body: fixed
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	config, err := LoadConfig(tmpFile.Name())
+	require.NoError(t, err)
+	server, _, err := NewServer(config)
+	require.NoError(t, err)
+
+	call := func(target, client string) (int, string) {
+		req := httptest.NewRequest("GET", target, nil)
+		if client != "" {
+			req.Header.Set("X-Client", client)
+		}
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, req)
+		return rr.Code, rr.Body.String()
+	}
+
+	var statuses []int
+	for i := 0; i < 5; i++ {
+		code, body := call("/jobs/1", "")
+		statuses = append(statuses, code)
+		if i >= 2 {
+			assert.JSONEq(t, `{"state": "done"}`, body)
+		}
+	}
+	assert.Equal(t, []int{202, 202, 200, 200, 200}, statuses)
+
+	code, body := call("/flaky", "")
+	assert.Equal(t, 500, code)
+	assert.Equal(t, "fail", body)
+	code, body = call("/flaky", "")
+	assert.Equal(t, 200, code)
+	assert.Equal(t, "ok", body)
+
+	var bodies []string
+	for _, client := range []string{"one", "one", "two", "one", "two"} {
+		_, body := call("/rotate", client)
+		bodies = append(bodies, body)
+	}
+	assert.Equal(t, []string{"a", "b", "a", "a", "b"}, bodies)
+
+	// Matching rules win and do not advance the sequence.
+	_, body = call("/rotate?fixed=1", "two")
+	assert.Equal(t, "fixed", body)
+	_, body = call("/rotate", "two")
+	assert.Equal(t, "a", body)
+
+	// A new server starts sequences over.
+	server, _, err = NewServer(config)
+	require.NoError(t, err)
+	code, _ = call("/jobs/1", "")
+	assert.Equal(t, 202, code)
+}
+
+func TestSequenceFileStep(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "image.png")
+	require.NoError(t, os.WriteFile(image, []byte("png"), 0644))
+	logPath := filepath.Join(dir, "requests.log")
+
+	config := NewConfig(GET("/image").File(image).Sequence(Response{Status: 503, File: image, Headers: map[string]string{"Retry-After": "1"}}))
+	config.Logging = LogConfig{Enabled: true, Format: "json", Output: logPath}
+	server, err := New(config)
+	require.NoError(t, err)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/image", nil))
+	assert.Equal(t, 503, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "png", rr.Body.String())
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/image", nil))
+	assert.Equal(t, 200, rr.Code)
+	assert.Empty(t, rr.Header().Get("Retry-After"))
+
+	logs, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(logs), `"status_code":503`)
+}
+
+func TestSequenceClient(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:5000"
+	req.Header.Set("X-Client", "abc")
+	assert.Equal(t, "10.0.0.1", sequenceClient(req, "ip"))
+	assert.Equal(t, "abc", sequenceClient(req, "header:X-Client"))
+	assert.Equal(t, "", sequenceClient(req, ""))
+
+	sc := newSequenceCounter(&SequenceConfig{Steps: []Response{{Status: 500}, {Status: 200}}})
+	step, ok := sc.next(req)
+	assert.True(t, ok)
+	assert.Equal(t, 500, step.Status)
+	sc.reset()
+	step, _ = sc.next(req)
+	assert.Equal(t, 500, step.Status)
+	assert.Nil(t, newSequenceCounter(&SequenceConfig{}))
+
+	// HEAD and OPTIONS see the next step without taking it.
+	sc.reset()
+	for _, method := range []string{"HEAD", "OPTIONS", "GET"} {
+		step, _ = sc.next(httptest.NewRequest(method, "/", nil))
+		assert.Equal(t, 500, step.Status, method)
+	}
+
+	// Past maxSequenceClients the least recently seen client starts over.
+	sc = newSequenceCounter(&SequenceConfig{PerClient: "header:X-Client", StickLast: true, Steps: []Response{{Status: 500}, {Status: 200}}})
+	client := func(name string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Client", name)
+		return req
+	}
+	for i := 0; i < maxSequenceClients; i++ {
+		sc.next(client(fmt.Sprint(i)))
+	}
+	sc.next(client("1"))
+	sc.next(client("new"))
+	assert.Len(t, sc.calls, maxSequenceClients)
+	step, _ = sc.next(client("0"))
+	assert.Equal(t, 500, step.Status, "forgotten client starts over")
+	step, _ = sc.next(client("1"))
+	assert.Equal(t, 200, step.Status)
+}
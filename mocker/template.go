@@ -0,0 +1,284 @@
+package mocker
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+)
+
+// templateRequest is the incoming request as seen by response templates.
+type templateRequest struct {
+	Method string
+	Path string
+	PathParams map[string]string
+	Query map[string]string
+	Body interface{}
+	header http.Header
+	cookies []*http.Cookie
+}
+
+// newTemplateRequest captures r for templates. JSON bodies are decoded,
+// form bodies become a map of their first values and anything else is
+// kept as text.
+func newTemplateRequest(r *http.Request) *templateRequest {
+	tr := &templateRequest{
+		Method: r.Method,
+		Path: r.URL.Path,
+		PathParams: pathParams(r),
+		Query: make(map[string]string),
+		Body: map[string]interface{}{},
+		header: r.Header,
+		cookies: r.Cookies(),
+	}
+	if tr.PathParams == nil {
+		tr.PathParams = map[string]string{}
+	}
+	for name, values := range r.URL.Query() {
+		tr.Query[name] = values[0]
+	}
+
+	body := readRequestBody(r)
+	if len(bytes.TrimSpace(body)) == 0 {
+		return tr
+	}
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err == nil {
+		tr.Body = document
+	} else if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		form, _ := url.ParseQuery(string(body))
+		fields := make(map[string]interface{}, len(form))
+		for name, values := range form {
+			fields[name] = values[0]
+		}
+		tr.Body = fields
+	} else {
+		tr.Body = string(body)
+	}
+	return tr
+}
+
+func (tr *templateRequest) Header(name string) string {
+	return tr.header.Get(name)
+}
+
+func (tr *templateRequest) Cookie(name string) string {
+	for _, cookie := range tr.cookies {
+		if cookie.Name == name {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+func isTemplate(text string) bool {
+	return strings.Contains(text, "{{")
+}
+
+// templateFuncs are the helpers available to response templates. They draw
+// from src so seeded responses stay reproducible.
+func templateFuncs(src *fakeSource) template.FuncMap {
+	return template.FuncMap{
+		"uuid": func() string {
+			return src.uuid()
+		},
+		"now": func(layout ...string) string {
+			if len(layout) > 0 {
+				return src.now.Format(layout[0])
+			}
+			return src.now.Format(time.RFC3339)
+		},
+		"faker": func(spec string) (interface{}, error) {
+			generate, err := parseFieldType(spec)
+			if err != nil {
+				return nil, err
+			}
+			return generate(src), nil
+		},
+		"randInt": func(min, max int) (int, error) {
+			if max < min {
+				return 0, fmt.Errorf("randInt: max %d is below min %d", max, min)
+			}
+			return src.intBetween(min, max), nil
+		},
+		"json": func(value interface{}) (string, error) {
+			data, err := json.Marshal(value)
+			return string(data), err
+		},
+		"int": func(value interface{}) (int, error) {
+			switch v := value.(type) {
+			case int:
+				return v, nil
+			case float64:
+				return int(v), nil
+			case json.Number:
+				n, err := v.Int64()
+				return int(n), err
+			}
+			return strconv.Atoi(strings.TrimSpace(fmt.Sprint(value)))
+		},
+		"float": func(value interface{}) (float64, error) {
+			switch v := value.(type) {
+			case int:
+				return float64(v), nil
+			case float64:
+				return v, nil
+			case json.Number:
+				return v.Float64()
+			}
+			return strconv.ParseFloat(strings.TrimSpace(fmt.Sprint(value)), 64)
+		},
+		"bool": func(value interface{}) (bool, error) {
+			if v, ok := value.(bool); ok {
+				return v, nil
+			}
+			return strconv.ParseBool(strings.TrimSpace(fmt.Sprint(value)))
+		},
+		"orEmpty": func(value interface{}) interface{} {
+			if value == nil {
+				return ""
+			}
+			return value
+		},
+	}
+}
+
+func parseTemplate(text string) (*template.Template, error) {
+	tmpl, err := template.New("response").Funcs(templateFuncs(nil)).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			blankMissing(t.Tree, t.Tree.Root)
+		}
+	}
+	return tmpl, nil
+}
+
+// blankMissing pipes every printed action through orEmpty. Missing keys of
+// decoded JSON bodies are nil interfaces, which text/template prints as
+// "<no value>" even with missingkey=zero.
+func blankMissing(tree *parse.Tree, node parse.Node) {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return
+		}
+		for _, child := range node.Nodes {
+			blankMissing(tree, child)
+		}
+	case *parse.ActionNode:
+		if len(node.Pipe.Decl) > 0 {
+			return
+		}
+		orEmpty := parse.NewIdentifier("orEmpty").SetTree(tree).SetPos(node.Pos)
+		node.Pipe.Cmds = append(node.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: node.Pos, Args: []parse.Node{orEmpty}})
+	case *parse.IfNode:
+		blankMissing(tree, node.List)
+		blankMissing(tree, node.ElseList)
+	case *parse.RangeNode:
+		blankMissing(tree, node.List)
+		blankMissing(tree, node.ElseList)
+	case *parse.WithNode:
+		blankMissing(tree, node.List)
+		blankMissing(tree, node.ElseList)
+	}
+}
+
+// renderTemplate executes tmpl against the request carried by src, with
+// funcs added to the helpers.
+func renderTemplate(tmpl *template.Template, src *fakeSource, funcs ...template.FuncMap) (string, error) {
+	tmpl, err := tmpl.Clone()
+	if err != nil {
+		return "", err
+	}
+	request := src.request
+	if request == nil {
+		request = &templateRequest{PathParams: map[string]string{}, Query: map[string]string{}, Body: map[string]interface{}{}}
+	}
+
+	tmpl.Funcs(templateFuncs(src))
+	for _, extra := range funcs {
+		tmpl.Funcs(extra)
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, request); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// compileTemplate turns a templated field into a generator. Fields that
+// fail to render generate null, like unknown field types. A field that is
+// exactly one action yielding a number or boolean keeps that type, so
+// "{{ .PathParams.id | int }}" can fill an integer field; all other output
+// stays a string.
+func compileTemplate(spec, path string) (valueGenerator, error) {
+	tmpl, err := parseTemplate(spec)
+	if err != nil {
+		if path != "" {
+			err = fmt.Errorf("%s: %v", path, err)
+		}
+		return func(src *fakeSource) interface{} { return nil }, err
+	}
+	typed := keepActionValue(tmpl)
+	return func(src *fakeSource) interface{} {
+		var value interface{}
+		keep := template.FuncMap{"keepValue": func(v interface{}) interface{} {
+			value = v
+			return v
+		}}
+		out, err := renderTemplate(tmpl, src, keep)
+		if err != nil {
+			return nil
+		}
+		if typed {
+			switch value.(type) {
+			case bool, int, float64, json.Number:
+				return value
+			}
+		}
+		return out
+	}, nil
+}
+
+// keepActionValue pipes the action of a template that is nothing but one
+// action through keepValue, so its value can be used before it is printed.
+// It reports whether tmpl is such a template.
+func keepActionValue(tmpl *template.Template) bool {
+	if tmpl.Tree == nil || len(tmpl.Tree.Root.Nodes) != 1 {
+		return false
+	}
+	action, ok := tmpl.Tree.Root.Nodes[0].(*parse.ActionNode)
+	if !ok || len(action.Pipe.Decl) > 0 {
+		return false
+	}
+	keepValue := parse.NewIdentifier("keepValue").SetTree(tmpl.Tree).SetPos(action.Pos)
+	action.Pipe.Cmds = append(action.Pipe.Cmds, &parse.CommandNode{NodeType: parse.NodeCommand, Pos: action.Pos, Args: []parse.Node{keepValue}})
+	return true
+}
+
+// renderBody renders a templated body.
+func renderBody(body string, src *fakeSource) ([]byte, error) {
+	if !isTemplate(body) {
+		return []byte(body), nil
+	}
+	tmpl, err := parseTemplate(body)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := renderTemplate(tmpl, src)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(out), nil
+}
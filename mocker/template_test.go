@@ -0,0 +1,102 @@
+package mocker
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseTemplates(t *testing.T) {
+	seed := int64(7)
+	body := `{"id": "{{ .PathParams.id }}", "tenant": "{{ .Header "X-Tenant" }}", "echo": {{ json .Body }}, "n": {{ randInt 5 5 }}, "at": "{{ now "2006-01-02" }}"}`
+	logger, _ := NewLogger(LogConfig{Enabled: false})
+	router := NewRouter(logger)
+	require.NoError(t, router.Handle("/users/{id}", "PUT", createLoggingHandler(Endpoint{
+		Path: "/users/{id}", Method: "PUT", Status: 200, Body: &body, Template: true, Seed: &seed,
+	}, logger)))
+	require.NoError(t, router.Handle("/users/{id}", "GET", createLoggingHandler(Endpoint{
+		Path:   "/users/{id}",
+		Method: "GET",
+		Status: 200,
+		Single: true,
+		Seed:   &seed,
+		Data:   `{"id": "{{ .PathParams.id | int }}", "name": "{{ .Query.name }}", "email": "{{ faker \"email\" }}", "ref": "{{ uuid }}", "missing": "{{ .Body.nope }}", "broken": "{{ faker \"nope\" }}", "literal": "<no value> {{ .Query.zip }}", "admin": "{{ eq .Query.name \"root\" }}", "page": "{{ .Query.page | int }}", "zip": "{{ .Query.zip }}", "ratio": "{{ .Query.ratio | float }}", "n": "{{ randInt 3 3 }}", "both": "{{ .Query.page }}{{ .Query.page }}", "bad": "{{ .Query.name | int }}"}`,
+	}, logger)))
+
+	req := httptest.NewRequest("PUT", "/users/42", strings.NewReader(`{"email": "ada@example.com"}`))
+	req.Header.Set("X-Tenant", "acme")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, 200, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"id": "42", "tenant": "acme", "echo": {"email": "ada@example.com"}, "n": 5, "at": "2025-01-01"}`, rr.Body.String())
+
+	get := func() map[string]interface{} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", "/users/9?name=Ada&zip=01234&page=2&ratio=0.5", nil))
+		require.Equal(t, 200, rr.Code)
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &record))
+		return record
+	}
+	record := get()
+	assert.Equal(t, float64(9), record["id"])
+	assert.Equal(t, float64(2), record["page"], "converted numbers keep their type")
+	assert.Equal(t, 0.5, record["ratio"])
+	assert.Equal(t, float64(3), record["n"])
+	assert.Equal(t, "01234", record["zip"], "numeric strings stay strings")
+	assert.Equal(t, "22", record["both"])
+	assert.Nil(t, record["bad"])
+	assert.Equal(t, "Ada", record["name"])
+	assert.Equal(t, false, record["admin"])
+	assert.Equal(t, "<no value> 01234", record["literal"], "only missing values render empty")
+	assert.Contains(t, record["email"], "@")
+	assert.Len(t, record["ref"], 36)
+	assert.Equal(t, "", record["missing"])
+	assert.Nil(t, record["broken"])
+	assert.Equal(t, record, get(), "seeded templates repeat")
+
+	form := httptest.NewRequest("POST", "/", strings.NewReader("name=Ada&name=Grace"))
+	form.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	assert.Equal(t, map[string]interface{}{"name": "Ada"}, newTemplateRequest(form).Body)
+	assert.Equal(t, "plain", newTemplateRequest(httptest.NewRequest("POST", "/", strings.NewReader("plain"))).Body)
+}
+
+func TestPlainBodyNotTemplated(t *testing.T) {
+	plain := `{"greeting": "Hello {{name}}", "broken": "{{ if }}"}`
+	fixture := filepath.Join(t.TempDir(), "mustache.html")
+	require.NoError(t, os.WriteFile(fixture, []byte("<p>{{#items}}{{.}}{{/items}}</p>"), 0644))
+	templated, yes := `{{ .Query.name }}`, true
+
+	logger, _ := NewLogger(LogConfig{Enabled: false})
+	router := NewRouter(logger)
+	require.NoError(t, router.Handle("/plain", "GET", createLoggingHandler(Endpoint{
+		Path: "/plain", Method: "GET", Status: 200, Body: &plain,
+		Responses: []ResponseRule{{
+			Match: MatchConfig{Query: map[string]Matcher{"name": {Exists: &yes}}},
+			Response: Response{Body: &templated, Template: true},
+		}},
+	}, logger)))
+	require.NoError(t, router.Handle("/fixture", "GET", createLoggingHandler(Endpoint{
+		Path: "/fixture", Method: "GET", Status: 200, BodyFile: fixture,
+	}, logger)))
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", target, nil))
+		return rr
+	}
+	rr := get("/plain")
+	assert.Equal(t, 200, rr.Code)
+	assert.Equal(t, plain, rr.Body.String())
+	rr = get("/fixture")
+	assert.Equal(t, 200, rr.Code)
+	assert.Equal(t, "<p>{{#items}}{{.}}{{/items}}</p>", rr.Body.String())
+	assert.Equal(t, "Ada", get("/plain?name=Ada").Body.String(), "a rule can opt in on its own")
+}
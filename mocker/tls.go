@@ -0,0 +1,329 @@
+package mocker
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// loadClientCAs reads the mtls CA file at path and returns its pool and
+// PEM data.
+func loadClientCAs(path string) (*x509.CertPool, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, nil, fmt.Errorf("%q contains no PEM certificates", path)
+	}
+	return pool, data, nil
+}
+
+// authenticateMTLS checks the client certificate of r against authConfig.
+// The result names the certificate subject whenever there is one.
+func authenticateMTLS(r *http.Request, authConfig *AuthConfig) (bool, string, string) {
+	if r.TLS == nil {
+		return false, "mtls", "tls-required"
+	}
+	if len(r.TLS.PeerCertificates) == 0 {
+		return false, "mtls", "missing-client-cert"
+	}
+
+	cert := r.TLS.PeerCertificates[0]
+	withSubject := func(result string) string {
+		return fmt.Sprintf("%s (subject: %s)", result, cert.Subject)
+	}
+	pool := authConfig.clientCAs
+	var err error
+	if pool == nil {
+		if pool, _, err = loadClientCAs(authConfig.CA); err != nil {
+			return false, "mtls", "invalid-ca"
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range r.TLS.PeerCertificates[1:] {
+		intermediates.AddCert(intermediate)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots: pool,
+		Intermediates: intermediates,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return false, "mtls", withSubject("untrusted-cert")
+	}
+
+	if authConfig.CommonName != "" && cert.Subject.CommonName != authConfig.CommonName {
+		return false, "mtls", withSubject("common-name-mismatch")
+	}
+	if authConfig.SAN != "" && !certHasSAN(cert, authConfig.SAN) {
+		return false, "mtls", withSubject("san-mismatch")
+	}
+	return true, "mtls", withSubject("success")
+}
+
+// certHasSAN reports whether name is one of the DNS names, email
+// addresses, IP addresses or URIs of cert.
+func certHasSAN(cert *x509.Certificate, name string) bool {
+	for _, dns := range cert.DNSNames {
+		if strings.EqualFold(dns, name) {
+			return true
+		}
+	}
+	for _, email := range cert.EmailAddresses {
+		if email == name {
+			return true
+		}
+	}
+	if ip := net.ParseIP(name); ip != nil {
+		for _, certIP := range cert.IPAddresses {
+			if certIP.Equal(ip) {
+				return true
+			}
+		}
+	}
+	for _, uri := range cert.URIs {
+		if uri.String() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TLSConfig returns the configuration to serve HTTPS with, or nil when the
+// config has no tls section.
+func (s *Server) TLSConfig() *tls.Config {
+	return s.tlsConfig
+}
+
+// Protocols returns the protocols to serve: HTTP/1.1 and, unless http2 is
+// false, HTTP/2 negotiated through TLS ALPN or, on plain HTTP, h2c with
+// prior knowledge.
+func (s *Server) Protocols() *http.Protocols {
+	protocols := new(http.Protocols)
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(s.http2)
+	protocols.SetUnencryptedHTTP2(s.http2)
+	return protocols
+}
+
+// clientTLSConfig asks for a client certificate while any endpoint uses
+// mtls auth. A certificate that none of their CAs signed fails the
+// handshake; a missing one is left to the endpoint to reject.
+func (s *Server) clientTLSConfig(*tls.ClientHelloInfo) (*tls.Config, error) {
+	pool := s.clientCAs.Load()
+	if pool == nil {
+		return nil, nil
+	}
+	config := s.tlsConfig.Clone()
+	config.GetConfigForClient = nil
+	config.ClientAuth = tls.VerifyClientCertIfGiven
+	config.ClientCAs = pool
+	return config, nil
+}
+
+// handleCA serves GET /__apimocker/ca.pem, the CA behind auto TLS.
+func (s *Server) handleCA(w http.ResponseWriter, r *http.Request) {
+	if s.ca == nil {
+		adminRespond(w, http.StatusNotFound, map[string]string{"error": "No CA: tls.auto is not enabled"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Write(s.ca.PEM)
+}
+
+// CertificateAuthority issues the leaf certificates for auto TLS. It is
+// kept on disk so clients only have to trust it once.
+type CertificateAuthority struct {
+	Cert *x509.Certificate
+	Key crypto.Signer
+	PEM []byte
+}
+
+// defaultCAPaths is where the auto TLS CA lives unless ca_cert and ca_key
+// say otherwise.
+func defaultCAPaths() (string, string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", "", fmt.Errorf("cannot locate the user config directory: %v", err)
+	}
+	return filepath.Join(dir, "apimocker", "ca.pem"), filepath.Join(dir, "apimocker", "ca-key.pem"), nil
+}
+
+// caPaths returns the CA files of config, filling in the defaults.
+func (config TLSConfig) caPaths() (string, string, error) {
+	if config.CACert != "" && config.CAKey != "" {
+		return config.CACert, config.CAKey, nil
+	}
+	certPath, keyPath, err := defaultCAPaths()
+	if config.CACert != "" {
+		certPath = config.CACert
+	}
+	if config.CAKey != "" {
+		keyPath = config.CAKey
+	}
+	return certPath, keyPath, err
+}
+
+// CA loads the CA used for auto TLS, creating it on first use.
+func (config TLSConfig) CA() (*CertificateAuthority, error) {
+	certPath, keyPath, err := config.caPaths()
+	if err != nil {
+		return nil, err
+	}
+	return LoadCA(certPath, keyPath)
+}
+
+// LoadCA reads the CA at certPath and keyPath, creating and saving a new
+// one when neither file exists.
+func LoadCA(certPath, keyPath string) (*CertificateAuthority, error) {
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+	if errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		return createCA(certPath, keyPath)
+	}
+
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load CA: %v", err)
+	}
+	cert, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CA certificate: %v", err)
+	}
+	key, ok := pair.PrivateKey.(crypto.Signer)
+	if !ok || !cert.IsCA {
+		return nil, fmt.Errorf("%s is not a CA certificate", certPath)
+	}
+	data, _ := os.ReadFile(certPath)
+	return &CertificateAuthority{Cert: cert, Key: key, PEM: data}, nil
+}
+
+func createCA(certPath, keyPath string) (*CertificateAuthority, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject: pkix.Name{CommonName: "apimocker local CA", Organization: []string{"apimocker"}},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter: now.AddDate(10, 0, 0),
+		KeyUsage: x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA: true,
+		MaxPathLenZero: true,
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CA: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	for _, file := range []struct {
+		path string
+		data []byte
+		mode os.FileMode
+	}{{certPath, certPEM, 0644}, {keyPath, keyPEM, 0600}} {
+		if err := os.MkdirAll(filepath.Dir(file.path), 0755); err != nil {
+			return nil, fmt.Errorf("failed to save CA: %v", err)
+		}
+		if err := os.WriteFile(file.path, file.data, file.mode); err != nil {
+			return nil, fmt.Errorf("failed to save CA: %v", err)
+		}
+	}
+	return &CertificateAuthority{Cert: cert, Key: key, PEM: certPEM}, nil
+}
+
+func newSerialNumber() *big.Int {
+	serial, _ := cryptorand.Int(cryptorand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	return serial
+}
+
+// Issue creates a server certificate for localhost and hosts, which may be
+// DNS names or IP addresses.
+func (ca *CertificateAuthority) Issue(hosts []string) (tls.Certificate, error) {
+	return ca.issue("localhost", x509.ExtKeyUsageServerAuth, append([]string{"localhost", "127.0.0.1", "::1"}, hosts...))
+}
+
+// IssueClient creates a client certificate for mtls auth with commonName
+// as its subject and sans as DNS names, email addresses or IP addresses.
+func (ca *CertificateAuthority) IssueClient(commonName string, sans ...string) (tls.Certificate, error) {
+	return ca.issue(commonName, x509.ExtKeyUsageClientAuth, sans)
+}
+
+func (ca *CertificateAuthority) issue(commonName string, usage x509.ExtKeyUsage, sans []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: newSerialNumber(),
+		Subject: pkix.Name{CommonName: commonName, Organization: []string{"apimocker"}},
+		NotBefore: now.Add(-time.Hour),
+		NotAfter: now.AddDate(1, 0, 0),
+		KeyUsage: x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{usage},
+	}
+	for _, san := range sans {
+		if ip := net.ParseIP(san); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else if strings.Contains(san, "@") {
+			template.EmailAddresses = append(template.EmailAddresses, san)
+		} else {
+			template.DNSNames = append(template.DNSNames, san)
+		}
+	}
+
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, ca.Cert, &key.PublicKey, ca.Key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to issue certificate: %v", err)
+	}
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der, ca.Cert.Raw}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// buildTLSConfig loads or issues the server certificate described by
+// config. The CA is only returned for auto TLS.
+func buildTLSConfig(config TLSConfig) (*tls.Config, *CertificateAuthority, error) {
+	if !config.Auto {
+		cert, err := tls.LoadX509KeyPair(config.Cert, config.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil, nil
+	}
+
+	ca, err := config.CA()
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := ca.Issue(config.Hosts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, ca, nil
+}
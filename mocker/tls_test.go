@@ -0,0 +1,230 @@
+package mocker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoTLS(t *testing.T) {
+	dir := t.TempDir()
+	tlsConfig := &TLSConfig{Auto: true, Hosts: []string{"api.test", "10.0.0.1"}, CACert: filepath.Join(dir, "ca.pem"), CAKey: filepath.Join(dir, "ca-key.pem")}
+	config := NewConfig(GET("/ping").Body("pong"))
+	config.TLS = tlsConfig
+	ts := startTest(t, config)
+	require.True(t, strings.HasPrefix(ts.URL, "https://"))
+
+	resp, err := ts.Client().Get(ts.URL + "/__apimocker/ca.pem")
+	require.NoError(t, err)
+	caPEM, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, 200, resp.StatusCode)
+
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM))
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+	resp, err = client.Get(ts.URL + "/ping")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "pong", string(body))
+
+	leaf := ts.Mock.TLSConfig().Certificates[0].Leaf
+	assert.Equal(t, []string{"localhost", "api.test"}, leaf.DNSNames)
+	assert.Len(t, leaf.IPAddresses, 3)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "api.test", Roots: pool})
+	assert.NoError(t, err)
+
+	ca, err := tlsConfig.CA()
+	require.NoError(t, err)
+	assert.Equal(t, caPEM, ca.PEM, "the CA is reused once created")
+	info, err := os.Stat(tlsConfig.CAKey)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	_, messages, err := NewServer(&Config{Port: 8443, TLS: tlsConfig})
+	require.NoError(t, err)
+	assert.Equal(t, "HTTPS: https://localhost:8443 (CA: /__apimocker/ca.pem)", messages[len(messages)-1])
+}
+
+func TestTLSCertFiles(t *testing.T) {
+	dir := t.TempDir()
+	ca, err := LoadCA(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem"))
+	require.NoError(t, err)
+	cert, err := ca.Issue(nil)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+	certPath, keyPath := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+	require.NoError(t, os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0644))
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	config := NewConfig(GET("/ping").Status(200).Body("pong"))
+	config.Port = 8080
+	config.TLS = &TLSConfig{Cert: certPath, Key: keyPath, Port: 8443}
+	server, messages, err := NewServer(config)
+	require.NoError(t, err)
+	assert.Equal(t, "[GET] http://localhost:8080/ping", messages[0])
+	assert.Equal(t, "HTTPS: https://localhost:8443", messages[len(messages)-1])
+	require.Len(t, server.TLSConfig().Certificates, 1)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/__apimocker/ca.pem", nil))
+	assert.Equal(t, 404, rr.Code)
+
+	doc := ExportOpenAPI(config)
+	assert.Len(t, doc["servers"], 2)
+}
+
+func TestMTLSAuth(t *testing.T) {
+	dir := t.TempDir()
+	clientCA, err := LoadCA(filepath.Join(dir, "client-ca.pem"), filepath.Join(dir, "client-ca-key.pem"))
+	require.NoError(t, err)
+	otherCA, err := LoadCA(filepath.Join(dir, "other-ca.pem"), filepath.Join(dir, "other-ca-key.pem"))
+	require.NoError(t, err)
+
+	config := NewConfig(
+		GET("/partner").Body("ok"),
+		GET("/partner-a").Body("ok"),
+		GET("/public").Body("ok"),
+	)
+	caPath := filepath.Join(dir, "client-ca.pem")
+	config.Endpoints[0].Auth = &AuthConfig{Type: "mtls", CA: caPath}
+	config.Endpoints[1].Auth = &AuthConfig{Type: "mtls", CA: caPath, CommonName: "partner-a", SAN: "a.partner.test"}
+	config.TLS = &TLSConfig{Auto: true, CACert: filepath.Join(dir, "ca.pem"), CAKey: filepath.Join(dir, "ca-key.pem")}
+	logPath := filepath.Join(dir, "requests.log")
+	config.Logging = LogConfig{Enabled: true, Format: "json", Output: logPath}
+	ts := startTest(t, config)
+	server := ts.Mock
+	logs := func() string {
+		data, _ := os.ReadFile(logPath)
+		return string(data)
+	}
+
+	clientWith := func(ca *CertificateAuthority, commonName string, sans ...string) *http.Client {
+		client := ts.Client()
+		transport := client.Transport.(*http.Transport).Clone()
+		if ca != nil {
+			cert, err := ca.IssueClient(commonName, sans...)
+			require.NoError(t, err)
+			transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+		}
+		client.Transport = transport
+		return client
+	}
+	get := func(client *http.Client, path string) (int, error) {
+		resp, err := client.Get(ts.URL + path)
+		if err != nil {
+			return 0, err
+		}
+		resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	code, err := get(clientWith(nil, ""), "/public")
+	require.NoError(t, err)
+	assert.Equal(t, 200, code, "endpoints without mtls need no certificate")
+	code, err = get(clientWith(nil, ""), "/partner")
+	require.NoError(t, err)
+	assert.Equal(t, 401, code)
+	assert.Contains(t, logs(), `"auth_result":"missing-client-cert"`)
+
+	code, err = get(clientWith(clientCA, "partner-b", "b.partner.test"), "/partner")
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+	assert.Contains(t, logs(), `"auth_result":"success (subject: CN=partner-b,O=apimocker)"`)
+
+	code, err = get(clientWith(clientCA, "partner-b", "b.partner.test"), "/partner-a")
+	require.NoError(t, err)
+	assert.Equal(t, 401, code)
+	assert.Contains(t, logs(), `"auth_result":"common-name-mismatch (subject: CN=partner-b,O=apimocker)"`)
+	code, err = get(clientWith(clientCA, "partner-a", "b.partner.test"), "/partner-a")
+	require.NoError(t, err)
+	assert.Equal(t, 401, code)
+	assert.Contains(t, logs(), `"auth_result":"san-mismatch`)
+	code, err = get(clientWith(clientCA, "partner-a", "a.partner.test"), "/partner-a")
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+
+	_, err = get(clientWith(otherCA, "partner-a", "a.partner.test"), "/partner-a")
+	assert.Error(t, err, "a certificate from an unknown CA fails the handshake")
+
+	// A CA file changed on disk applies after a reload.
+	otherPEM, err := os.ReadFile(filepath.Join(dir, "other-ca.pem"))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(caPath, otherPEM, 0644))
+	for i := range config.Endpoints {
+		applyEndpointDefaults(&config.Endpoints[i])
+	}
+	_, err = server.Reload(config)
+	require.NoError(t, err)
+	code, err = get(clientWith(otherCA, "partner-a", "a.partner.test"), "/partner-a")
+	require.NoError(t, err)
+	assert.Equal(t, 200, code)
+
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/partner", nil))
+	assert.Equal(t, 401, rr.Code)
+	assert.Contains(t, logs(), `"auth_result":"tls-required"`)
+}
+
+func TestHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	_, err := LoadCA(filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem"))
+	require.NoError(t, err)
+	logPath := filepath.Join(dir, "requests.log")
+
+	config := NewConfig(
+		GET("/ping").Body("pong"),
+		GET("/partner").Body("ok"),
+	)
+	config.Endpoints[1].Auth = &AuthConfig{Type: "mtls", CA: filepath.Join(dir, "ca.pem")}
+	config.TLS = &TLSConfig{Auto: true, CACert: filepath.Join(dir, "ca.pem"), CAKey: filepath.Join(dir, "ca-key.pem")}
+	config.Logging = LogConfig{Enabled: true, Format: "json", Output: logPath}
+	ts := startTest(t, config)
+	resp, err := ts.Client().Get(ts.URL + "/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/2.0", resp.Proto, "HTTP/2 is negotiated through ALPN while client certificates are requested")
+	assert.Equal(t, "HTTP/2.0", ts.Mock.Requests()[0].Protocol)
+	logs, _ := os.ReadFile(logPath)
+	assert.Contains(t, string(logs), `"protocol":"HTTP/2.0"`)
+
+	h2c := new(http.Protocols)
+	h2c.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: h2c}}
+
+	plain := startTest(t, NewConfig(GET("/ping").Body("pong")))
+	resp, err = client.Get(plain.URL + "/ping")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+	assert.Equal(t, "pong", string(body))
+	resp, err = http.Get(plain.URL + "/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+
+	disabled := false
+	config = NewConfig(GET("/ping").Body("pong"))
+	config.HTTP2 = &disabled
+	_, err = client.Get(startTest(t, config).URL + "/ping")
+	assert.Error(t, err, "h2c is refused when http2 is false")
+	config.TLS = &TLSConfig{Auto: true, CACert: filepath.Join(dir, "ca.pem"), CAKey: filepath.Join(dir, "ca-key.pem")}
+	secure := startTest(t, config)
+	resp, err = secure.Client().Get(secure.URL + "/ping")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+}
@@ -0,0 +1,567 @@
+package mocker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigError is a single problem found in a config file, positioned at the
+// offending key or value.
+type ConfigError struct {
+	File string
+	Line int
+	Column int
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+}
+
+type ConfigErrors []ConfigError
+
+func (errs ConfigErrors) Error() string {
+	lines := make([]string, 0, len(errs))
+	for _, err := range errs {
+		lines = append(lines, err.Error())
+	}
+	return "invalid config:\n  " + strings.Join(lines, "\n  ")
+}
+
+var validMethods = map[string]bool{
+	http.MethodGet: true,
+	http.MethodHead: true,
+	http.MethodPost: true,
+	http.MethodPut: true,
+	http.MethodPatch: true,
+	http.MethodDelete: true,
+	http.MethodOptions: true,
+	http.MethodConnect: true,
+	http.MethodTrace: true,
+}
+
+// validateConfig checks a decoded config against the YAML node tree it was
+// decoded from, so every problem can be reported with its line and column.
+// JSON configs parse as YAML, so the same positions apply to them. specErr
+// is the error of importing config.Spec, if any; the caller imports it once.
+func validateConfig(file string, root *yaml.Node, config *Config, specErr error) ConfigErrors {
+	var errs ConfigErrors
+	report := func(node *yaml.Node, format string, args ...interface{}) {
+		err := ConfigError{File: file, Message: fmt.Sprintf(format, args...)}
+		if node != nil {
+			err.Line, err.Column = node.Line, node.Column
+		}
+		errs = append(errs, err)
+	}
+
+	doc := root
+	if doc.Kind == yaml.DocumentNode && len(doc.Content) > 0 {
+		doc = doc.Content[0]
+	}
+	checkUnknownFields(doc, reflect.TypeOf(Config{}), "", report)
+
+	endpointNodes := make([]*yaml.Node, len(config.Endpoints))
+	if list := mappingValue(doc, "endpoints"); list != nil && list.Kind == yaml.SequenceNode {
+		copy(endpointNodes, list.Content)
+	}
+
+	if config.Proxy != nil {
+		proxyNode := valueOr(doc, "proxy")
+		if config.Proxy.Target == "" {
+			report(proxyNode, "proxy.target is required")
+		} else if _, err := parseTargetURL(config.Proxy.Target); err != nil {
+			report(valueOr(proxyNode, "target"), "proxy.target: %v", err)
+		}
+	}
+
+	if config.TLS != nil {
+		tlsNode := valueOr(doc, "tls")
+		if config.TLS.Auto {
+			if config.TLS.Cert != "" || config.TLS.Key != "" {
+				report(tlsNode, "tls: cert and key cannot be combined with auto")
+			}
+		} else if config.TLS.Cert == "" || config.TLS.Key == "" {
+			report(tlsNode, "tls requires cert and key, or auto: true")
+		} else {
+			for _, field := range []struct{ key, path string }{{"cert", config.TLS.Cert}, {"key", config.TLS.Key}} {
+				if _, err := os.Stat(field.path); err != nil {
+					report(valueOr(tlsNode, field.key), "tls.%s: %q does not exist", field.key, field.path)
+				}
+			}
+		}
+		if config.TLS.Port != 0 && config.TLS.Port == config.Port {
+			report(valueOr(tlsNode, "port"), "tls.port: %d is already used for HTTP", config.TLS.Port)
+		}
+	}
+
+	if config.OAuth != nil {
+		validateOAuth(config.OAuth, valueOr(doc, "oauth"), report)
+	}
+
+	if config.Journal.Limit < 0 {
+		report(valueOr(valueOr(doc, "journal"), "limit"), "journal.limit: %d must not be negative", config.Journal.Limit)
+	}
+
+	if specErr != nil {
+		report(valueOr(doc, "spec"), "spec: %v", specErr)
+	}
+
+	router := NewRouter(nil)
+	for i, ep := range config.Endpoints {
+		node := endpointNodes[i]
+		at := func(key string) *yaml.Node {
+			if value := mappingValue(node, key); value != nil {
+				return value
+			}
+			return node
+		}
+		name := fmt.Sprintf("endpoints[%d]", i)
+
+		if ep.Path == "" {
+			report(node, "%s: path is required", name)
+		} else if !strings.HasPrefix(ep.Path, "/") {
+			report(at("path"), "%s.path: %q must start with /", name, ep.Path)
+		} else if strings.HasPrefix(ep.Path, adminPrefix) {
+			report(at("path"), "%s.path: %s is reserved for apimocker", name, adminPrefix)
+		} else if config.OAuth != nil {
+			for _, path := range oauthPaths {
+				if ep.Path == path {
+					report(at("path"), "%s.path: %s is served by the oauth provider", name, path)
+				}
+			}
+		}
+
+		method := strings.ToUpper(ep.Method)
+		if method == "" {
+			method = http.MethodGet
+		}
+		if !validMethods[method] {
+			report(at("method"), "%s.method: invalid method %q", name, ep.Method)
+		}
+
+		switch ep.Kind {
+		case "":
+			if ep.Path != "" && validMethods[method] {
+				if err := router.Handle(ep.Path, method, stateBound(ep, http.NotFoundHandler())); err != nil {
+					report(at("path"), "%s: %v", name, err)
+				}
+			}
+		case "resource":
+			if ep.Path != "" {
+				if err := registerResourceRoutes(router, ep, http.NotFoundHandler()); err != nil {
+					report(at("path"), "%s: %v", name, err)
+				}
+			}
+		default:
+			report(at("kind"), "%s.kind: unknown kind %q (expected \"resource\")", name, ep.Kind)
+		}
+
+		validatePayload(name, at, ep.Status, ep.Count, ep.Data, ep.Body, ep.BodyFile, ep.File, ep.Delay, ep.Template, report)
+
+		if ep.Kind == "resource" && len(ep.Responses) > 0 {
+			report(at("responses"), "%s.responses: not supported on resources", name)
+		}
+		var ruleNodes []*yaml.Node
+		if list := mappingValue(node, "responses"); list != nil && list.Kind == yaml.SequenceNode {
+			ruleNodes = list.Content
+		}
+		for j, rule := range ep.Responses {
+			ruleNode := node
+			if j < len(ruleNodes) {
+				ruleNode = ruleNodes[j]
+			}
+			ruleName := fmt.Sprintf("%s.responses[%d]", name, j)
+			ruleAt := func(key string) *yaml.Node {
+				return valueOr(ruleNode, key)
+			}
+			validatePayload(ruleName, ruleAt, rule.Status, rule.Count, rule.Data, rule.Body, rule.BodyFile, rule.File, rule.Delay, ep.Template || rule.Template, report)
+			validateMatch(rule.Match, ruleAt("match"), ruleName+".match", report)
+		}
+
+		if ep.Scenario == "" {
+			for _, key := range []string{"state", "next_state"} {
+				if mappingValue(node, key) != nil {
+					report(at(key), "%s.%s: requires scenario", name, key)
+				}
+			}
+			for j, rule := range ep.Responses {
+				if rule.State != "" || rule.NextState != "" {
+					report(at("responses"), "%s.responses[%d]: state and next_state require the endpoint to set scenario", name, j)
+				}
+			}
+			if ep.Sequence != nil {
+				for j, step := range ep.Sequence.Steps {
+					if step.NextState != "" {
+						report(at("sequence"), "%s.sequence.steps[%d].next_state: requires the endpoint to set scenario", name, j)
+					}
+				}
+			}
+		} else if ep.Kind == "resource" {
+			report(at("scenario"), "%s.scenario: not supported on resources", name)
+		}
+
+		if ep.Sequence != nil {
+			validateSequence(ep.Sequence, ep.Template, at("sequence"), name+".sequence", report)
+			if ep.Kind == "resource" {
+				report(at("sequence"), "%s.sequence: not supported on resources", name)
+			}
+		}
+
+		var errorNodes []*yaml.Node
+		if list := mappingValue(node, "errors"); list != nil && list.Kind == yaml.SequenceNode {
+			errorNodes = list.Content
+		}
+		for j, errorConfig := range ep.Errors {
+			errorNode := node
+			if j < len(errorNodes) {
+				errorNode = errorNodes[j]
+			}
+			if errorConfig.Probability < 0 || errorConfig.Probability > 1 {
+				report(valueOr(errorNode, "probability"), "%s.errors[%d].probability: %v is outside 0..1", name, j, errorConfig.Probability)
+			}
+			if errorConfig.Status < 100 || errorConfig.Status > 599 {
+				report(valueOr(errorNode, "status"), "%s.errors[%d].status: %d is not a valid HTTP status", name, j, errorConfig.Status)
+			}
+		}
+
+		if ep.Auth != nil {
+			validateAuth(ep.Auth, at("auth"), name+".auth", report)
+			if strings.EqualFold(ep.Auth.Type, "mtls") && config.TLS == nil {
+				report(at("auth"), "%s.auth: mtls auth requires a tls section", name)
+			}
+			if strings.EqualFold(ep.Auth.Type, "jwt") && ep.Auth.Secret == "" && ep.Auth.PublicKey == "" && ep.Auth.JWKS == "" && config.OAuth == nil {
+				report(at("auth"), "%s.auth: jwt auth requires secret, public_key or jwks, or an oauth section", name)
+			}
+		}
+	}
+
+	return errs
+}
+
+// validateOAuth checks the oauth section.
+func validateOAuth(oauth *OAuthConfig, node *yaml.Node, report func(*yaml.Node, string, ...interface{})) {
+	if oauth.Issuer != "" {
+		if issuer, err := url.Parse(oauth.Issuer); err != nil || !issuer.IsAbs() {
+			report(valueOr(node, "issuer"), "oauth.issuer: %q must be an absolute URL", oauth.Issuer)
+		}
+	}
+	if oauth.TokenTTL != "" {
+		if ttl, err := time.ParseDuration(oauth.TokenTTL); err != nil || ttl <= 0 {
+			report(valueOr(node, "token_ttl"), "oauth.token_ttl: %q is not a positive duration", oauth.TokenTTL)
+		}
+	}
+
+	listNode := func(key string, i int) *yaml.Node {
+		if list := mappingValue(node, key); list != nil && list.Kind == yaml.SequenceNode && i < len(list.Content) {
+			return list.Content[i]
+		}
+		return valueOr(node, key)
+	}
+	clientIDs := map[string]bool{}
+	for i, client := range oauth.Clients {
+		clientNode := listNode("clients", i)
+		name := fmt.Sprintf("oauth.clients[%d]", i)
+		if client.ID == "" {
+			report(clientNode, "%s: id is required", name)
+		} else if clientIDs[client.ID] {
+			report(valueOr(clientNode, "id"), "%s.id: duplicate client %q", name, client.ID)
+		}
+		clientIDs[client.ID] = true
+		for j, uri := range client.RedirectURIs {
+			if target, err := url.Parse(uri); err != nil || !target.IsAbs() {
+				report(valueOr(clientNode, "redirect_uris"), "%s.redirect_uris[%d]: %q must be an absolute URL", name, j, uri)
+			}
+		}
+	}
+	usernames := map[string]bool{}
+	for i, user := range oauth.Users {
+		userNode := listNode("users", i)
+		name := fmt.Sprintf("oauth.users[%d]", i)
+		if user.Username == "" {
+			report(userNode, "%s: username is required", name)
+		} else if usernames[user.Username] {
+			report(valueOr(userNode, "username"), "%s.username: duplicate user %q", name, user.Username)
+		}
+		usernames[user.Username] = true
+		if user.Password == "" {
+			report(userNode, "%s: password is required", name)
+		}
+	}
+}
+
+// validatePayload checks the response fields shared by endpoints and their
+// response rules.
+func validatePayload(name string, at func(string) *yaml.Node, status, count int, data string, body *string, bodyFile, file, delay string, template bool, report func(*yaml.Node, string, ...interface{})) {
+	if status != 0 && (status < 100 || status > 599) {
+		report(at("status"), "%s.status: %d is not a valid HTTP status", name, status)
+	}
+	if count < 0 {
+		report(at("count"), "%s.count: must not be negative", name)
+	}
+
+	if data != "" {
+		var template map[string]interface{}
+		if err := json.Unmarshal([]byte(data), &template); err != nil {
+			report(at("data"), "%s.data: invalid JSON schema: %v", name, err)
+		} else if _, err := compileSchema(template, ""); err != nil {
+			report(at("data"), "%s.data: %v", name, err)
+		}
+	}
+
+	if body != nil && (data != "" || file != "" || bodyFile != "") {
+		report(at("body"), "%s.body: cannot be combined with data, file or body_file", name)
+	}
+	if bodyFile != "" && (data != "" || file != "") {
+		report(at("body_file"), "%s.body_file: cannot be combined with data or file", name)
+	}
+	if body != nil && template {
+		if _, err := parseTemplate(*body); err != nil {
+			report(at("body"), "%s.body: %v", name, err)
+		}
+	}
+
+	if delay != "" {
+		if _, err := parseDelay(delay); err != nil {
+			report(at("delay"), "%s.delay: %v", name, err)
+		}
+	}
+
+	for _, field := range []struct{ key, path string }{{"file", file}, {"body_file", bodyFile}} {
+		if field.path == "" {
+			continue
+		}
+		if info, err := os.Stat(field.path); err != nil {
+			report(at(field.key), "%s.%s: %q does not exist", name, field.key, field.path)
+		} else if info.IsDir() {
+			report(at(field.key), "%s.%s: %q is a directory", name, field.key, field.path)
+		}
+	}
+}
+
+func validateSequence(sequence *SequenceConfig, template bool, node *yaml.Node, name string, report func(*yaml.Node, string, ...interface{})) {
+	if len(sequence.Steps) == 0 {
+		report(node, "%s.steps: at least one step is required", name)
+	}
+	if sequence.Loop && sequence.StickLast {
+		report(node, "%s: loop and stick_last cannot both be set", name)
+	}
+	if client := sequence.PerClient; client != "" && client != "ip" && (!strings.HasPrefix(client, "header:") || client == "header:") {
+		report(valueOr(node, "per_client"), "%s.per_client: %q must be \"ip\" or \"header:<Name>\"", name, client)
+	}
+
+	var stepNodes []*yaml.Node
+	if list := mappingValue(node, "steps"); list != nil && list.Kind == yaml.SequenceNode {
+		stepNodes = list.Content
+	}
+	for i, step := range sequence.Steps {
+		stepNode := node
+		if i < len(stepNodes) {
+			stepNode = stepNodes[i]
+		}
+		stepAt := func(key string) *yaml.Node {
+			return valueOr(stepNode, key)
+		}
+		validatePayload(fmt.Sprintf("%s.steps[%d]", name, i), stepAt, step.Status, step.Count, step.Data, step.Body, step.BodyFile, step.File, step.Delay, template || step.Template, report)
+	}
+}
+
+func validateMatch(match MatchConfig, node *yaml.Node, name string, report func(*yaml.Node, string, ...interface{})) {
+	sections := []struct {
+		key string
+		matchers map[string]Matcher
+	}{
+		{"query", match.Query},
+		{"headers", match.Headers},
+		{"path_params", match.PathParams},
+		{"cookies", match.Cookies},
+		{"body", match.Body},
+	}
+	for _, section := range sections {
+		sectionNode := valueOr(node, section.key)
+		keys := make([]string, 0, len(section.matchers))
+		for key := range section.matchers {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyNode := valueOr(sectionNode, key)
+			if section.key == "body" {
+				if _, err := parseJSONPath(key); err != nil {
+					report(keyNode, "%s.body: %v", name, err)
+					continue
+				}
+			}
+			if err := section.matchers[key].validate(); err != nil {
+				report(keyNode, "%s.%s.%s: %v", name, section.key, key, err)
+			}
+		}
+	}
+}
+
+func validateAuth(auth *AuthConfig, node *yaml.Node, name string, report func(*yaml.Node, string, ...interface{})) {
+	switch strings.ToLower(auth.Type) {
+	case "":
+		report(node, "%s: type is required", name)
+	case "basic":
+		if auth.Username == "" {
+			report(node, "%s: basic auth requires username", name)
+		}
+		if auth.Password == "" {
+			report(node, "%s: basic auth requires password", name)
+		}
+	case "bearer":
+		if auth.Token == "" {
+			report(node, "%s: bearer auth requires token", name)
+		}
+	case "jwt":
+		sources := 0
+		for _, source := range []string{auth.Secret, auth.PublicKey, auth.JWKS} {
+			if source != "" {
+				sources++
+			}
+		}
+		if sources > 1 {
+			report(node, "%s: jwt auth takes only one of secret, public_key or jwks", name)
+		} else if sources == 1 {
+			if _, err := loadJWTKeys(auth); err != nil {
+				key := "public_key"
+				if auth.JWKS != "" {
+					key = "jwks"
+				}
+				report(valueOr(node, key), "%s.%s: %v", name, key, err)
+			}
+		}
+		claimNames := make([]string, 0, len(auth.Claims))
+		for claim := range auth.Claims {
+			claimNames = append(claimNames, claim)
+		}
+		sort.Strings(claimNames)
+		for _, claim := range claimNames {
+			if _, err := parseJSONPath(claim); err != nil {
+				report(valueOr(node, "claims"), "%s.claims.%s: %v", name, claim, err)
+			} else if err := auth.Claims[claim].validate(); err != nil {
+				report(valueOr(valueOr(node, "claims"), claim), "%s.claims.%s: %v", name, claim, err)
+			}
+		}
+	case "mtls":
+		if auth.CA == "" {
+			report(node, "%s: mtls auth requires ca", name)
+		} else if _, _, err := loadClientCAs(auth.CA); err != nil {
+			report(valueOr(node, "ca"), "%s.ca: %v", name, err)
+		}
+	default:
+		report(valueOr(node, "type"), "%s.type: unknown auth type %q", name, auth.Type)
+	}
+}
+
+// checkUnknownFields reports mapping keys that have no matching yaml tag in
+// typ, recursing into nested structs, slices and maps.
+func checkUnknownFields(node *yaml.Node, typ reflect.Type, path string, report func(*yaml.Node, string, ...interface{})) {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+
+	switch typ.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			fieldPath := key.Value
+			if path != "" {
+				fieldPath = path + "." + key.Value
+			}
+			field, ok := fieldByYAMLName(typ, key.Value)
+			if !ok {
+				report(key, "%s: unknown field %q", fieldPath, key.Value)
+				continue
+			}
+			checkUnknownFields(value, field.Type, fieldPath, report)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			checkUnknownFields(item, typ.Elem(), fmt.Sprintf("%s[%d]", path, i), report)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			checkUnknownFields(node.Content[i+1], typ.Elem(), path+"."+node.Content[i].Value, report)
+		}
+	}
+}
+
+func fieldByYAMLName(typ reflect.Type, name string) (reflect.StructField, bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == ",inline" {
+			if inlined, ok := fieldByYAMLName(field.Type, name); ok {
+				return inlined, true
+			}
+			continue
+		}
+		if strings.Split(tag, ",")[0] == name {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func valueOr(node *yaml.Node, key string) *yaml.Node {
+	if value := mappingValue(node, key); value != nil {
+		return value
+	}
+	return node
+}
+
+// validateConfigValue runs the config file checks on a config built in
+// memory, such as one edited through the admin API or passed to New.
+// Positions would point into a generated document, so they are dropped.
+// A spec has already been imported into the endpoints and is not read
+// again; specErr is the error of that import, if any.
+func validateConfigValue(file string, config *Config, specErr error) ConfigErrors {
+	data, err := MarshalConfig(config)
+	if err != nil {
+		return ConfigErrors{{File: file, Message: err.Error()}}
+	}
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return ConfigErrors{{File: file, Message: err.Error()}}
+	}
+	errs := validateConfig(file, &root, config, specErr)
+	for i := range errs {
+		errs[i].Line, errs[i].Column = 0, 0
+	}
+	return errs
+}
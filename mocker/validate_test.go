@@ -0,0 +1,449 @@
+package mocker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateConfig(t *testing.T) {
+	configContent := `port: 8080
+loging:
+  enabled: true
+endpoints:
+  - path: /users
+    method: GETT
+  - path: /users
+    method: get
+    data: '{"id": "uuid", "age": "int:9..1"}'
+  - path: /users
+    method: GET
+    delay: soon
+    errors:
+      - probability: 1.5
+        status: 500
+  - path: /file
+    file: ./does-not-exist.jpg
+    colour: blue
+  - path: /secure
+    auth:
+      type: basic
+      username: admin
+  - path: /token
+    auth:
+      type: bearer
+      tokn: abc
+proxy:
+  target: localhost:9000
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	config, err := LoadConfig(tmpFile.Name())
+	assert.Nil(t, config)
+	require.Error(t, err)
+
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs))
+
+	expected := []struct {
+		line int
+		column int
+		message string
+	}{
+		{2, 1, `loging: unknown field "loging"`},
+		{6, 13, `endpoints[0].method: invalid method "GETT"`},
+		{9, 11, `endpoints[1].data: age: invalid int type "int:9..1"`},
+		{10, 11, `endpoints[2]: duplicate endpoint GET /users`},
+		{12, 12, `endpoints[2].delay: invalid delay "soon"`},
+		{14, 22, `endpoints[2].errors[0].probability: 1.5 is outside 0..1`},
+		{17, 11, `endpoints[3].file: "./does-not-exist.jpg" does not exist`},
+		{18, 5, `endpoints[3].colour: unknown field "colour"`},
+		{21, 7, `endpoints[4].auth: basic auth requires password`},
+		{26, 7, `endpoints[5].auth.tokn: unknown field "tokn"`},
+		{25, 7, `endpoints[5].auth: bearer auth requires token`},
+		{28, 11, `proxy.target: invalid target "localhost:9000"`},
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, configErr := range errs {
+		messages = append(messages, configErr.Error())
+	}
+	assert.Len(t, errs, len(expected), strings.Join(messages, "\n"))
+	for _, want := range expected {
+		found := false
+		for _, configErr := range errs {
+			if strings.Contains(configErr.Message, want.message) {
+				found = true
+				assert.Equal(t, want.line, configErr.Line, want.message)
+				assert.Equal(t, want.column, configErr.Column, want.message)
+				assert.Equal(t, tmpFile.Name(), configErr.File)
+			}
+		}
+		assert.True(t, found, "missing error %q in:\n%s", want.message, strings.Join(messages, "\n"))
+	}
+}
+
+func TestValidateConfigJSON(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "*.json")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(`{
+  "port": 8080,
+  "endpoints": [
+    {"path": "/users", "method": "GET", "kind": "table"}
+  ]
+}`)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs))
+	require.Len(t, errs, 1)
+	assert.Equal(t, 4, errs[0].Line)
+	assert.Contains(t, errs[0].Error(), `unknown kind "table"`)
+
+	// An escaped slash is valid JSON but not YAML; the config is still
+	// validated, without positions.
+	require.NoError(t, os.WriteFile(tmpFile.Name(), []byte(`{
+  "port": 8080,
+  "endpoints": [
+    {"path": "\/users", "method": "GET", "kind": "table"}
+  ]
+}`), 0644))
+	_, err = LoadConfig(tmpFile.Name())
+	require.True(t, errors.As(err, &errs), "%v", err)
+	require.Len(t, errs, 1)
+	assert.Equal(t, 0, errs[0].Line)
+	assert.Contains(t, errs[0].Error(), `unknown kind "table"`)
+}
+
+func TestValidateResponseRules(t *testing.T) {
+	configContent := `endpoints:
+  - path: /search
+    responses:
+      - match:
+          query:
+            q:
+              regex: "("
+          body:
+            $.items[: x
+        status: 700
+      - match:
+          headers:
+            X-Mode: {}
+        data: '{"id": "uuid"}'
+        body: 'x'
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+
+	messages := make([]string, 0, len(errs))
+	for _, configErr := range errs {
+		messages = append(messages, fmt.Sprintf("%d:%d %s", configErr.Line, configErr.Column, configErr.Message))
+	}
+	assert.ElementsMatch(t, []string{
+		`10:17 endpoints[0].responses[0].status: 700 is not a valid HTTP status`,
+		`7:15 endpoints[0].responses[0].match.query.q: invalid regex "(": error parsing regexp: missing closing ): ` + "`(`",
+		`9:23 endpoints[0].responses[0].match.body: invalid JSONPath "$.items[": missing ]`,
+		`13:21 endpoints[0].responses[1].match.headers.X-Mode: needs equals, regex, exists, values, gt, gte, lt or lte`,
+		`15:15 endpoints[0].responses[1].body: cannot be combined with data, file or body_file`,
+	}, messages)
+}
+
+func TestValidatePathConstraints(t *testing.T) {
+	configContent := `endpoints:
+  - path: /files/{path:[a-z/]+}
+  - path: /docs/{name:[a-z]{2,8}}
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	require.Len(t, errs, 1, err.Error())
+	assert.Equal(t, 2, errs[0].Line)
+	assert.Contains(t, errs[0].Message, `endpoints[0]: invalid pattern for parameter "path"`)
+	assert.Contains(t, errs[0].Message, "cannot contain /")
+}
+
+func TestValidateTemplates(t *testing.T) {
+	configContent := `endpoints:
+  - path: /a
+    data: '{"id": "{{ .PathParams.id "}'
+  - path: /b
+    body: '{{ if }}'
+    template: true
+  - path: /c
+    body: '{{ if }}'
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	require.Len(t, errs, 2, err.Error())
+	assert.Contains(t, errs[0].Message, "endpoints[0].data: id: template:")
+	assert.Contains(t, errs[1].Message, "endpoints[1].body: template:")
+}
+
+func TestValidateBodyFile(t *testing.T) {
+	configContent := `endpoints:
+  - path: /a
+    body_file: ./does-not-exist.json
+  - path: /b
+    body_file: ./mocker.go
+    file: ./mocker.go
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	require.Len(t, errs, 2, err.Error())
+	assert.Equal(t, `endpoints[0].body_file: "./does-not-exist.json" does not exist`, errs[0].Message)
+	assert.Equal(t, `endpoints[1].body_file: cannot be combined with data or file`, errs[1].Message)
+
+	assert.Equal(t, "application/json", fileContentType("fixtures/users.json"))
+	assert.Equal(t, "image/png", fileContentType("a.PNG"))
+	assert.Equal(t, "application/octet-stream", fileContentType("blob.unknownext"))
+}
+
+func TestValidateSequence(t *testing.T) {
+	configContent := `endpoints:
+  - path: /a
+    sequence:
+      loop: true
+      stick_last: true
+      per_client: cookie
+      steps:
+        - status: 99
+          sttus: 200
+  - path: /b
+    sequence:
+      steps: []
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+
+	messages := make([]string, 0, len(errs))
+	for _, configErr := range errs {
+		messages = append(messages, configErr.Message)
+	}
+	assert.ElementsMatch(t, []string{
+		`endpoints[0].sequence.steps[0].sttus: unknown field "sttus"`,
+		`endpoints[0].sequence: loop and stick_last cannot both be set`,
+		`endpoints[0].sequence.per_client: "cookie" must be "ip" or "header:<Name>"`,
+		`endpoints[0].sequence.steps[0].status: 99 is not a valid HTTP status`,
+		`endpoints[1].sequence.steps: at least one step is required`,
+	}, messages)
+}
+
+func TestValidateScenarios(t *testing.T) {
+	configContent := `endpoints:
+  - path: /a
+    state: paid
+    responses:
+      - state: paid
+        body: x
+  - path: /b
+    kind: resource
+    scenario: checkout
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+
+	messages := make([]string, 0, len(errs))
+	for _, configErr := range errs {
+		messages = append(messages, configErr.Message)
+	}
+	assert.ElementsMatch(t, []string{
+		`endpoints[0].state: requires scenario`,
+		`endpoints[0].responses[0]: state and next_state require the endpoint to set scenario`,
+		`endpoints[1].scenario: not supported on resources`,
+	}, messages)
+}
+
+func TestValidateTLS(t *testing.T) {
+	configContent := `port: 8443
+endpoints:
+  - path: /a
+tls:
+  auto: true
+  cert: server.pem
+  port: 8443
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	require.Len(t, errs, 2, err.Error())
+	assert.Equal(t, "tls: cert and key cannot be combined with auto", errs[0].Message)
+	assert.Equal(t, 5, errs[0].Line)
+	assert.Equal(t, "tls.port: 8443 is already used for HTTP", errs[1].Message)
+
+	_, err = New(&Config{TLS: &TLSConfig{Cert: "missing.pem"}})
+	assert.EqualError(t, err, "invalid config:\n  mocker.New: tls requires cert and key, or auto: true")
+	_, err = New(&Config{TLS: &TLSConfig{Cert: "missing.pem", Key: "missing-key.pem"}})
+	assert.ErrorContains(t, err, `tls.cert: "missing.pem" does not exist`)
+}
+
+func TestValidateMTLSAuth(t *testing.T) {
+	configContent := `endpoints:
+  - path: /a
+    auth:
+      type: mtls
+  - path: /b
+    auth:
+      type: mtls
+      ca: ./missing-ca.pem
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	var messages []string
+	for _, configErr := range errs {
+		messages = append(messages, configErr.Message)
+	}
+	assert.Equal(t, []string{
+		"endpoints[0].auth: mtls auth requires ca",
+		"endpoints[0].auth: mtls auth requires a tls section",
+		"endpoints[1].auth.ca: open ./missing-ca.pem: no such file or directory",
+		"endpoints[1].auth: mtls auth requires a tls section",
+	}, messages)
+}
+
+func TestValidateJWTAuth(t *testing.T) {
+	configContent := `endpoints:
+  - path: /a
+    auth:
+      type: jwt
+  - path: /b
+    auth:
+      type: jwt
+      secret: s
+      jwks: ./missing.json
+  - path: /c
+    auth:
+      type: jwt
+      public_key: ./missing.pem
+  - path: /d
+    auth:
+      type: jwt
+      secret: s
+      claims:
+        role: admin
+        level:
+          regex: "("
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	var messages []string
+	for _, configErr := range errs {
+		messages = append(messages, configErr.Message)
+	}
+	require.Len(t, messages, 4, "%v", messages)
+	assert.Equal(t, "endpoints[0].auth: jwt auth requires secret, public_key or jwks, or an oauth section", messages[0])
+	assert.Equal(t, "endpoints[1].auth: jwt auth takes only one of secret, public_key or jwks", messages[1])
+	assert.Equal(t, "endpoints[2].auth.public_key: open ./missing.pem: no such file or directory", messages[2])
+	assert.Contains(t, messages[3], "endpoints[3].auth.claims.level: invalid regex")
+}
+
+func TestValidateOAuth(t *testing.T) {
+	configContent := `oauth:
+  token_ttl: soon
+  clients:
+    - secret: s
+    - id: web
+      redirect_uris: [/callback]
+    - id: web
+  users:
+    - username: alice
+endpoints:
+  - path: /token
+    method: POST
+  - path: /me
+    auth:
+      type: jwt
+`
+	tmpFile, err := os.CreateTemp("", "*.yaml")
+	require.NoError(t, err)
+	defer os.Remove(tmpFile.Name())
+	tmpFile.WriteString(configContent)
+	tmpFile.Close()
+
+	_, err = LoadConfig(tmpFile.Name())
+	var errs ConfigErrors
+	require.True(t, errors.As(err, &errs), "%v", err)
+	var messages []string
+	for _, configErr := range errs {
+		messages = append(messages, configErr.Message)
+	}
+	assert.Equal(t, []string{
+		`oauth.token_ttl: "soon" is not a positive duration`,
+		"oauth.clients[0]: id is required",
+		`oauth.clients[1].redirect_uris[0]: "/callback" must be an absolute URL`,
+		`oauth.clients[2].id: duplicate client "web"`,
+		"oauth.users[0]: password is required",
+		"endpoints[0].path: /token is served by the oauth provider",
+	}, messages)
+}